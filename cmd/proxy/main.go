@@ -1,27 +1,38 @@
 package main
 
 import (
+	"crypto/tls"
 	"flag"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"github.com/wso2/open-mcp-auth-proxy/internal/audit"
 	"github.com/wso2/open-mcp-auth-proxy/internal/authz"
 	"github.com/wso2/open-mcp-auth-proxy/internal/config"
-	"github.com/wso2/open-mcp-auth-proxy/internal/constants"
 	"github.com/wso2/open-mcp-auth-proxy/internal/logging"
+	"github.com/wso2/open-mcp-auth-proxy/internal/metrics"
 	"github.com/wso2/open-mcp-auth-proxy/internal/proxy"
+	"github.com/wso2/open-mcp-auth-proxy/internal/session"
 	"github.com/wso2/open-mcp-auth-proxy/internal/subprocess"
+	"github.com/wso2/open-mcp-auth-proxy/internal/tracing"
 	"github.com/wso2/open-mcp-auth-proxy/internal/util"
+	"github.com/wso2/open-mcp-auth-proxy/internal/vault"
 )
 
+// version is set at build time with -ldflags "-X main.version=..."
+var version = "dev"
+
 func main() {
 	// Parse command line flags
 	demoMode := flag.Bool("demo", false, "Use Asgardeo-based provider (demo).")
 	asgardeoMode := flag.Bool("asgardeo", false, "Use Asgardeo-based provider (asgardeo).")
+	keycloakMode := flag.Bool("keycloak", false, "Use Keycloak-based provider (keycloak).")
 	debugMode := flag.Bool("debug", false, "Enable debug logging")
 	stdioMode := flag.Bool("stdio", false, "Use stdio transport mode instead of SSE")
 	flag.Parse()
@@ -36,6 +47,19 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Mask secrets in every log line and audit record from here on
+	if err := logger.ConfigureRedaction(cfg.Redaction); err != nil {
+		errorHandler.LogConfigValidationError(err, "redaction")
+		os.Exit(1)
+	}
+
+	// Switch to the configured format and levels; -debug still overrides the levels
+	if err := logger.Configure(cfg.Logging); err != nil {
+		errorHandler.LogConfigValidationError(err, "logging")
+		os.Exit(1)
+	}
+	logger.WatchSignals()
+
 	// Apply command line overrides
 	if err := applyCommandLineOverrides(cfg, *stdioMode, errorHandler); err != nil {
 		os.Exit(1)
@@ -44,25 +68,86 @@ func main() {
 	// Set global config for utility functions
 	util.SetGlobalConfig(cfg)
 
+	// Build the shared clients for identity provider and MCP server calls
+	if err := util.InitOutboundClient(cfg); err != nil {
+		errorHandler.LogStartupError(err, "outbound")
+		os.Exit(1)
+	}
+	if err := util.InitUpstreamTransport(cfg); err != nil {
+		errorHandler.LogStartupError(err, "upstream")
+		os.Exit(1)
+	}
+
 	// Log configuration summary
 	logConfigurationSummary(cfg)
 
-	// Start subprocess if needed
-	procManager := startSubprocessIfNeeded(cfg)
-
 	// Create authentication provider
-	provider := createAuthProvider(cfg, *demoMode, *asgardeoMode)
+	provider, err := createAuthProvider(cfg, *demoMode, *asgardeoMode, *keycloakMode)
+	if err != nil {
+		errorHandler.LogConfigValidationError(fmt.Errorf("provider validation failed: %w", err), "provider")
+		os.Exit(1)
+	}
+
+	routerOptions := []proxy.RouterOption{proxy.WithVersion(version)}
+
+	// Record session owners where the other replicas can find them
+	sessions, err := session.Open(cfg.Cluster)
+	if err != nil {
+		errorHandler.LogStartupError(err, "cluster")
+		os.Exit(1)
+	}
+	routerOptions = append(routerOptions, proxy.WithSessionStore(sessions))
+
+	auditor, err := audit.Open(cfg.Audit)
+	if err != nil {
+		errorHandler.LogStartupError(err, "audit")
+		os.Exit(1)
+	}
+	if auditor != nil {
+		logger.Info("Auditing MCP activity to %s", cfg.Audit.Sink)
+		routerOptions = append(routerOptions, proxy.WithAuditor(auditor))
+	}
+
+	tracer, err := openTracer(cfg)
+	if err != nil {
+		errorHandler.LogStartupError(err, "tracing")
+		os.Exit(1)
+	}
+	tracing.SetTracer(tracer)
 
 	// Fetch JWKS if configured
 	if err := fetchJWKSIfConfigured(cfg, errorHandler); err != nil {
 		os.Exit(1)
 	}
 
+	listener, tlsConfig := listen(cfg)
+
+	// Start per-user servers or a shared subprocess last, so a failed
+	// startup doesn't leave them behind
+	var procManager *subprocess.Manager
+	userServers, serverOptions, err := startUserServersIfNeeded(cfg)
+	if err != nil {
+		errorHandler.LogStartupError(err, "vault")
+		os.Exit(1)
+	}
+	routerOptions = append(routerOptions, serverOptions...)
+	if userServers == nil {
+		procManager = startSubprocessIfNeeded(cfg)
+	}
+	if procManager != nil {
+		metrics.SubprocessUptime.Set(func() float64 { return procManager.Uptime().Seconds() })
+		routerOptions = append(routerOptions, proxy.WithSubprocess(procManager))
+	}
+
+	if cfg.Metrics.Enabled {
+		logger.Info("Serving Prometheus metrics at %s", cfg.Metrics.Path)
+	}
+
 	// Start HTTP server
-	srv := startHTTPServer(cfg, provider)
+	srv := startHTTPServer(cfg, provider, listener, tlsConfig, routerOptions...)
 
 	// Wait for shutdown and cleanup
-	waitForShutdownAndCleanup(srv, procManager)
+	waitForShutdownAndCleanup(srv, procManager, userServers, sessions, auditor, tracer)
 }
 
 // loadConfiguration loads and validates the configuration file
@@ -146,34 +231,72 @@ func startSubprocessIfNeeded(cfg *config.Config) *subprocess.Manager {
 	return procManager
 }
 
-// createAuthProvider creates the appropriate authentication provider
-func createAuthProvider(cfg *config.Config, demoMode, asgardeoMode bool) authz.Provider {
-	var provider authz.Provider
+// startUserServersIfNeeded opens the credential vault and creates the pool of
+// per-user servers when the vault is enabled in stdio transport mode
+func startUserServersIfNeeded(cfg *config.Config) (*subprocess.Pool, []proxy.RouterOption, error) {
+	if !cfg.Vault.Enabled {
+		return nil, nil, nil
+	}
+	if cfg.TransportMode != config.StdioTransport || !cfg.Stdio.Enabled {
+		logger.Warn("The credential vault requires stdio transport mode, ignoring it")
+		return nil, nil, nil
+	}
+
+	key, err := vault.LoadKey(cfg.Vault.KeyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	v, err := vault.Open(cfg.Vault.File, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := subprocess.EnsureDependenciesAvailable(cfg.Stdio.UserCommand); err != nil {
+		logger.Warn("%v", err)
+		logger.Warn("User servers may fail to start due to missing dependencies")
+	}
+
+	logger.Info("Using credential vault %s, starting a server per user from port %d", cfg.Vault.File, cfg.Vault.BasePort)
+	pool := subprocess.NewPool(cfg)
+	broker := vault.NewBroker(v, cfg.Vault.Credentials)
+	return pool, []proxy.RouterOption{proxy.WithUserServers(broker, pool)}, nil
+}
 
+// createAuthProvider creates the authentication provider selected by the
+// command line flags or the provider field of the configuration
+func createAuthProvider(cfg *config.Config, demoMode, asgardeoMode, keycloakMode bool) (authz.Provider, error) {
+	// Command line flags are shorthands for the built-in providers
 	switch {
 	case demoMode:
-		cfg.Mode = "demo"
-		cfg.AuthServerBaseURL = constants.ASGARDEO_BASE_URL + cfg.Demo.OrgName + "/oauth2"
-		cfg.JWKSURL = constants.ASGARDEO_BASE_URL + cfg.Demo.OrgName + "/oauth2/jwks"
-		provider = authz.NewAsgardeoProvider(cfg)
-		logger.Info("Using demo mode with Asgardeo sandbox")
-
+		cfg.Provider = "demo"
 	case asgardeoMode:
-		cfg.Mode = "asgardeo"
-		cfg.AuthServerBaseURL = constants.ASGARDEO_BASE_URL + cfg.Asgardeo.OrgName + "/oauth2"
-		cfg.JWKSURL = constants.ASGARDEO_BASE_URL + cfg.Asgardeo.OrgName + "/oauth2/jwks"
-		provider = authz.NewAsgardeoProvider(cfg)
-		logger.Info("Using Asgardeo mode with organization: %s", cfg.Asgardeo.OrgName)
+		cfg.Provider = "asgardeo"
+	case keycloakMode:
+		cfg.Provider = "keycloak"
+	}
 
-	default:
-		cfg.Mode = "default"
-		cfg.JWKSURL = cfg.Default.JWKSURL
-		cfg.AuthServerBaseURL = cfg.Default.BaseURL
-		provider = authz.NewDefaultProvider(cfg)
+	provider, err := authz.NewProvider(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep the deprecated mode field in sync for existing consumers
+	cfg.Mode = cfg.Provider
+
+	switch cfg.Provider {
+	case "demo":
+		logger.Info("Using demo mode with Asgardeo sandbox")
+	case "asgardeo":
+		logger.Info("Using Asgardeo mode with organization: %s", cfg.Asgardeo.OrgName)
+	case "keycloak":
+		logger.Info("Using Keycloak mode with realm: %s", cfg.Keycloak.Realm)
+	case authz.DefaultProviderName:
 		logger.Info("Using default provider mode")
+	default:
+		logger.Info("Using provider: %s", cfg.Provider)
 	}
 
-	return provider
+	return provider, nil
 }
 
 // fetchJWKSIfConfigured fetches JWKS if a URL is configured
@@ -189,23 +312,56 @@ func fetchJWKSIfConfigured(cfg *config.Config, errorHandler *util.ErrorHandler)
 	}
 
 	logger.Info("JWKS fetched successfully")
+	if len(cfg.TokenValidation.Audiences) == 0 {
+		logger.Warn("token_validation.audiences is not set, accepting access tokens issued for any audience")
+	}
+	util.StartJWKSRefresh(time.Duration(cfg.TokenValidation.JWKSRefreshSeconds) * time.Second)
 	return nil
 }
 
-// startHTTPServer creates and starts the HTTP server
-func startHTTPServer(cfg *config.Config, provider authz.Provider) *http.Server {
-	mux := proxy.NewRouter(cfg, provider)
-	listenAddress := fmt.Sprintf(":%d", cfg.ListenPort)
+// listen opens the listener and loads the TLS configuration of the HTTP server
+func listen(cfg *config.Config) (net.Listener, *tls.Config) {
+	var tlsConfig *tls.Config
+	if cfg.TLS.Enabled {
+		var err error
+		tlsConfig, err = util.NewServerTLSConfig(cfg.TLS)
+		if err != nil {
+			util.NewErrorHandler().LogStartupError(err, "tls")
+			os.Exit(1)
+		}
+	}
+
+	listener, err := util.Listen(cfg)
+	if err != nil {
+		util.NewErrorHandler().LogStartupError(err, "server")
+		os.Exit(1)
+	}
+	return listener, tlsConfig
+}
+
+// startHTTPServer creates the HTTP server and serves it on listener,
+// terminating TLS if configured
+func startHTTPServer(cfg *config.Config, provider authz.Provider, listener net.Listener, tlsConfig *tls.Config, options ...proxy.RouterOption) *http.Server {
+	mux := proxy.NewRouter(cfg, provider, options...)
 
 	srv := &http.Server{
-		Addr:    listenAddress,
-		Handler: mux,
+		Handler:   mux,
+		TLSConfig: tlsConfig,
 	}
 
 	go func() {
-		logger.Info("Server listening on %s", listenAddress)
-		logger.Info("Auth proxy is ready to accept connections")
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if srv.TLSConfig != nil {
+			logger.Info("Server listening on %s (TLS, client auth: %s)", cfg.Listen, cfg.TLS.ClientAuth)
+			logger.Info("Auth proxy is ready to accept connections")
+			// Certificates come from TLSConfig.GetCertificate
+			err = srv.ServeTLS(listener, "", "")
+		} else {
+			logger.Info("Server listening on %s", cfg.Listen)
+			logger.Info("Auth proxy is ready to accept connections")
+			err = srv.Serve(listener)
+		}
+		if err != nil && err != http.ErrServerClosed {
 			util.NewErrorHandler().LogStartupError(err, "server")
 			os.Exit(1)
 		}
@@ -214,8 +370,25 @@ func startHTTPServer(cfg *config.Config, provider authz.Provider) *http.Server {
 	return srv
 }
 
+// openTracer returns the tracer exporting spans, or nil when tracing is disabled
+func openTracer(cfg *config.Config) (*tracing.Tracer, error) {
+	if !cfg.Tracing.Enabled {
+		return nil, nil
+	}
+	var client *http.Client
+	if cfg.Tracing.Exporter == "otlp" {
+		var err error
+		client, err = util.NewHTTPClient(cfg.Tracing.Transport, 10*time.Second)
+		if err != nil {
+			return nil, err
+		}
+		logger.Info("Exporting traces to %s", cfg.Tracing.Endpoint)
+	}
+	return tracing.Open(cfg.Tracing, client)
+}
+
 // waitForShutdownAndCleanup waits for shutdown signal and performs cleanup
-func waitForShutdownAndCleanup(srv *http.Server, procManager *subprocess.Manager) {
+func waitForShutdownAndCleanup(srv *http.Server, procManager *subprocess.Manager, userServers *subprocess.Pool, sessions session.Store, auditor *audit.Auditor, tracer *tracing.Tracer) {
 	// Wait for shutdown signal
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
@@ -227,6 +400,10 @@ func waitForShutdownAndCleanup(srv *http.Server, procManager *subprocess.Manager
 		logger.Info("Terminating subprocess...")
 		procManager.Shutdown()
 	}
+	if userServers != nil {
+		logger.Info("Terminating user servers...")
+		userServers.Shutdown()
+	}
 
 	// Shutdown HTTP server
 	logger.Info("Shutting down HTTP server...")
@@ -236,5 +413,17 @@ func waitForShutdownAndCleanup(srv *http.Server, procManager *subprocess.Manager
 	if err := srv.Shutdown(shutdownCtx); err != nil {
 		logger.Error("HTTP server shutdown error: %v", err)
 	}
+
+	// Flush audit records of the requests that completed during shutdown
+	if err := auditor.Close(); err != nil {
+		logger.Error("Error closing audit log: %v", err)
+	}
+	// Streams remove their sessions as they end, so close the store last
+	if closer, ok := sessions.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			logger.Error("Error closing session store: %v", err)
+		}
+	}
+	tracer.Shutdown()
 	logger.Info("Stopped.")
 }
\ No newline at end of file
@@ -0,0 +1,311 @@
+// Package audit records MCP activity: one record per JSON-RPC exchange,
+// pairing each request sent to the MCP server with its response
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/wso2/open-mcp-auth-proxy/internal/config"
+	logger "github.com/wso2/open-mcp-auth-proxy/internal/logging"
+)
+
+// Outcomes of an exchange
+const (
+	OutcomeSuccess      = "success"
+	OutcomeError        = "error"        // A JSON-RPC error, or a tool result with isError set
+	OutcomeNotification = "notification" // Notifications have no response
+	OutcomeNoResponse   = "no_response"  // The stream ended or the request timed out unanswered
+)
+
+// maxPending bounds the requests awaiting a response
+const maxPending = 10000
+
+// Record is one audited exchange
+type Record struct {
+	Time         time.Time       `json:"time"` // When the request was received
+	Subject      string          `json:"subject"`
+	ClientID     string          `json:"client_id,omitempty"`
+	Session      string          `json:"session,omitempty"`
+	RequestID    json.RawMessage `json:"request_id,omitempty"` // JSON-RPC id
+	Method       string          `json:"method"`
+	Tool         string          `json:"tool,omitempty"`     // tools/call
+	Prompt       string          `json:"prompt,omitempty"`   // prompts/get
+	Resource     string          `json:"resource,omitempty"` // resources/read and subscriptions
+	Arguments    json.RawMessage `json:"arguments,omitempty"`
+	Truncated    bool            `json:"arguments_truncated,omitempty"` // Arguments were too large to record
+	Outcome      string          `json:"outcome"`
+	ErrorCode    int             `json:"error_code,omitempty"`
+	ErrorMessage string          `json:"error_message,omitempty"`
+	LatencyMS    int64           `json:"latency_ms"`
+}
+
+// Caller identifies who sent a request
+type Caller struct {
+	Subject  string
+	ClientID string
+	Session  string
+}
+
+// Sink writes records somewhere durable
+type Sink interface {
+	Write(r Record) error
+	Close() error
+}
+
+// message is the part of a JSON-RPC message the auditor reads
+type message struct {
+	ID     json.RawMessage `json:"id,omitempty"`
+	Method string          `json:"method,omitempty"`
+	Params struct {
+		Name      string          `json:"name"`
+		URI       string          `json:"uri"`
+		Arguments json.RawMessage `json:"arguments"`
+	} `json:"params"`
+	Result *struct {
+		IsError bool `json:"isError"`
+	} `json:"result,omitempty"`
+	Error *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// Auditor pairs requests with their responses and writes the records.
+// Exchanges are grouped in scopes: the session for responses arriving on an
+// SSE stream, or a single HTTP request for responses in its body.
+type Auditor struct {
+	sink           Sink
+	redactor       *logger.Redactor
+	maxArgs        int
+	pendingTimeout time.Duration
+	now            func() time.Time
+
+	mu        sync.Mutex
+	pending   map[string]map[string]*Record // By scope, then request ID
+	count     int
+	lastSweep time.Time
+}
+
+// New returns nil when auditing is disabled
+func New(cfg config.AuditConfig, sink Sink) *Auditor {
+	if !cfg.Enabled {
+		return nil
+	}
+	return &Auditor{
+		sink:           sink,
+		redactor:       logger.CurrentRedactor().WithKeys(cfg.RedactKeys),
+		maxArgs:        cfg.MaxArgumentBytes,
+		pendingTimeout: time.Duration(cfg.PendingTimeoutSeconds) * time.Second,
+		now:            time.Now,
+		pending:        make(map[string]map[string]*Record),
+	}
+}
+
+// Request records the JSON-RPC message or batch in body, sent by caller.
+// Notifications are written right away; requests wait for their response.
+func (a *Auditor) Request(scope string, caller Caller, body []byte) {
+	if a == nil {
+		return
+	}
+	messages := parse(body)
+	if len(messages) == 0 {
+		return
+	}
+
+	now := a.now()
+	var notifications []Record
+
+	a.mu.Lock()
+	expired := a.sweep(now)
+	for _, m := range messages {
+		if m.Method == "" {
+			continue // The client's responses to server requests
+		}
+		record := &Record{
+			Time:     now,
+			Subject:  caller.Subject,
+			ClientID: caller.ClientID,
+			Session:  caller.Session,
+			Method:   m.Method,
+		}
+		a.describe(record, m)
+
+		if len(m.ID) == 0 || string(m.ID) == "null" {
+			record.Outcome = OutcomeNotification
+			notifications = append(notifications, *record)
+			continue
+		}
+		record.RequestID = m.ID
+		if a.count >= maxPending {
+			// Nothing can be held anymore; keep the request on record at least
+			record.Outcome = OutcomeNoResponse
+			notifications = append(notifications, *record)
+			continue
+		}
+		if a.pending[scope] == nil {
+			a.pending[scope] = make(map[string]*Record)
+		}
+		if _, exists := a.pending[scope][string(m.ID)]; !exists {
+			a.count++
+		}
+		a.pending[scope][string(m.ID)] = record
+	}
+	a.mu.Unlock()
+
+	a.write(expired...)
+	a.write(notifications...)
+}
+
+// Response completes the exchanges answered by the JSON-RPC message or batch in body
+func (a *Auditor) Response(scope string, body []byte) {
+	if a == nil {
+		return
+	}
+	messages := parse(body)
+	if len(messages) == 0 {
+		return
+	}
+
+	now := a.now()
+	var completed []Record
+
+	a.mu.Lock()
+	for _, m := range messages {
+		if m.Method != "" || (m.Result == nil && m.Error == nil) {
+			continue // Server requests and notifications
+		}
+		record, ok := a.pending[scope][string(m.ID)]
+		if !ok {
+			continue
+		}
+		a.remove(scope, string(m.ID))
+
+		record.LatencyMS = now.Sub(record.Time).Milliseconds()
+		switch {
+		case m.Error != nil:
+			record.Outcome = OutcomeError
+			record.ErrorCode = m.Error.Code
+			record.ErrorMessage = a.redactor.String(m.Error.Message)
+		case m.Result.IsError:
+			record.Outcome = OutcomeError
+		default:
+			record.Outcome = OutcomeSuccess
+		}
+		completed = append(completed, *record)
+	}
+	a.mu.Unlock()
+
+	a.write(completed...)
+}
+
+// Abandon records the requests still pending in scope as unanswered, for
+// when their stream or HTTP request ended
+func (a *Auditor) Abandon(scope string) {
+	if a == nil {
+		return
+	}
+	now := a.now()
+
+	a.mu.Lock()
+	var abandoned []Record
+	for id, record := range a.pending[scope] {
+		record.Outcome = OutcomeNoResponse
+		record.LatencyMS = now.Sub(record.Time).Milliseconds()
+		abandoned = append(abandoned, *record)
+		a.remove(scope, id)
+	}
+	a.mu.Unlock()
+
+	a.write(abandoned...)
+}
+
+// Close flushes and closes the sink
+func (a *Auditor) Close() error {
+	if a == nil {
+		return nil
+	}
+	return a.sink.Close()
+}
+
+// describe fills in what the message acts on and its arguments, redacted
+// with the log redaction rules plus the audit's own keys
+func (a *Auditor) describe(record *Record, m message) {
+	switch m.Method {
+	case "tools/call":
+		record.Tool = m.Params.Name
+	case "prompts/get":
+		record.Prompt = m.Params.Name
+	case "resources/read", "resources/subscribe", "resources/unsubscribe":
+		// Resource URIs may carry credentials in their query
+		record.Resource = a.redactor.String(m.Params.URI)
+	}
+
+	if len(m.Params.Arguments) == 0 || string(m.Params.Arguments) == "null" {
+		return
+	}
+	args := a.redactor.JSON(m.Params.Arguments)
+	if a.maxArgs > 0 && len(args) > a.maxArgs {
+		record.Truncated = true
+		return
+	}
+	record.Arguments = args
+}
+
+// sweep removes the requests pending longer than the timeout, at most once a
+// minute, and returns them as unanswered. Callers hold a.mu.
+func (a *Auditor) sweep(now time.Time) []Record {
+	if now.Sub(a.lastSweep) < time.Minute {
+		return nil
+	}
+	a.lastSweep = now
+
+	var expired []Record
+	for scope, records := range a.pending {
+		for id, record := range records {
+			if now.Sub(record.Time) > a.pendingTimeout {
+				record.Outcome = OutcomeNoResponse
+				record.LatencyMS = now.Sub(record.Time).Milliseconds()
+				expired = append(expired, *record)
+				a.remove(scope, id)
+			}
+		}
+	}
+	return expired
+}
+
+// remove forgets a pending request. Callers hold a.mu.
+func (a *Auditor) remove(scope, id string) {
+	delete(a.pending[scope], id)
+	if len(a.pending[scope]) == 0 {
+		delete(a.pending, scope)
+	}
+	a.count--
+}
+
+func (a *Auditor) write(records ...Record) {
+	for _, record := range records {
+		if err := a.sink.Write(record); err != nil {
+			auditLog.Error("Failed to write audit record: %v", err)
+		}
+	}
+}
+
+// parse reads a JSON-RPC message or batch. Anything else yields no messages.
+func parse(body []byte) []message {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		var batch []message
+		if json.Unmarshal(trimmed, &batch) != nil {
+			return nil
+		}
+		return batch
+	}
+	var single message
+	if json.Unmarshal(trimmed, &single) != nil {
+		return nil
+	}
+	return []message{single}
+}
@@ -0,0 +1,165 @@
+package audit
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/wso2/open-mcp-auth-proxy/internal/config"
+)
+
+// memorySink keeps the records written to it
+type memorySink struct {
+	mu      sync.Mutex
+	records []Record
+}
+
+func (s *memorySink) Write(r Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records = append(s.records, r)
+	return nil
+}
+
+func (s *memorySink) Close() error { return nil }
+
+func (s *memorySink) all() []Record {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Record{}, s.records...)
+}
+
+func newTestAuditor(sink Sink) *Auditor {
+	return New(config.AuditConfig{
+		Enabled:               true,
+		RedactKeys:            []string{"ssn"},
+		MaxArgumentBytes:      200,
+		PendingTimeoutSeconds: 300,
+	}, sink)
+}
+
+func TestAuditExchanges(t *testing.T) {
+	caller := Caller{Subject: "alice", ClientID: "app", Session: "s1"}
+
+	tests := []struct {
+		name     string
+		request  string
+		response string
+		expected Record
+	}{
+		{
+			name:     "Tool call",
+			request:  `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"search","arguments":{"query":"go"}}}`,
+			response: `{"jsonrpc":"2.0","id":1,"result":{"content":[]}}`,
+			expected: Record{Method: "tools/call", Tool: "search", Arguments: json.RawMessage(`{"query":"go"}`), Outcome: OutcomeSuccess},
+		},
+		{
+			name:     "Tool error result",
+			request:  `{"jsonrpc":"2.0","id":"a","method":"tools/call","params":{"name":"search"}}`,
+			response: `{"jsonrpc":"2.0","id":"a","result":{"isError":true}}`,
+			expected: Record{Method: "tools/call", Tool: "search", Outcome: OutcomeError},
+		},
+		{
+			name:     "JSON-RPC error",
+			request:  `{"jsonrpc":"2.0","id":2,"method":"resources/read","params":{"uri":"file:///etc/hosts"}}`,
+			response: `{"jsonrpc":"2.0","id":2,"error":{"code":-32002,"message":"Resource not found"}}`,
+			expected: Record{Method: "resources/read", Resource: "file:///etc/hosts", Outcome: OutcomeError, ErrorCode: -32002, ErrorMessage: "Resource not found"},
+		},
+		{
+			name:     "Notification",
+			request:  `{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+			expected: Record{Method: "notifications/initialized", Outcome: OutcomeNotification},
+		},
+		{
+			name:     "Redacted arguments",
+			request:  `{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"login","arguments":{"user":"alice","Password":"hunter2","auth":{"api-key":"k","max_tokens":5},"SSN":"123"}}}`,
+			response: `{"jsonrpc":"2.0","id":3,"result":{}}`,
+			expected: Record{Method: "tools/call", Tool: "login", Arguments: json.RawMessage(`{"Password":"[REDACTED]","SSN":"[REDACTED]","auth":{"api-key":"[REDACTED]","max_tokens":5},"user":"alice"}`), Outcome: OutcomeSuccess},
+		},
+		{
+			name:     "Large arguments",
+			request:  `{"jsonrpc":"2.0","id":4,"method":"tools/call","params":{"name":"upload","arguments":{"data":"` + strings.Repeat("x", 300) + `"}}}`,
+			response: `{"jsonrpc":"2.0","id":4,"result":{}}`,
+			expected: Record{Method: "tools/call", Tool: "upload", Truncated: true, Outcome: OutcomeSuccess},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			sink := &memorySink{}
+			auditor := newTestAuditor(sink)
+			auditor.Request("scope", caller, []byte(tc.request))
+			if tc.response != "" {
+				auditor.Response("scope", []byte(tc.response))
+			}
+
+			records := sink.all()
+			if len(records) != 1 {
+				t.Fatalf("Expected 1 record, got %d", len(records))
+			}
+			got := records[0]
+			if got.Subject != "alice" || got.ClientID != "app" || got.Session != "s1" {
+				t.Errorf("Expected caller alice/app/s1, got %s/%s/%s", got.Subject, got.ClientID, got.Session)
+			}
+			if got.Method != tc.expected.Method || got.Tool != tc.expected.Tool || got.Resource != tc.expected.Resource {
+				t.Errorf("Expected %s %s%s, got %s %s%s", tc.expected.Method, tc.expected.Tool, tc.expected.Resource, got.Method, got.Tool, got.Resource)
+			}
+			if got.Outcome != tc.expected.Outcome || got.ErrorCode != tc.expected.ErrorCode || got.ErrorMessage != tc.expected.ErrorMessage {
+				t.Errorf("Expected outcome %s %d %q, got %s %d %q", tc.expected.Outcome, tc.expected.ErrorCode, tc.expected.ErrorMessage, got.Outcome, got.ErrorCode, got.ErrorMessage)
+			}
+			if string(got.Arguments) != string(tc.expected.Arguments) || got.Truncated != tc.expected.Truncated {
+				t.Errorf("Expected arguments %s (truncated=%v), got %s (truncated=%v)", tc.expected.Arguments, tc.expected.Truncated, got.Arguments, got.Truncated)
+			}
+		})
+	}
+}
+
+func TestAuditBatchAndScopes(t *testing.T) {
+	sink := &memorySink{}
+	auditor := newTestAuditor(sink)
+	now := time.Unix(1000, 0)
+	auditor.now = func() time.Time { return now }
+
+	auditor.Request("s1", Caller{Subject: "alice"}, []byte(`[{"jsonrpc":"2.0","id":1,"method":"tools/list"},{"jsonrpc":"2.0","id":2,"method":"prompts/get","params":{"name":"greet"}}]`))
+	auditor.Request("s2", Caller{Subject: "bob"}, []byte(`{"jsonrpc":"2.0","id":1,"method":"ping"}`))
+
+	// The same ID in another scope doesn't complete the request
+	now = now.Add(250 * time.Millisecond)
+	auditor.Response("s1", []byte(`{"jsonrpc":"2.0","id":1,"result":{}}`))
+	records := sink.all()
+	if len(records) != 1 || records[0].Subject != "alice" || records[0].Method != "tools/list" {
+		t.Fatalf("Expected alice's tools/list to complete, got %+v", records)
+	}
+	if records[0].LatencyMS != 250 {
+		t.Errorf("Expected latency 250ms, got %d", records[0].LatencyMS)
+	}
+
+	auditor.Abandon("s1")
+	records = sink.all()
+	if len(records) != 2 || records[1].Prompt != "greet" || records[1].Outcome != OutcomeNoResponse {
+		t.Errorf("Expected the unanswered prompts/get to be recorded, got %+v", records)
+	}
+
+	// Requests pending past the timeout are recorded with the next request
+	now = now.Add(10 * time.Minute)
+	auditor.Request("s3", Caller{Subject: "carol"}, []byte(`{"jsonrpc":"2.0","method":"notifications/initialized"}`))
+	records = sink.all()
+	if len(records) != 4 || records[2].Subject != "bob" || records[2].Outcome != OutcomeNoResponse {
+		t.Errorf("Expected bob's ping to time out, got %+v", records)
+	}
+}
+
+func TestAuditIgnoresNonRPC(t *testing.T) {
+	sink := &memorySink{}
+	auditor := newTestAuditor(sink)
+	auditor.Request("scope", Caller{Subject: "alice"}, []byte(`not json`))
+	auditor.Request("scope", Caller{Subject: "alice"}, []byte(`{"jsonrpc":"2.0","id":7,"result":{}}`)) // A client's response
+	auditor.Response("scope", []byte(`{"jsonrpc":"2.0","method":"notifications/progress"}`))
+	if records := sink.all(); len(records) != 0 {
+		t.Errorf("Expected no records, got %+v", records)
+	}
+
+	var disabled *Auditor
+	disabled.Request("scope", Caller{}, []byte(`{"jsonrpc":"2.0","method":"ping"}`))
+}
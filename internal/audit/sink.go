@@ -0,0 +1,272 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/wso2/open-mcp-auth-proxy/internal/config"
+	logger "github.com/wso2/open-mcp-auth-proxy/internal/logging"
+	"github.com/wso2/open-mcp-auth-proxy/internal/metrics"
+	"github.com/wso2/open-mcp-auth-proxy/internal/util"
+)
+
+// auditLog logs the delivery of audit records with the proxy subsystem
+var auditLog = logger.For(logger.Proxy)
+
+// Reasons for dropping audit records, as counted in the drop metric
+const (
+	DropQueueFull  = "queue_full"
+	DropPostFailed = "post_failed"
+)
+
+// Backoff between attempts to post a batch to the webhook
+var (
+	webhookRetryBackoff = time.Second
+	webhookMaxBackoff   = 30 * time.Second
+)
+
+// Open returns the auditor with the configured sink, or nil when auditing is disabled
+func Open(cfg config.AuditConfig) (*Auditor, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	var sink Sink
+	switch cfg.Sink {
+	case "stdout":
+		sink = NewWriterSink(os.Stdout)
+	case "file":
+		file, err := NewFileSink(cfg.File)
+		if err != nil {
+			return nil, err
+		}
+		sink = file
+	case "webhook":
+		client, err := util.NewHTTPClient(cfg.Webhook.Transport, 10*time.Second)
+		if err != nil {
+			return nil, err
+		}
+		sink = NewWebhookSink(cfg.Webhook, client)
+	default:
+		return nil, fmt.Errorf("unknown audit sink %q", cfg.Sink)
+	}
+	return New(cfg, sink), nil
+}
+
+// WriterSink writes records as JSON lines
+type WriterSink struct {
+	mu     sync.Mutex
+	w      io.Writer
+	closer io.Closer // nil for writers the sink doesn't own, such as stdout
+}
+
+// NewWriterSink writes records to w, which stays open when the sink is closed
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{w: w}
+}
+
+// NewFileSink appends records to the file at path, creating it if needed
+func NewFileSink(path string) (*WriterSink, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit file: %w", err)
+	}
+	return &WriterSink{w: f, closer: f}, nil
+}
+
+// Write implements Sink
+func (s *WriterSink) Write(r Record) error {
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// One write per line, so lines of concurrent writers never interleave
+	_, err = s.w.Write(append(line, '\n'))
+	return err
+}
+
+// Close implements Sink
+func (s *WriterSink) Close() error {
+	if s.closer == nil {
+		return nil
+	}
+	return s.closer.Close()
+}
+
+// WebhookSink posts records in batches from a background goroutine. Failed
+// posts are retried with backoff while records queue up behind them. Records
+// arriving while the queue is full are dropped, and so is the batch being
+// retried, so a slow collector never holds up MCP traffic.
+type WebhookSink struct {
+	cfg    config.WebhookConfig
+	client *http.Client
+
+	queue   chan Record
+	closing chan struct{} // Closed by Close, to cut retries short
+	done    chan struct{}
+
+	mu      sync.Mutex
+	closed  bool
+	dropped int // Since the last warning
+}
+
+// NewWebhookSink starts a sink posting to cfg.URL
+func NewWebhookSink(cfg config.WebhookConfig, client *http.Client) *WebhookSink {
+	s := &WebhookSink{
+		cfg:     cfg,
+		client:  client,
+		queue:   make(chan Record, cfg.QueueSize),
+		closing: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+// Write implements Sink
+func (s *WebhookSink) Write(r Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return fmt.Errorf("audit webhook sink is closed")
+	}
+
+	select {
+	case s.queue <- r:
+		return nil
+	default:
+		metrics.AuditRecordsDropped.Inc(DropQueueFull)
+		s.dropped++
+		if s.dropped == 1 {
+			return fmt.Errorf("audit webhook queue is full, dropping records")
+		}
+		return nil
+	}
+}
+
+// Close posts the queued records and stops the sink
+func (s *WebhookSink) Close() error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+		close(s.closing)
+	}
+	s.mu.Unlock()
+	<-s.done
+	return nil
+}
+
+func (s *WebhookSink) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(time.Duration(s.cfg.FlushIntervalSeconds) * time.Second)
+	defer ticker.Stop()
+
+	var batch []Record
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		s.deliver(batch)
+		batch = nil
+	}
+
+	for {
+		select {
+		case r, ok := <-s.queue:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, r)
+			if len(batch) >= s.cfg.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+			s.reportDropped()
+		}
+	}
+}
+
+// deliver posts batch, retrying with backoff until it is posted or the queue
+// fills up behind it. Once the sink is closing, a failed batch is retried once.
+func (s *WebhookSink) deliver(batch []Record) {
+	backoff := webhookRetryBackoff
+	for attempt := 1; ; attempt++ {
+		err := s.post(batch)
+		if err == nil {
+			return
+		}
+		queueFull := len(s.queue) >= cap(s.queue)
+		if queueFull || (isClosed(s.closing) && attempt > 1) {
+			auditLog.Error("Dropped %d audit records after failing to post them: %v", len(batch), err)
+			metrics.AuditRecordsDropped.Add(float64(len(batch)), DropPostFailed)
+			return
+		}
+		auditLog.Warn("Failed to post %d audit records, retrying in %v: %v", len(batch), backoff, err)
+
+		select {
+		case <-time.After(backoff):
+		case <-s.closing:
+		}
+		backoff *= 2
+		if backoff > webhookMaxBackoff {
+			backoff = webhookMaxBackoff
+		}
+	}
+}
+
+func (s *WebhookSink) post(batch []Record) error {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, s.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range s.cfg.Headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("collector returned %s", resp.Status)
+	}
+	return nil
+}
+
+func (s *WebhookSink) reportDropped() {
+	s.mu.Lock()
+	dropped := s.dropped
+	s.dropped = 0
+	s.mu.Unlock()
+	if dropped > 0 {
+		auditLog.Warn("Dropped %d audit records while the webhook queue was full", dropped)
+	}
+}
+
+func isClosed(ch chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
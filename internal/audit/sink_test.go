@@ -0,0 +1,147 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/wso2/open-mcp-auth-proxy/internal/config"
+	"github.com/wso2/open-mcp-auth-proxy/internal/metrics"
+)
+
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	sink, err := NewFileSink(path)
+	if err != nil {
+		t.Fatalf("NewFileSink failed: %v", err)
+	}
+	sink.Write(Record{Subject: "alice", Method: "tools/call", Tool: "search", Outcome: OutcomeSuccess})
+	sink.Write(Record{Subject: "bob", Method: "ping", Outcome: OutcomeNoResponse})
+	sink.Close()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open audit file: %v", err)
+	}
+	defer f.Close()
+
+	var subjects []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var r Record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			t.Fatalf("Expected a JSON record per line, got %q", scanner.Text())
+		}
+		subjects = append(subjects, r.Subject)
+	}
+	if len(subjects) != 2 || subjects[0] != "alice" || subjects[1] != "bob" {
+		t.Errorf("Expected records of alice and bob, got %v", subjects)
+	}
+}
+
+func TestWebhookSink(t *testing.T) {
+	var mu sync.Mutex
+	var batches [][]Record
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer collector-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var batch []Record
+		json.NewDecoder(r.Body).Decode(&batch)
+		mu.Lock()
+		batches = append(batches, batch)
+		mu.Unlock()
+	}))
+	defer collector.Close()
+
+	sink := NewWebhookSink(config.WebhookConfig{
+		URL:                  collector.URL,
+		Headers:              map[string]string{"Authorization": "Bearer collector-token"},
+		BatchSize:            2,
+		FlushIntervalSeconds: 60,
+		QueueSize:            10,
+	}, collector.Client())
+	for _, subject := range []string{"alice", "bob", "carol"} {
+		if err := sink.Write(Record{Subject: subject, Method: "ping"}); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	// Closing posts the partial batch
+	sink.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(batches) != 2 || len(batches[0]) != 2 || len(batches[1]) != 1 || batches[1][0].Subject != "carol" {
+		t.Errorf("Expected batches of 2 and 1 records, got %+v", batches)
+	}
+	if err := sink.Write(Record{Subject: "dave"}); err == nil {
+		t.Errorf("Expected writes after Close to fail")
+	}
+}
+
+func TestWebhookSinkRetries(t *testing.T) {
+	defer func(backoff time.Duration) { webhookRetryBackoff = backoff }(webhookRetryBackoff)
+	webhookRetryBackoff = 10 * time.Millisecond
+
+	// The collector fails twice, then accepts
+	var attempts atomic.Int32
+	var mu sync.Mutex
+	var received []Record
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var batch []Record
+		json.NewDecoder(r.Body).Decode(&batch)
+		mu.Lock()
+		received = append(received, batch...)
+		mu.Unlock()
+	}))
+	defer collector.Close()
+
+	dropped := metrics.AuditRecordsDropped.Value(DropPostFailed)
+	sink := NewWebhookSink(config.WebhookConfig{URL: collector.URL, BatchSize: 1, FlushIntervalSeconds: 60, QueueSize: 10}, collector.Client())
+	sink.Write(Record{Subject: "alice", Method: "ping"})
+
+	deadline := time.Now().Add(2 * time.Second)
+	for attempts.Load() < 3 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	sink.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != 1 || received[0].Subject != "alice" {
+		t.Errorf("Expected the record to be posted after retries, got %+v", received)
+	}
+	if metrics.AuditRecordsDropped.Value(DropPostFailed) != dropped {
+		t.Errorf("Expected no records to be counted as dropped")
+	}
+}
+
+func TestWebhookSinkCountsLostRecords(t *testing.T) {
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer collector.Close()
+
+	dropped := metrics.AuditRecordsDropped.Value(DropPostFailed)
+	sink := NewWebhookSink(config.WebhookConfig{URL: collector.URL, BatchSize: 10, FlushIntervalSeconds: 60, QueueSize: 10}, collector.Client())
+	sink.Write(Record{Subject: "alice", Method: "ping"})
+	sink.Write(Record{Subject: "bob", Method: "ping"})
+
+	// Closing cuts the retries short
+	sink.Close()
+	if got := metrics.AuditRecordsDropped.Value(DropPostFailed) - dropped; got != 2 {
+		t.Errorf("Expected 2 lost records to be counted, got %v", got)
+	}
+}
@@ -0,0 +1,287 @@
+package authz
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// appTemplateData is the data available to Asgardeo application templates
+type appTemplateData struct {
+	AppName       string
+	ClientID      string
+	ClientSecret  string
+	ClientName    string
+	RedirectURIs  []string
+	GrantTypes    []string
+	ResponseTypes []string
+}
+
+// jsonLiteral is a value already rendered as JSON by the json template func
+type jsonLiteral string
+
+// valueFunc is appended to every action of an application template so each
+// printed value passes through it
+const valueFunc = "_appTemplateValue"
+
+var appTemplateFuncs = template.FuncMap{
+	// json renders a value as a JSON literal, e.g. {{ json .RedirectURIs }}
+	"json": func(v interface{}) (jsonLiteral, error) {
+		b, err := json.Marshal(v)
+		return jsonLiteral(b), err
+	},
+	"join":    strings.Join,
+	valueFunc: func(v interface{}) interface{} { return v },
+}
+
+// escapeJSONValue makes a printed value safe inside a JSON string. Values
+// from the json func are printed as they are.
+func escapeJSONValue(v interface{}) (string, error) {
+	if literal, ok := v.(jsonLiteral); ok {
+		return string(literal), nil
+	}
+	b, err := json.Marshal(fmt.Sprint(v))
+	if err != nil {
+		return "", err
+	}
+	return string(b[1 : len(b)-1]), nil
+}
+
+// parseAppTemplate parses an application template and appends valueFunc to
+// every action that prints a value, the way html/template adds its escapers
+func parseAppTemplate(name, text string) (*template.Template, error) {
+	tmpl, err := template.New(name).Funcs(appTemplateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil {
+			addValueFunc(t.Tree, t.Tree.Root)
+		}
+	}
+	return tmpl, nil
+}
+
+func addValueFunc(tree *parse.Tree, node parse.Node) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			addValueFunc(tree, child)
+		}
+	case *parse.ActionNode:
+		// Variable declarations print nothing
+		if len(n.Pipe.Decl) > 0 {
+			return
+		}
+		ident := parse.NewIdentifier(valueFunc).SetTree(tree).SetPos(n.Pos)
+		n.Pipe.Cmds = append(n.Pipe.Cmds, &parse.CommandNode{NodeType: parse.NodeCommand, Pos: n.Pos, Args: []parse.Node{ident}})
+	case *parse.IfNode:
+		addValueFunc(tree, n.List)
+		addValueFunc(tree, n.ElseList)
+	case *parse.RangeNode:
+		addValueFunc(tree, n.List)
+		addValueFunc(tree, n.ElseList)
+	case *parse.WithNode:
+		addValueFunc(tree, n.List)
+		addValueFunc(tree, n.ElseList)
+	}
+}
+
+func newAppTemplateData(regReq RegisterRequest, appName string) appTemplateData {
+	return appTemplateData{
+		AppName:       appName,
+		ClientID:      regReq.ClientID,
+		ClientSecret:  regReq.ClientSecret,
+		ClientName:    regReq.ClientName,
+		RedirectURIs:  regReq.RedirectURIs,
+		GrantTypes:    regReq.GrantTypes,
+		ResponseTypes: regReq.ResponseTypes,
+	}
+}
+
+// buildTemplatedPayload renders the application payload from either a YAML
+// sub-tree or a template file. It returns nil when neither is configured.
+func buildTemplatedPayload(regReq RegisterRequest, tree map[string]interface{}, file string) (map[string]interface{}, error) {
+	data := newAppTemplateData(regReq, applicationName(regReq))
+
+	var payload map[string]interface{}
+	switch {
+	case file != "":
+		rendered, err := renderTemplateFile(file, data)
+		if err != nil {
+			return nil, err
+		}
+		payload = rendered
+	case len(tree) > 0:
+		rendered, err := renderTemplateTree(normalizeYAML(tree), data)
+		if err != nil {
+			return nil, err
+		}
+		tree, ok := rendered.(map[string]interface{})
+		if !ok || tree == nil {
+			return nil, fmt.Errorf("application template did not render a map")
+		}
+		payload = tree
+	default:
+		return nil, nil
+	}
+
+	enforceRegistrationFields(payload, data)
+	return payload, nil
+}
+
+// renderTemplateFile executes a Go text/template file that must produce a JSON
+// object. Printed values are escaped for use inside JSON strings; use the
+// json func to print a JSON literal.
+func renderTemplateFile(file string, data appTemplateData) (map[string]interface{}, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read application template: %w", err)
+	}
+
+	tmpl, err := parseAppTemplate(file, string(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse application template: %w", err)
+	}
+	tmpl.Funcs(template.FuncMap{valueFunc: escapeJSONValue})
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("failed to render application template: %w", err)
+	}
+
+	var payload map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &payload); err != nil {
+		return nil, fmt.Errorf("application template did not render a JSON object: %w", err)
+	}
+	if payload == nil {
+		return nil, fmt.Errorf("application template did not render a JSON object")
+	}
+	return payload, nil
+}
+
+// renderTemplateTree walks a YAML sub-tree and renders every string leaf
+// that contains template actions. A leaf that only prints the json func,
+// like {{ json .RedirectURIs }}, becomes the JSON value; other leaves stay
+// strings.
+func renderTemplateTree(node interface{}, data appTemplateData) (interface{}, error) {
+	switch v := node.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, child := range v {
+			rendered, err := renderTemplateTree(child, data)
+			if err != nil {
+				return nil, err
+			}
+			out[key] = rendered
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, child := range v {
+			rendered, err := renderTemplateTree(child, data)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = rendered
+		}
+		return out, nil
+	case string:
+		if !strings.Contains(v, "{{") {
+			return v, nil
+		}
+		tmpl, err := parseAppTemplate("app_template", v)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse application template value %q: %w", v, err)
+		}
+		var printed []interface{}
+		tmpl.Funcs(template.FuncMap{valueFunc: func(value interface{}) interface{} {
+			printed = append(printed, value)
+			return value
+		}})
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return nil, fmt.Errorf("failed to render application template value %q: %w", v, err)
+		}
+		if len(printed) == 1 {
+			if literal, ok := printed[0].(jsonLiteral); ok && strings.TrimSpace(buf.String()) == string(literal) {
+				var value interface{}
+				if err := json.Unmarshal([]byte(literal), &value); err != nil {
+					return nil, fmt.Errorf("failed to decode application template value %q: %w", v, err)
+				}
+				return value, nil
+			}
+		}
+		return buf.String(), nil
+	default:
+		return v, nil
+	}
+}
+
+// normalizeYAML converts the map[interface{}]interface{} values produced by
+// the YAML decoder into JSON-compatible maps
+func normalizeYAML(node interface{}) interface{} {
+	switch v := node.(type) {
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, child := range v {
+			out[fmt.Sprint(key)] = normalizeYAML(child)
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, child := range v {
+			out[key] = normalizeYAML(child)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, child := range v {
+			out[i] = normalizeYAML(child)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// enforceRegistrationFields makes sure the issued credentials, redirect URIs
+// and the security settings the proxy relies on end up in the payload
+// regardless of what the template contains
+func enforceRegistrationFields(payload map[string]interface{}, data appTemplateData) {
+	if name, _ := payload["name"].(string); name == "" {
+		payload["name"] = data.AppName
+	}
+
+	oidc := childMap(childMap(payload, "inboundProtocolConfiguration"), "oidc")
+
+	oidc["clientId"] = data.ClientID
+	oidc["clientSecret"] = data.ClientSecret
+	oidc["callbackURLs"] = data.RedirectURIs
+	if len(data.GrantTypes) > 0 {
+		oidc["grantTypes"] = data.GrantTypes
+	}
+
+	// Clients authenticate with the issued secret and must use PKCE, and the
+	// proxy validates access tokens as JWTs
+	oidc["publicClient"] = false
+	childMap(oidc, "pkce")["mandatory"] = true
+	childMap(oidc, "accessToken")["type"] = "JWT"
+}
+
+// childMap returns the map stored under key, replacing any other value
+func childMap(parent map[string]interface{}, key string) map[string]interface{} {
+	child, ok := parent[key].(map[string]interface{})
+	if !ok {
+		child = map[string]interface{}{}
+		parent[key] = child
+	}
+	return child
+}
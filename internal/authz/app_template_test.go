@@ -0,0 +1,192 @@
+package authz
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func testRegisterRequest() RegisterRequest {
+	return RegisterRequest{
+		ClientID:     "client-abc",
+		ClientSecret: "secret-xyz",
+		ClientName:   "inspector",
+		RedirectURIs: []string{"http://localhost:6274/callback"},
+		GrantTypes:   []string{"authorization_code"},
+	}
+}
+
+func TestBuildTemplatedPayloadFromYAMLTree(t *testing.T) {
+	var section struct {
+		AppTemplate map[string]interface{} `yaml:"app_template"`
+	}
+	err := yaml.Unmarshal([]byte(`
+app_template:
+  name: "mcp-{{ .ClientName }}"
+  description: "{{ .ClientName }}: registered by the proxy"
+  templateId: custom-application-oidc
+  advancedConfigurations:
+    allowedOrigins: "{{ json .RedirectURIs }}"
+  inboundProtocolConfiguration:
+    oidc:
+      clientId: "ignored"
+      pkce:
+        mandatory: true
+        supportPlainTransformAlgorithm: false
+  authenticationSequence:
+    type: DEFAULT
+`), &section)
+	if err != nil {
+		t.Fatalf("Failed to parse YAML: %v", err)
+	}
+
+	payload, err := buildTemplatedPayload(testRegisterRequest(), section.AppTemplate, "")
+	if err != nil {
+		t.Fatalf("buildTemplatedPayload failed: %v", err)
+	}
+
+	// Only the json func renders lists and maps
+	regReq := testRegisterRequest()
+	regReq.ClientName = `{"publicClient": true}`
+	jsonNamed, err := buildTemplatedPayload(regReq, map[string]interface{}{"description": "{{ .ClientName }}"}, "")
+	if err != nil {
+		t.Fatalf("buildTemplatedPayload failed: %v", err)
+	}
+	if jsonNamed["description"] != regReq.ClientName {
+		t.Errorf("Expected a client name that looks like JSON to stay a string, got %#v", jsonNamed["description"])
+	}
+
+	if payload["name"] != "mcp-inspector" {
+		t.Errorf("Expected templated name mcp-inspector, got %v", payload["name"])
+	}
+	if payload["description"] != "inspector: registered by the proxy" {
+		t.Errorf("Expected the description to stay a string, got %v", payload["description"])
+	}
+	advanced := payload["advancedConfigurations"].(map[string]interface{})
+	if !reflect.DeepEqual(advanced["allowedOrigins"], []interface{}{"http://localhost:6274/callback"}) {
+		t.Errorf("Expected a JSON-rendered value to become a list, got %#v", advanced["allowedOrigins"])
+	}
+
+	oidc := payload["inboundProtocolConfiguration"].(map[string]interface{})["oidc"].(map[string]interface{})
+	if oidc["clientId"] != "client-abc" {
+		t.Errorf("Expected issued clientId to override template, got %v", oidc["clientId"])
+	}
+	if !reflect.DeepEqual(oidc["callbackURLs"], []string{"http://localhost:6274/callback"}) {
+		t.Errorf("Expected callbackURLs from the request, got %v", oidc["callbackURLs"])
+	}
+	pkce := oidc["pkce"].(map[string]interface{})
+	if pkce["supportPlainTransformAlgorithm"] != false {
+		t.Errorf("Expected template PKCE settings to be kept, got %v", pkce)
+	}
+}
+
+func TestBuildTemplatedPayloadFromFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "app.json.tmpl")
+	content := `{
+  "name": "{{ .AppName }}",
+  "templateId": "custom-application-oidc",
+  "inboundProtocolConfiguration": {
+    "oidc": {
+      "grantTypes": {{ json .GrantTypes }},
+      "callbackURLs": {{ json .RedirectURIs }}
+    }
+  }
+}`
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write template: %v", err)
+	}
+
+	payload, err := buildTemplatedPayload(testRegisterRequest(), nil, file)
+	if err != nil {
+		t.Fatalf("buildTemplatedPayload failed: %v", err)
+	}
+
+	if name, _ := payload["name"].(string); len(name) <= len("inspector-") {
+		t.Errorf("Expected generated application name, got %v", payload["name"])
+	}
+	oidc := payload["inboundProtocolConfiguration"].(map[string]interface{})["oidc"].(map[string]interface{})
+	if oidc["clientSecret"] != "secret-xyz" {
+		t.Errorf("Expected clientSecret to be injected, got %v", oidc["clientSecret"])
+	}
+	if _, ok := payload["authenticationSequence"]; ok {
+		t.Errorf("Expected no default authentication sequence in templated payload")
+	}
+}
+
+func TestBuildTemplatedPayloadEscapesValues(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "app.json.tmpl")
+	content := `{
+  "name": "{{ .ClientName }}",
+  "description": "{{ .ClientName }} at {{ join .RedirectURIs ", " }}",
+  "inboundProtocolConfiguration": {
+    "oidc": {
+      "publicClient": true,
+      "pkce": {"mandatory": false, "supportPlainTransformAlgorithm": false},
+      "accessToken": {"type": "Default", "userAccessTokenExpiryInSeconds": 600},
+      "allowedOrigins": {{ json .RedirectURIs }}
+    }
+  }
+}`
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write template: %v", err)
+	}
+
+	regReq := testRegisterRequest()
+	regReq.ClientName = `x","publicClient":true,"pkce":{"mandatory":false},"y":"`
+	regReq.RedirectURIs = []string{`http://localhost/cb","z":"`}
+	payload, err := buildTemplatedPayload(regReq, nil, file)
+	if err != nil {
+		t.Fatalf("buildTemplatedPayload failed: %v", err)
+	}
+
+	if payload["name"] != regReq.ClientName {
+		t.Errorf("Expected the client name to stay a string, got %v", payload["name"])
+	}
+	for _, key := range []string{"publicClient", "pkce", "y", "z"} {
+		if _, ok := payload[key]; ok {
+			t.Errorf("Expected no injected %s key, got %v", key, payload)
+		}
+	}
+
+	oidc := payload["inboundProtocolConfiguration"].(map[string]interface{})["oidc"].(map[string]interface{})
+	if !reflect.DeepEqual(oidc["allowedOrigins"], []interface{}{regReq.RedirectURIs[0]}) {
+		t.Errorf("Expected json to render a list, got %#v", oidc["allowedOrigins"])
+	}
+	if oidc["publicClient"] != false {
+		t.Errorf("Expected publicClient to be pinned, got %v", oidc["publicClient"])
+	}
+	pkce := oidc["pkce"].(map[string]interface{})
+	if pkce["mandatory"] != true || pkce["supportPlainTransformAlgorithm"] != false {
+		t.Errorf("Expected PKCE to be mandatory, got %v", pkce)
+	}
+	accessToken := oidc["accessToken"].(map[string]interface{})
+	if accessToken["type"] != "JWT" || accessToken["userAccessTokenExpiryInSeconds"] != float64(600) {
+		t.Errorf("Expected JWT access tokens, got %v", accessToken)
+	}
+}
+
+func TestBuildTemplatedPayloadErrors(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "bad.tmpl")
+	os.WriteFile(file, []byte(`{"name": {{ .Unknown }}}`), 0644)
+	if _, err := buildTemplatedPayload(testRegisterRequest(), nil, file); err == nil {
+		t.Errorf("Expected error for unknown template field")
+	}
+
+	os.WriteFile(file, []byte(`not json`), 0644)
+	if _, err := buildTemplatedPayload(testRegisterRequest(), nil, file); err == nil {
+		t.Errorf("Expected error for non-JSON template output")
+	}
+
+	os.WriteFile(file, []byte(`null`), 0644)
+	if _, err := buildTemplatedPayload(testRegisterRequest(), nil, file); err == nil {
+		t.Errorf("Expected error for a template rendering null")
+	}
+
+	payload, err := buildTemplatedPayload(testRegisterRequest(), nil, "")
+	if err != nil || payload != nil {
+		t.Errorf("Expected no payload without a template, got %v, %v", payload, err)
+	}
+}
@@ -2,9 +2,9 @@ package authz
 
 import (
 	"bytes"
-	"crypto/tls"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"math/rand"
@@ -13,17 +13,75 @@ import (
 	"time"
 
 	"github.com/wso2/open-mcp-auth-proxy/internal/config"
-	logger "github.com/wso2/open-mcp-auth-proxy/internal/logging"
+	"github.com/wso2/open-mcp-auth-proxy/internal/constants"
 	"github.com/wso2/open-mcp-auth-proxy/internal/util"
 )
 
 type asgardeoProvider struct {
-	cfg *config.Config
+	cfg        *config.Config
+	settings   config.AsgardeoConfig
+	adminToken *tokenCache
+	dpop       *dpopVerifier
+}
+
+func init() {
+	routes := func(cfg *config.Config) Routes {
+		return Routes{
+			Served:  []string{WellKnownPath, RegisterPath},
+			Proxied: []string{AuthorizePath, TokenPath},
+		}
+	}
+
+	Register(Definition{
+		Name:          "asgardeo",
+		ConfigSection: "asgardeo",
+		Configure: func(cfg *config.Config) error {
+			return configureAsgardeo(cfg, cfg.Asgardeo)
+		},
+		New: func(cfg *config.Config) (Provider, error) {
+			return NewAsgardeoProvider(cfg), nil
+		},
+		Routes: routes,
+	})
+
+	// Demo mode is Asgardeo backed by the shared sandbox organization
+	Register(Definition{
+		Name:          "demo",
+		ConfigSection: "demo",
+		Configure: func(cfg *config.Config) error {
+			return configureAsgardeo(cfg, config.AsgardeoConfig(cfg.Demo))
+		},
+		New: func(cfg *config.Config) (Provider, error) {
+			return newAsgardeoProvider(cfg, config.AsgardeoConfig(cfg.Demo)), nil
+		},
+		Routes: routes,
+	})
+}
+
+// asgardeoAPIBaseURL is where the application management API of each
+// organization is served; tests point it at a fake server
+var asgardeoAPIBaseURL = constants.ASGARDEO_BASE_URL
+
+// configureAsgardeo derives the OAuth2 and JWKS endpoints of the organization
+func configureAsgardeo(cfg *config.Config, settings config.AsgardeoConfig) error {
+	if settings.OrgName == "" {
+		return fmt.Errorf("org_name is required")
+	}
+	cfg.AuthServerBaseURL = constants.ASGARDEO_BASE_URL + settings.OrgName + "/oauth2"
+	cfg.JWKSURL = constants.ASGARDEO_BASE_URL + settings.OrgName + "/oauth2/jwks"
+	if cfg.TokenValidation.Issuer == "" {
+		cfg.TokenValidation.Issuer = cfg.AuthServerBaseURL + "/token"
+	}
+	return nil
 }
 
 // NewAsgardeoProvider initializes a Provider for Asgardeo.
 func NewAsgardeoProvider(cfg *config.Config) Provider {
-	return &asgardeoProvider{cfg: cfg}
+	return newAsgardeoProvider(cfg, cfg.Asgardeo)
+}
+
+func newAsgardeoProvider(cfg *config.Config, settings config.AsgardeoConfig) *asgardeoProvider {
+	return &asgardeoProvider{cfg: cfg, settings: settings, adminToken: newTokenCache(), dpop: newDPoPVerifier(cfg.DPoP)}
 }
 
 func (p *asgardeoProvider) WellKnownHandler() http.HandlerFunc {
@@ -45,7 +103,7 @@ func (p *asgardeoProvider) WellKnownHandler() http.HandlerFunc {
 
 		// Use the external base URL that clients can actually reach
 		baseURL := util.GetExternalBaseURL(r)
-		logger.Info("Using base URL for OAuth endpoints: %s", baseURL)
+		authzLog.Info("Using base URL for OAuth endpoints: %s", baseURL)
 
 		issuer := strings.TrimSuffix(p.cfg.AuthServerBaseURL, "/") + "/token"
 
@@ -60,16 +118,29 @@ func (p *asgardeoProvider) WellKnownHandler() http.HandlerFunc {
 			"registration_endpoint":                 baseURL + "/register",
 			"code_challenge_methods_supported":      []string{"plain", "S256"},
 		}
+		if algs := DPoPAlgorithms(p.cfg); algs != nil {
+			response["dpop_signing_alg_values_supported"] = algs
+		}
 
 		w.Header().Set("Content-Type", "application/json")
 		w.Header().Set("X-Accel-Buffering", "no")
 		if err := json.NewEncoder(w).Encode(response); err != nil {
-			logger.Error("Error encoding well-known: %v", err)
+			authzLog.Ctx(r.Context()).Error("Error encoding well-known: %v", err)
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 		}
 	}
 }
 
+// ValidateToken validates the JWT access token against the provider's JWKS
+func (p *asgardeoProvider) ValidateToken(r *http.Request) (*Principal, error) {
+	return validateJWTBearer(p, p.cfg.TokenValidation, p.dpop, r)
+}
+
+// ProtectedResourceMetadata advertises the proxy as the authorization server
+func (p *asgardeoProvider) ProtectedResourceMetadata(r *http.Request) *ResourceMetadata {
+	return defaultResourceMetadata(p.cfg, r)
+}
+
 func (p *asgardeoProvider) RegisterHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
@@ -89,12 +160,14 @@ func (p *asgardeoProvider) RegisterHandler() http.HandlerFunc {
 
 		var regReq RegisterRequest
 		if err := json.NewDecoder(r.Body).Decode(&regReq); err != nil {
-			logger.Error("Reading register request: %v", err)
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			authzLog.Ctx(r.Context()).Error("Reading register request: %v", err)
+			writeRegistrationError(w, newRegistrationError(http.StatusBadRequest,
+				errInvalidClientMetadata, "request body must be a JSON client metadata document"))
 			return
 		}
-		if len(regReq.RedirectURIs) == 0 {
-			http.Error(w, "redirect_uris is required", http.StatusBadRequest)
+		if err := validateRedirectURIs(regReq.RedirectURIs); err != nil {
+			authzLog.Ctx(r.Context()).Warn("Rejecting registration with invalid redirect URIs: %v", err)
+			writeRegistrationError(w, err)
 			return
 		}
 
@@ -103,9 +176,9 @@ func (p *asgardeoProvider) RegisterHandler() http.HandlerFunc {
 		regReq.ClientSecret = randomString(16)
 
 		if err := p.createAsgardeoApplication(regReq); err != nil {
-			logger.Warn("Asgardeo application creation failed: %v", err)
-			// Optionally http.Error(...) if you want to fail
-			// or continue to return partial data.
+			authzLog.Ctx(r.Context()).Error("Asgardeo application creation failed: %v", err)
+			writeRegistrationError(w, registrationErrorFromUpstream(err))
+			return
 		}
 
 		resp := RegisterResponse{
@@ -121,7 +194,7 @@ func (p *asgardeoProvider) RegisterHandler() http.HandlerFunc {
 		w.Header().Set("X-Accel-Buffering", "no")
 		w.WriteHeader(http.StatusCreated)
 		if err := json.NewEncoder(w).Encode(resp); err != nil {
-			logger.Error("Encoding /register response: %v", err)
+			authzLog.Ctx(r.Context()).Error("Encoding /register response: %v", err)
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 		}
 	}
@@ -151,19 +224,36 @@ type RegisterResponse struct {
 
 func (p *asgardeoProvider) createAsgardeoApplication(regReq RegisterRequest) error {
 
-	orgName := p.cfg.Demo.OrgName
-	if p.cfg.Mode == "asgardeo" {
-		orgName = p.cfg.Asgardeo.OrgName
+	body, err := p.buildApplicationPayload(regReq)
+	if err != nil {
+		return err
 	}
-
-	body := buildAsgardeoPayload(regReq)
 	reqBytes, err := json.Marshal(body)
 	if err != nil {
 		return fmt.Errorf("failed to marshal Asgardeo request: %w", err)
 	}
 
-	asgardeoAppURL := "https://api.asgardeo.io/t/" + orgName + "/api/server/v1/applications"
-	req, err := http.NewRequest("POST", asgardeoAppURL, bytes.NewBuffer(reqBytes))
+	asgardeoAppURL := asgardeoAPIBaseURL + p.settings.OrgName + "/api/server/v1/applications"
+
+	err = p.postApplication(asgardeoAppURL, reqBytes)
+	var apiErr *upstreamAPIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusUnauthorized {
+		// The cached admin token may have been revoked; retry once with a fresh one
+		authzLog.Info("Asgardeo rejected the cached admin token, requesting a new one")
+		p.adminToken.Invalidate()
+		err = p.postApplication(asgardeoAppURL, reqBytes)
+	}
+	if err != nil {
+		return err
+	}
+
+	authzLog.Info("Created Asgardeo application for clientID=%s", regReq.ClientID)
+	return nil
+}
+
+// postApplication sends an application creation request to Asgardeo
+func (p *asgardeoProvider) postApplication(appURL string, payload []byte) error {
+	req, err := http.NewRequest("POST", appURL, bytes.NewBuffer(payload))
 	if err != nil {
 		return fmt.Errorf("failed to create Asgardeo API request: %w", err)
 	}
@@ -175,8 +265,7 @@ func (p *asgardeoProvider) createAsgardeoApplication(regReq RegisterRequest) err
 	req.Header.Set("Authorization", "Bearer "+token)
 	req.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := util.OutboundClient().Do(req)
 	if err != nil {
 		return fmt.Errorf("asgardeo API call failed: %w", err)
 	}
@@ -184,21 +273,21 @@ func (p *asgardeoProvider) createAsgardeoApplication(regReq RegisterRequest) err
 
 	if resp.StatusCode >= 400 {
 		respBody, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("Asgardeo creation error (%d): %s", resp.StatusCode, string(respBody))
+		return &upstreamAPIError{Provider: "Asgardeo", StatusCode: resp.StatusCode, Body: string(respBody)}
 	}
-
-	logger.Info("Created Asgardeo application for clientID=%s", regReq.ClientID)
 	return nil
 }
 
+// getAsgardeoAdminToken returns a cached admin token, requesting a new one when needed
 func (p *asgardeoProvider) getAsgardeoAdminToken() (string, error) {
+	return p.adminToken.Get(p.requestAsgardeoAdminToken)
+}
 
-	clientId := p.cfg.Demo.ClientID
-	clientSecret := p.cfg.Demo.ClientSecret
-	if p.cfg.Mode == "asgardeo" {
-		clientId = p.cfg.Asgardeo.ClientID
-		clientSecret = p.cfg.Asgardeo.ClientSecret
-	}
+// requestAsgardeoAdminToken performs the client credentials grant for the admin application
+func (p *asgardeoProvider) requestAsgardeoAdminToken() (string, time.Duration, error) {
+
+	clientId := p.settings.ClientID
+	clientSecret := p.settings.ClientSecret
 
 	tokenURL := p.cfg.AuthServerBaseURL + "/token"
 
@@ -207,7 +296,7 @@ func (p *asgardeoProvider) getAsgardeoAdminToken() (string, error) {
 
 	req, err := http.NewRequest("POST", tokenURL, strings.NewReader(formData))
 	if err != nil {
-		return "", err
+		return "", 0, err
 	}
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
@@ -215,25 +304,17 @@ func (p *asgardeoProvider) getAsgardeoAdminToken() (string, error) {
 	auth := clientId + ":" + clientSecret
 	req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(auth)))
 
-	logger.Debug("Requesting admin token for Asgardeo with client ID: %s", clientId)
-
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-	}
-	client := &http.Client{
-		Timeout:   time.Duration(p.cfg.TimeoutSeconds) * time.Second,
-		Transport: tr,
-	}
+	authzLog.Debug("Requesting admin token for Asgardeo with client ID: %s", clientId)
 
-	resp, err := client.Do(req)
+	resp, err := util.OutboundClient().Do(req)
 	if err != nil {
-		return "", fmt.Errorf("token request failed: %w", err)
+		return "", 0, fmt.Errorf("token request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("token request failed (%d): %s", resp.StatusCode, string(body))
+		return "", 0, fmt.Errorf("token request failed (%d): %s", resp.StatusCode, string(body))
 	}
 
 	var tokenResp struct {
@@ -243,25 +324,40 @@ func (p *asgardeoProvider) getAsgardeoAdminToken() (string, error) {
 		Scope       string `json:"scope"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
-		return "", fmt.Errorf("failed to parse token JSON: %w", err)
+		return "", 0, fmt.Errorf("failed to parse token JSON: %w", err)
 	}
 
 	// Don't log the actual token at info level, only at debug level
-	logger.Debug("Received access token: %s", tokenResp.AccessToken)
-	logger.Info("Successfully obtained admin token from Asgardeo")
+	authzLog.Info("Successfully obtained admin token from Asgardeo (expires in %ds)", tokenResp.ExpiresIn)
 
-	return tokenResp.AccessToken, nil
+	return tokenResp.AccessToken, time.Duration(tokenResp.ExpiresIn) * time.Second, nil
 }
 
-func buildAsgardeoPayload(regReq RegisterRequest) map[string]interface{} {
+// buildApplicationPayload renders the configured application template,
+// falling back to the built-in payload when none is configured
+func (p *asgardeoProvider) buildApplicationPayload(regReq RegisterRequest) (map[string]interface{}, error) {
+	payload, err := buildTemplatedPayload(regReq, p.settings.AppTemplate, p.settings.AppTemplateFile)
+	if err != nil {
+		return nil, err
+	}
+	if payload == nil {
+		payload = buildAsgardeoPayload(regReq)
+	}
+	return payload, nil
+}
+
+// applicationName derives a unique Asgardeo application name for a registration
+func applicationName(regReq RegisterRequest) string {
 	appName := regReq.ClientName
 	if appName == "" {
 		appName = "demo-app"
 	}
-	appName += "-" + randomString(5)
+	return appName + "-" + randomString(5)
+}
 
+func buildAsgardeoPayload(regReq RegisterRequest) map[string]interface{} {
 	return map[string]interface{}{
-		"name":       appName,
+		"name":       applicationName(regReq),
 		"templateId": "custom-application-oidc",
 		"inboundProtocolConfiguration": map[string]interface{}{
 			"oidc": map[string]interface{}{
@@ -0,0 +1,242 @@
+package authz
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/wso2/open-mcp-auth-proxy/internal/config"
+)
+
+// fakeAsgardeo is a fake Asgardeo organization whose application
+// management API answers with a fixed status and body.
+type fakeAsgardeo struct {
+	*httptest.Server
+	tokenCalls int32
+	appCalls   int32
+}
+
+func startFakeAsgardeo(t *testing.T, appStatus int, appBody string) *fakeAsgardeo {
+	fake := &fakeAsgardeo{}
+	fake.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/t/testorg/oauth2/token":
+			atomic.AddInt32(&fake.tokenCalls, 1)
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"access_token":"admin-token","token_type":"Bearer","expires_in":3600}`))
+		case "/t/testorg/api/server/v1/applications":
+			atomic.AddInt32(&fake.appCalls, 1)
+			if r.Header.Get("Authorization") != "Bearer admin-token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.WriteHeader(appStatus)
+			w.Write([]byte(appBody))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(fake.Close)
+
+	apiBaseURL := asgardeoAPIBaseURL
+	asgardeoAPIBaseURL = fake.URL + "/t/"
+	t.Cleanup(func() { asgardeoAPIBaseURL = apiBaseURL })
+	return fake
+}
+
+func newTestAsgardeoProvider(serverURL string) Provider {
+	cfg := &config.Config{
+		Provider:          "asgardeo",
+		AuthServerBaseURL: serverURL + "/t/testorg/oauth2",
+		TimeoutSeconds:    5,
+		Asgardeo: config.AsgardeoConfig{
+			OrgName:      "testorg",
+			ClientID:     "admin-client",
+			ClientSecret: "admin-secret",
+		},
+	}
+	return NewAsgardeoProvider(cfg)
+}
+
+func doRegister(t *testing.T, provider Provider, body string) (*httptest.ResponseRecorder, map[string]interface{}) {
+	req := httptest.NewRequest("POST", "/register", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	provider.RegisterHandler()(w, req)
+
+	var response map[string]interface{}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response JSON: %v", err)
+	}
+	return w, response
+}
+
+func TestAsgardeoRegisterSuccess(t *testing.T) {
+	fake := startFakeAsgardeo(t, http.StatusCreated, `{}`)
+	provider := newTestAsgardeoProvider(fake.URL)
+
+	w, response := doRegister(t, provider, `{"client_name":"app","redirect_uris":["http://127.0.0.1:6274/callback"]}`)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status Created, got %v", w.Code)
+	}
+	if id, _ := response["client_id"].(string); !strings.HasPrefix(id, "client-") {
+		t.Errorf("Expected generated client_id, got %v", response["client_id"])
+	}
+}
+
+func TestAsgardeoRegisterUpstreamFailures(t *testing.T) {
+	tests := []struct {
+		name           string
+		appStatus      int
+		appBody        string
+		expectedStatus int
+		expectedError  string
+	}{
+		{
+			name:           "Rejected callback URL",
+			appStatus:      http.StatusBadRequest,
+			appBody:        `{"code":"APP-60001","message":"Invalid request.","description":"Invalid callback URL provided."}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  errInvalidRedirectURI,
+		},
+		{
+			name:           "Rejected metadata",
+			appStatus:      http.StatusConflict,
+			appBody:        `{"code":"APP-60007","message":"Conflict.","description":"Application name already exists."}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  errInvalidClientMetadata,
+		},
+		{
+			name:           "Upstream outage",
+			appStatus:      http.StatusInternalServerError,
+			appBody:        `oops`,
+			expectedStatus: http.StatusBadGateway,
+			expectedError:  errServerError,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := startFakeAsgardeo(t, tc.appStatus, tc.appBody)
+			provider := newTestAsgardeoProvider(fake.URL)
+
+			w, response := doRegister(t, provider, `{"client_name":"app","redirect_uris":["https://app.example.com/cb"]}`)
+
+			if w.Code != tc.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tc.expectedStatus, w.Code)
+			}
+			if response["error"] != tc.expectedError {
+				t.Errorf("Expected error=%s, got %v", tc.expectedError, response["error"])
+			}
+			if _, ok := response["client_secret"]; ok {
+				t.Errorf("Expected no client_secret in error response")
+			}
+		})
+	}
+}
+
+func TestAsgardeoRegisterRejectsRedirectURIsBeforeUpstream(t *testing.T) {
+	fake := startFakeAsgardeo(t, http.StatusCreated, `{}`)
+	provider := newTestAsgardeoProvider(fake.URL)
+
+	w, response := doRegister(t, provider, `{"client_name":"app","redirect_uris":["http://evil.example.com/cb"]}`)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status BadRequest, got %v", w.Code)
+	}
+	if response["error"] != errInvalidRedirectURI {
+		t.Errorf("Expected error=%s, got %v", errInvalidRedirectURI, response["error"])
+	}
+	if atomic.LoadInt32(&fake.appCalls) != 0 {
+		t.Errorf("Expected Asgardeo not to be called for invalid redirect URIs")
+	}
+}
+
+func TestValidateRedirectURIs(t *testing.T) {
+	tests := []struct {
+		name        string
+		uris        []string
+		expectError bool
+	}{
+		{name: "HTTPS URI", uris: []string{"https://app.example.com/callback"}},
+		{name: "Loopback IPv4", uris: []string{"http://127.0.0.1:6274/callback"}},
+		{name: "Loopback IPv6", uris: []string{"http://[::1]:8080/callback"}},
+		{name: "Localhost", uris: []string{"http://localhost:5173/oauth/callback"}},
+		{name: "Empty list", uris: nil, expectError: true},
+		{name: "Plain HTTP remote", uris: []string{"http://app.example.com/callback"}, expectError: true},
+		{name: "Fragment", uris: []string{"https://app.example.com/callback#frag"}, expectError: true},
+		{name: "Relative", uris: []string{"/callback"}, expectError: true},
+		{name: "Custom scheme", uris: []string{"myapp://callback"}, expectError: true},
+		{name: "One bad URI", uris: []string{"https://ok.example.com/cb", "http://bad.example.com/cb"}, expectError: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateRedirectURIs(tc.uris)
+			if tc.expectError && err == nil {
+				t.Errorf("Expected error but got none")
+			}
+			if !tc.expectError && err != nil {
+				t.Errorf("Expected no error but got: %v", err)
+			}
+			if err != nil && err.Code != errInvalidRedirectURI {
+				t.Errorf("Expected error code %s, got %s", errInvalidRedirectURI, err.Code)
+			}
+		})
+	}
+}
+
+func TestAsgardeoAdminTokenIsReused(t *testing.T) {
+	fake := startFakeAsgardeo(t, http.StatusCreated, `{}`)
+	provider := newTestAsgardeoProvider(fake.URL)
+
+	for i := 0; i < 3; i++ {
+		w, _ := doRegister(t, provider, `{"client_name":"app","redirect_uris":["https://app.example.com/cb"]}`)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Expected status Created, got %v", w.Code)
+		}
+	}
+
+	if calls := atomic.LoadInt32(&fake.tokenCalls); calls != 1 {
+		t.Errorf("Expected a single admin token request, got %d", calls)
+	}
+}
+
+func TestTokenCacheRefreshesBeforeExpiry(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	cache := newTokenCache()
+	cache.now = func() time.Time { return now }
+
+	fetches := 0
+	fetch := func() (string, time.Duration, error) {
+		fetches++
+		return fmt.Sprintf("token-%d", fetches), 10 * time.Minute, nil
+	}
+
+	if token, _ := cache.Get(fetch); token != "token-1" {
+		t.Fatalf("Expected token-1, got %s", token)
+	}
+
+	// Still well within the lifetime
+	now = now.Add(8 * time.Minute)
+	if token, _ := cache.Get(fetch); token != "token-1" {
+		t.Errorf("Expected cached token-1, got %s", token)
+	}
+
+	// Inside the refresh window, one minute before expiry
+	now = now.Add(90 * time.Second)
+	if token, _ := cache.Get(fetch); token != "token-2" {
+		t.Errorf("Expected refreshed token-2, got %s", token)
+	}
+
+	cache.Invalidate()
+	if token, _ := cache.Get(fetch); token != "token-3" {
+		t.Errorf("Expected token-3 after invalidation, got %s", token)
+	}
+}
@@ -5,17 +5,47 @@ import (
 	"net/http"
 
 	"github.com/wso2/open-mcp-auth-proxy/internal/config"
-	logger "github.com/wso2/open-mcp-auth-proxy/internal/logging"
 	"github.com/wso2/open-mcp-auth-proxy/internal/util"
 )
 
 type defaultProvider struct {
-	cfg *config.Config
+	cfg  *config.Config
+	dpop *dpopVerifier
+}
+
+func init() {
+	Register(Definition{
+		Name:          DefaultProviderName,
+		ConfigSection: "default",
+		Configure: func(cfg *config.Config) error {
+			cfg.JWKSURL = cfg.Default.JWKSURL
+			cfg.AuthServerBaseURL = cfg.Default.BaseURL
+			return nil
+		},
+		New: func(cfg *config.Config) (Provider, error) {
+			return NewDefaultProvider(cfg), nil
+		},
+		Routes: defaultRoutes,
+	})
+}
+
+// defaultRoutes serves the well-known document only when a custom
+// response is configured and proxies everything else
+func defaultRoutes(cfg *config.Config) Routes {
+	if pathConfig, exists := cfg.Default.Path[WellKnownPath]; exists && pathConfig.Response != nil {
+		return Routes{
+			Served:  []string{WellKnownPath},
+			Proxied: []string{AuthorizePath, TokenPath, RegisterPath},
+		}
+	}
+	return Routes{
+		Proxied: []string{AuthorizePath, TokenPath, RegisterPath, WellKnownPath},
+	}
 }
 
 // NewDefaultProvider initializes a Provider for default OAuth providers.
 func NewDefaultProvider(cfg *config.Config) Provider {
-	return &defaultProvider{cfg: cfg}
+	return &defaultProvider{cfg: cfg, dpop: newDPoPVerifier(cfg.DPoP)}
 }
 
 func (p *defaultProvider) WellKnownHandler() http.HandlerFunc {
@@ -43,7 +73,7 @@ func (p *defaultProvider) WellKnownHandler() http.HandlerFunc {
 
 				// Get current base URL using EXTERNAL_HOST priority
 				baseURL := util.GetExternalBaseURL(r)
-				logger.Info("Using base URL for OAuth endpoints: %s", baseURL)
+				authzLog.Info("Using base URL for OAuth endpoints: %s", baseURL)
 
 				authorizationEndpoint := responseConfig.AuthorizationEndpoint
 				if authorizationEndpoint == "" {
@@ -70,10 +100,13 @@ func (p *defaultProvider) WellKnownHandler() http.HandlerFunc {
 					"registration_endpoint":                 registrationEndpoint,
 					"code_challenge_methods_supported":      responseConfig.CodeChallengeMethodsSupported,
 				}
+				if algs := DPoPAlgorithms(p.cfg); algs != nil {
+					response["dpop_signing_alg_values_supported"] = algs
+				}
 
 				w.Header().Set("Content-Type", "application/json")
 				if err := json.NewEncoder(w).Encode(response); err != nil {
-					logger.Error("Error encoding well-known response: %v", err)
+					authzLog.Ctx(r.Context()).Error("Error encoding well-known response: %v", err)
 					http.Error(w, "Internal server error", http.StatusInternalServerError)
 				}
 				return
@@ -82,6 +115,16 @@ func (p *defaultProvider) WellKnownHandler() http.HandlerFunc {
 	}
 }
 
+// ValidateToken validates the JWT access token against the provider's JWKS
+func (p *defaultProvider) ValidateToken(r *http.Request) (*Principal, error) {
+	return validateJWTBearer(p, p.cfg.TokenValidation, p.dpop, r)
+}
+
+// ProtectedResourceMetadata advertises the proxy as the authorization server
+func (p *defaultProvider) ProtectedResourceMetadata(r *http.Request) *ResourceMetadata {
+	return defaultResourceMetadata(p.cfg, r)
+}
+
 func (p *defaultProvider) RegisterHandler() http.HandlerFunc {
 	return nil
 }
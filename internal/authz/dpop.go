@@ -0,0 +1,353 @@
+package authz
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/wso2/open-mcp-auth-proxy/internal/config"
+	"github.com/wso2/open-mcp-auth-proxy/internal/util"
+)
+
+// DPoPHeader carries the DPoP proof JWT
+const DPoPHeader = "DPoP"
+
+// dpopVerifier checks DPoP proofs (RFC 9449) for sender-constrained tokens
+type dpopVerifier struct {
+	cfg    config.DPoPConfig
+	replay *replayCache
+	now    func() time.Time
+}
+
+// newDPoPVerifier returns nil when DPoP is disabled
+func newDPoPVerifier(cfg config.DPoPConfig) *dpopVerifier {
+	if !cfg.Enabled {
+		return nil
+	}
+	return &dpopVerifier{
+		cfg:    cfg,
+		replay: newReplayCache(cfg.ReplayCacheSize),
+		now:    time.Now,
+	}
+}
+
+// checkBinding enforces DPoP for a validated access token. Tokens bound with
+// cnf.jkt must come with the DPoP scheme and a proof signed by that key.
+func (v *dpopVerifier) checkBinding(r *http.Request, scheme, token string, claims map[string]interface{}) error {
+	jkt := confirmation(claims, "jkt")
+
+	if v == nil {
+		if scheme == DPoPHeader || jkt != "" {
+			return errors.New("DPoP-bound tokens are not accepted")
+		}
+		return nil
+	}
+
+	if scheme != DPoPHeader {
+		if jkt != "" {
+			return errors.New("DPoP-bound token presented as a bearer token")
+		}
+		if v.cfg.Required {
+			return errors.New("DPoP-bound token required")
+		}
+		return nil
+	}
+	if jkt == "" {
+		return errors.New("DPoP scheme used with a token that isn't DPoP-bound")
+	}
+
+	thumbprint, err := v.verifyProof(r, token)
+	if err != nil {
+		return fmt.Errorf("invalid DPoP proof: %w", err)
+	}
+	if thumbprint != jkt {
+		return errors.New("DPoP proof key does not match the token's cnf.jkt")
+	}
+	return nil
+}
+
+// verifyProof validates the request's DPoP proof and returns the JWK thumbprint of its key
+func (v *dpopVerifier) verifyProof(r *http.Request, accessToken string) (string, error) {
+	proofs := r.Header.Values(DPoPHeader)
+	if len(proofs) != 1 {
+		return "", errors.New("exactly one DPoP header is required")
+	}
+
+	var thumbprint string
+	claims := jwt.MapClaims{}
+	parser := jwt.NewParser(jwt.WithValidMethods(v.cfg.Algorithms), jwt.WithoutClaimsValidation())
+	_, err := parser.ParseWithClaims(proofs[0], claims, func(token *jwt.Token) (interface{}, error) {
+		if typ, _ := token.Header["typ"].(string); typ != "dpop+jwt" {
+			return nil, errors.New("typ must be dpop+jwt")
+		}
+		jwk, ok := token.Header["jwk"].(map[string]interface{})
+		if !ok {
+			return nil, errors.New("missing jwk header")
+		}
+		key, err := publicKeyFromJWK(jwk)
+		if err != nil {
+			return nil, err
+		}
+		thumbprint, err = jwkThumbprint(jwk)
+		return key, err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if htm, _ := claims["htm"].(string); htm != r.Method {
+		return "", fmt.Errorf("htm %q does not match %s", htm, r.Method)
+	}
+	htu, _ := claims["htu"].(string)
+	if !sameHTU(htu, util.GetExternalBaseURL(r)+r.URL.Path) {
+		return "", fmt.Errorf("htu %q does not match the request URL", htu)
+	}
+
+	iat, ok := claims["iat"].(float64)
+	if !ok {
+		return "", errors.New("missing iat")
+	}
+	maxAge := time.Duration(v.cfg.ProofMaxAgeSeconds) * time.Second
+	now := v.now()
+	issuedAt := time.Unix(int64(iat), 0)
+	if issuedAt.Before(now.Add(-maxAge)) || issuedAt.After(now.Add(maxAge)) {
+		return "", errors.New("iat is outside the accepted window")
+	}
+
+	hash := sha256.Sum256([]byte(accessToken))
+	if ath, _ := claims["ath"].(string); ath != base64.RawURLEncoding.EncodeToString(hash[:]) {
+		return "", errors.New("ath does not match the access token")
+	}
+
+	jti, _ := claims["jti"].(string)
+	if jti == "" {
+		return "", errors.New("missing jti")
+	}
+	// Proofs are remembered for as long as their iat is accepted
+	if err := v.replay.Add(thumbprint+":"+jti, issuedAt.Add(maxAge), now); err != nil {
+		return "", err
+	}
+	return thumbprint, nil
+}
+
+// sameHTU compares htu with the request URL, ignoring query, fragment and case of scheme and host
+func sameHTU(htu, requestURL string) bool {
+	normalize := func(u string) string {
+		if i := strings.IndexAny(u, "?#"); i >= 0 {
+			u = u[:i]
+		}
+		if i := strings.Index(u, "://"); i >= 0 {
+			rest := u[i+3:]
+			host, path := rest, ""
+			if j := strings.Index(rest, "/"); j >= 0 {
+				host, path = rest[:j], rest[j:]
+			}
+			return strings.ToLower(u[:i]) + "://" + strings.ToLower(host) + path
+		}
+		return u
+	}
+	return htu != "" && normalize(htu) == normalize(requestURL)
+}
+
+// confirmation returns a member of the token's cnf claim (RFC 7800)
+func confirmation(claims map[string]interface{}, member string) string {
+	cnf, _ := claims["cnf"].(map[string]interface{})
+	value, _ := cnf[member].(string)
+	return value
+}
+
+// publicKeyFromJWK parses an RSA, EC or Ed25519 public JWK
+func publicKeyFromJWK(jwk map[string]interface{}) (crypto.PublicKey, error) {
+	for _, private := range []string{"d", "p", "q", "dp", "dq", "qi"} {
+		if _, ok := jwk[private]; ok {
+			return nil, errors.New("jwk must not contain private key members")
+		}
+	}
+
+	member := func(name string) ([]byte, error) {
+		value, _ := jwk[name].(string)
+		if value == "" {
+			return nil, fmt.Errorf("jwk is missing %s", name)
+		}
+		return base64.RawURLEncoding.DecodeString(value)
+	}
+
+	switch jwk["kty"] {
+	case "RSA":
+		n, err := member("n")
+		if err != nil {
+			return nil, err
+		}
+		e, err := member("e")
+		if err != nil {
+			return nil, err
+		}
+		// Larger exponents would overflow; small or even ones are insecure
+		if len(e) > 4 {
+			return nil, errors.New("jwk exponent is too large")
+		}
+		exponent := 0
+		for _, b := range e {
+			exponent = exponent<<8 + int(b)
+		}
+		if exponent < 3 || exponent%2 == 0 {
+			return nil, errors.New("jwk exponent must be odd and at least 3")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: exponent}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk["crv"] {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %v", jwk["crv"])
+		}
+		x, err := member("x")
+		if err != nil {
+			return nil, err
+		}
+		y, err := member("y")
+		if err != nil {
+			return nil, err
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(key.X, key.Y) {
+			return nil, errors.New("jwk point is not on the curve")
+		}
+		return key, nil
+	case "OKP":
+		if jwk["crv"] != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %v", jwk["crv"])
+		}
+		x, err := member("x")
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key size")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("unsupported key type %v", jwk["kty"])
+}
+
+// jwkThumbprint computes the RFC 7638 SHA-256 thumbprint of a public JWK
+func jwkThumbprint(jwk map[string]interface{}) (string, error) {
+	var required []string
+	switch jwk["kty"] {
+	case "RSA":
+		required = []string{"e", "kty", "n"}
+	case "EC":
+		required = []string{"crv", "kty", "x", "y"}
+	case "OKP":
+		required = []string{"crv", "kty", "x"}
+	default:
+		return "", fmt.Errorf("unsupported key type %v", jwk["kty"])
+	}
+
+	// Required members only, in lexicographic order, without whitespace
+	var b strings.Builder
+	b.WriteString("{")
+	for i, name := range required {
+		value, _ := jwk[name].(string)
+		encoded, _ := json.Marshal(value)
+		if i > 0 {
+			b.WriteString(",")
+		}
+		fmt.Fprintf(&b, "%q:%s", name, encoded)
+	}
+	b.WriteString("}")
+
+	sum := sha256.Sum256([]byte(b.String()))
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// replayCache remembers proof IDs until they expire, holding at most size entries
+type replayCache struct {
+	mu    sync.Mutex
+	size  int
+	seen  map[string]time.Time
+	order []replayEntry // Insertion order, oldest first
+}
+
+type replayEntry struct {
+	id        string
+	expiresAt time.Time
+}
+
+func newReplayCache(size int) *replayCache {
+	return &replayCache{size: size, seen: make(map[string]time.Time)}
+}
+
+// Errors of replayCache.Add
+var (
+	errReplayedProof   = errors.New("replayed jti")
+	errReplayCacheFull = errors.New("too many unexpired DPoP proofs")
+)
+
+// Add records id until expiresAt. It fails if id was already seen, or if the
+// cache holds size unexpired entries: evicting them would let them be replayed.
+func (c *replayCache) Add(id string, expiresAt, now time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if expiry, ok := c.seen[id]; ok && now.Before(expiry) {
+		return errReplayedProof
+	}
+
+	// Drop expired entries from the front, then every expired entry while full
+	for len(c.order) > 0 && !now.Before(c.order[0].expiresAt) {
+		c.forget(c.order[0])
+		c.order = c.order[1:]
+	}
+	if len(c.order) >= c.size {
+		live := c.order[:0]
+		for _, entry := range c.order {
+			if now.Before(entry.expiresAt) {
+				live = append(live, entry)
+			} else {
+				c.forget(entry)
+			}
+		}
+		c.order = live
+	}
+	if len(c.order) >= c.size {
+		return errReplayCacheFull
+	}
+
+	c.seen[id] = expiresAt
+	c.order = append(c.order, replayEntry{id: id, expiresAt: expiresAt})
+	return nil
+}
+
+// forget removes entry unless its id was recorded again since. Callers hold c.mu.
+func (c *replayCache) forget(entry replayEntry) {
+	if c.seen[entry.id].Equal(entry.expiresAt) {
+		delete(c.seen, entry.id)
+	}
+}
+
+// DPoPAlgorithms returns the proof algorithms to advertise, or nil when DPoP is disabled
+func DPoPAlgorithms(cfg *config.Config) []string {
+	if !cfg.DPoP.Enabled {
+		return nil
+	}
+	return cfg.DPoP.Algorithms
+}
@@ -0,0 +1,202 @@
+package authz
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/wso2/open-mcp-auth-proxy/internal/config"
+)
+
+// dpopTestKey is a client key with its public JWK and thumbprint
+type dpopTestKey struct {
+	private    *ecdsa.PrivateKey
+	jwk        map[string]interface{}
+	thumbprint string
+}
+
+func newDPoPTestKey(t *testing.T) *dpopTestKey {
+	private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate EC key: %v", err)
+	}
+	jwk := map[string]interface{}{
+		"kty": "EC",
+		"crv": "P-256",
+		"x":   base64.RawURLEncoding.EncodeToString(private.X.FillBytes(make([]byte, 32))),
+		"y":   base64.RawURLEncoding.EncodeToString(private.Y.FillBytes(make([]byte, 32))),
+	}
+	thumbprint, err := jwkThumbprint(jwk)
+	if err != nil {
+		t.Fatalf("Failed to compute thumbprint: %v", err)
+	}
+	return &dpopTestKey{private: private, jwk: jwk, thumbprint: thumbprint}
+}
+
+func (k *dpopTestKey) proof(t *testing.T, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["typ"] = "dpop+jwt"
+	token.Header["jwk"] = k.jwk
+	signed, err := token.SignedString(k.private)
+	if err != nil {
+		t.Fatalf("Failed to sign proof: %v", err)
+	}
+	return signed
+}
+
+func TestJWKThumbprint(t *testing.T) {
+	// Example from RFC 7638, section 3.1
+	jwk := map[string]interface{}{
+		"kty": "RSA",
+		"n":   "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",
+		"e":   "AQAB",
+		"alg": "RS256",
+		"kid": "2011-04-29",
+	}
+	thumbprint, err := jwkThumbprint(jwk)
+	if err != nil || thumbprint != "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs" {
+		t.Errorf("Unexpected thumbprint %q (%v)", thumbprint, err)
+	}
+}
+
+func TestPublicKeyFromRSAJWK(t *testing.T) {
+	n := base64.RawURLEncoding.EncodeToString(make([]byte, 256))
+	tests := []struct {
+		name  string
+		e     []byte
+		valid bool
+	}{
+		{"65537", []byte{1, 0, 1}, true},
+		{"3", []byte{3}, true},
+		{"Exponent 1", []byte{1}, false},
+		{"Even exponent", []byte{1, 0, 0}, false},
+		{"Longer than 4 bytes", []byte{1, 0, 0, 0, 1}, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := publicKeyFromJWK(map[string]interface{}{"kty": "RSA", "n": n, "e": base64.RawURLEncoding.EncodeToString(tc.e)})
+			if tc.valid && err != nil {
+				t.Errorf("Expected the key to be accepted, got %v", err)
+			}
+			if !tc.valid && err == nil {
+				t.Errorf("Expected the key to be rejected")
+			}
+		})
+	}
+}
+
+func TestValidateDPoPBoundToken(t *testing.T) {
+	signingKey := loadTestJWKS(t)
+	client := newDPoPTestKey(t)
+	attacker := newDPoPTestKey(t)
+
+	cfg := &config.Config{DPoP: config.DPoPConfig{Enabled: true}}
+	cfg.Validate()
+	provider := NewDefaultProvider(cfg)
+
+	token := signTestToken(t, signingKey, jwt.MapClaims{
+		"sub": "user-123",
+		"exp": time.Now().Add(time.Hour).Unix(),
+		"cnf": map[string]interface{}{"jkt": client.thumbprint},
+	})
+	tokenHash := sha256.Sum256([]byte(token))
+	ath := base64.RawURLEncoding.EncodeToString(tokenHash[:])
+
+	proofClaims := func(jti string) jwt.MapClaims {
+		return jwt.MapClaims{"htm": "POST", "htu": "http://example.com/messages", "iat": time.Now().Unix(), "jti": jti, "ath": ath}
+	}
+	with := func(claims jwt.MapClaims, key, value string) jwt.MapClaims {
+		claims[key] = value
+		return claims
+	}
+
+	tests := []struct {
+		name    string
+		scheme  string
+		proof   string
+		wantErr string
+	}{
+		{"Valid proof", "DPoP", client.proof(t, proofClaims("jti-1")), ""},
+		{"Replayed proof", "DPoP", client.proof(t, proofClaims("jti-1")), "replayed jti"},
+		{"Bound token as bearer", "Bearer", "", "presented as a bearer token"},
+		{"Missing proof", "DPoP", "", "exactly one DPoP header"},
+		{"Other key", "DPoP", attacker.proof(t, proofClaims("jti-2")), "does not match the token's cnf.jkt"},
+		{"Wrong method", "DPoP", client.proof(t, with(proofClaims("jti-3"), "htm", "GET")), "htm"},
+		{"Wrong URL", "DPoP", client.proof(t, with(proofClaims("jti-4"), "htu", "http://example.com/sse")), "htu"},
+		{"Wrong token hash", "DPoP", client.proof(t, with(proofClaims("jti-5"), "ath", "bogus")), "ath"},
+		{"Stale proof", "DPoP", client.proof(t, jwt.MapClaims{"htm": "POST", "htu": "http://example.com/messages", "iat": time.Now().Add(-time.Hour).Unix(), "jti": "jti-6", "ath": ath}), "iat"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/messages?sessionId=abc", nil)
+			req.Header.Set("Authorization", tc.scheme+" "+token)
+			if tc.proof != "" {
+				req.Header.Set("DPoP", tc.proof)
+			}
+
+			principal, err := provider.ValidateToken(req)
+			if tc.wantErr == "" {
+				if err != nil || principal.Subject != "user-123" {
+					t.Errorf("Expected valid DPoP request, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestDPoPRequired(t *testing.T) {
+	signingKey := loadTestJWKS(t)
+	token := signTestToken(t, signingKey, jwt.MapClaims{"sub": "user-123", "exp": time.Now().Add(time.Hour).Unix()})
+
+	cfg := &config.Config{DPoP: config.DPoPConfig{Enabled: true, Required: true}}
+	cfg.Validate()
+	provider := NewDefaultProvider(cfg)
+
+	req := httptest.NewRequest("GET", "/sse", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	if _, err := provider.ValidateToken(req); err == nil {
+		t.Errorf("Expected plain bearer tokens to be rejected when DPoP is required")
+	}
+
+	metadata := provider.ProtectedResourceMetadata(req)
+	if !metadata.DPoPBoundAccessTokensRequired || len(metadata.DPoPSigningAlgValuesSupported) == 0 {
+		t.Errorf("Expected DPoP to be advertised, got %+v", metadata)
+	}
+}
+
+func TestReplayCacheBounded(t *testing.T) {
+	cache := newReplayCache(2)
+	now := time.Now()
+	expiry := now.Add(time.Minute)
+
+	if cache.Add("a", expiry, now) != nil || cache.Add("b", expiry, now) != nil || cache.Add("a", expiry, now) != errReplayedProof {
+		t.Fatalf("Expected first uses to be accepted and the repeat rejected")
+	}
+
+	// Full of unexpired proofs: new ones are rejected rather than evicting them
+	if err := cache.Add("c", expiry, now); err != errReplayCacheFull {
+		t.Errorf("Expected %v, got %v", errReplayCacheFull, err)
+	}
+	if len(cache.seen) != 2 || cache.Add("a", expiry, now) != errReplayedProof {
+		t.Errorf("Expected the cache to keep its 2 entries, got %d", len(cache.seen))
+	}
+
+	// Expired entries make room and may be used again
+	later := expiry.Add(time.Second)
+	if cache.Add("c", later.Add(time.Minute), later) != nil || cache.Add("a", later.Add(time.Minute), later) != nil {
+		t.Errorf("Expected expired entries to make room")
+	}
+}
@@ -0,0 +1,429 @@
+package authz
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/wso2/open-mcp-auth-proxy/internal/config"
+	"github.com/wso2/open-mcp-auth-proxy/internal/util"
+)
+
+// Keycloak client registration modes
+const (
+	KeycloakRegistrationAdmin = "admin"
+	KeycloakRegistrationDCR   = "dcr"
+	KeycloakRegistrationNone  = "none"
+)
+
+type keycloakProvider struct {
+	cfg        *config.Config
+	adminToken *tokenCache
+	dpop       *dpopVerifier
+}
+
+func init() {
+	Register(Definition{
+		Name:          "keycloak",
+		ConfigSection: "keycloak",
+		Configure:     configureKeycloak,
+		New: func(cfg *config.Config) (Provider, error) {
+			return NewKeycloakProvider(cfg), nil
+		},
+		Routes: func(cfg *config.Config) Routes {
+			routes := Routes{
+				Served:  []string{WellKnownPath},
+				Proxied: []string{AuthorizePath, TokenPath},
+			}
+			if cfg.Keycloak.Registration != KeycloakRegistrationNone {
+				routes.Served = append(routes.Served, RegisterPath)
+			}
+			return routes
+		},
+	})
+}
+
+// configureKeycloak validates the realm settings and derives all endpoints from them
+func configureKeycloak(cfg *config.Config) error {
+	kc := cfg.Keycloak
+	if kc.BaseURL == "" || kc.Realm == "" {
+		return fmt.Errorf("keycloak.base_url and keycloak.realm are required")
+	}
+	switch kc.Registration {
+	case "", KeycloakRegistrationAdmin, KeycloakRegistrationDCR, KeycloakRegistrationNone:
+	default:
+		return fmt.Errorf("keycloak.registration must be one of admin, dcr or none, got %q", kc.Registration)
+	}
+
+	cfg.AuthServerBaseURL = KeycloakOIDCURL(kc)
+	cfg.JWKSURL = KeycloakOIDCURL(kc) + "/certs"
+	if cfg.TokenValidation.Issuer == "" {
+		cfg.TokenValidation.Issuer = KeycloakRealmURL(kc)
+	}
+
+	// Keycloak names its authorization endpoint /auth
+	if cfg.PathMapping == nil {
+		cfg.PathMapping = make(map[string]string)
+	}
+	if _, ok := cfg.PathMapping[AuthorizePath]; !ok {
+		cfg.PathMapping[AuthorizePath] = "/auth"
+	}
+	return nil
+}
+
+// NewKeycloakProvider initializes a Provider for a Keycloak realm.
+func NewKeycloakProvider(cfg *config.Config) Provider {
+	return &keycloakProvider{cfg: cfg, adminToken: newTokenCache(), dpop: newDPoPVerifier(cfg.DPoP)}
+}
+
+// KeycloakRealmURL returns the issuer URL of the configured realm
+func KeycloakRealmURL(kc config.KeycloakConfig) string {
+	return strings.TrimSuffix(kc.BaseURL, "/") + "/realms/" + url.PathEscape(kc.Realm)
+}
+
+// KeycloakOIDCURL returns the base URL of the realm's OpenID Connect endpoints
+func KeycloakOIDCURL(kc config.KeycloakConfig) string {
+	return KeycloakRealmURL(kc) + "/protocol/openid-connect"
+}
+
+// keycloakAdminURL returns the admin REST API URL for the realm's clients
+func keycloakAdminURL(kc config.KeycloakConfig) string {
+	return strings.TrimSuffix(kc.BaseURL, "/") + "/admin/realms/" + url.PathEscape(kc.Realm) + "/clients"
+}
+
+// registrationMode returns the configured registration mode, defaulting to the admin API
+func (p *keycloakProvider) registrationMode() string {
+	if p.cfg.Keycloak.Registration == "" {
+		return KeycloakRegistrationAdmin
+	}
+	return p.cfg.Keycloak.Registration
+}
+
+func (p *keycloakProvider) WellKnownHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		baseURL := util.GetExternalBaseURL(r)
+
+		response := map[string]interface{}{
+			"issuer":                                KeycloakRealmURL(p.cfg.Keycloak),
+			"authorization_endpoint":                baseURL + "/authorize",
+			"token_endpoint":                        baseURL + "/token",
+			"jwks_uri":                              p.cfg.JWKSURL,
+			"response_types_supported":              []string{"code"},
+			"grant_types_supported":                 []string{"authorization_code", "refresh_token"},
+			"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post"},
+			"code_challenge_methods_supported":      []string{"S256"},
+		}
+		if p.registrationMode() != KeycloakRegistrationNone {
+			response["registration_endpoint"] = baseURL + "/register"
+		}
+		if algs := DPoPAlgorithms(p.cfg); algs != nil {
+			response["dpop_signing_alg_values_supported"] = algs
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			authzLog.Ctx(r.Context()).Error("Error encoding well-known: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+	}
+}
+
+// ValidateToken validates the JWT access token against the provider's JWKS
+func (p *keycloakProvider) ValidateToken(r *http.Request) (*Principal, error) {
+	return validateJWTBearer(p, p.cfg.TokenValidation, p.dpop, r)
+}
+
+// ProtectedResourceMetadata advertises the proxy as the authorization server
+func (p *keycloakProvider) ProtectedResourceMetadata(r *http.Request) *ResourceMetadata {
+	return defaultResourceMetadata(p.cfg, r)
+}
+
+func (p *keycloakProvider) RegisterHandler() http.HandlerFunc {
+	if p.registrationMode() == KeycloakRegistrationNone {
+		return nil
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+		w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var regReq RegisterRequest
+		if err := json.NewDecoder(r.Body).Decode(&regReq); err != nil {
+			authzLog.Ctx(r.Context()).Error("Reading register request: %v", err)
+			writeRegistrationError(w, newRegistrationError(http.StatusBadRequest,
+				errInvalidClientMetadata, "request body must be a JSON client metadata document"))
+			return
+		}
+		if err := validateRedirectURIs(regReq.RedirectURIs); err != nil {
+			authzLog.Ctx(r.Context()).Warn("Rejecting registration with invalid redirect URIs: %v", err)
+			writeRegistrationError(w, err)
+			return
+		}
+
+		var (
+			resp *RegisterResponse
+			err  error
+		)
+		if p.registrationMode() == KeycloakRegistrationDCR {
+			resp, err = p.registerWithDCR(regReq)
+		} else {
+			resp, err = p.registerWithAdminAPI(regReq)
+		}
+		if err != nil {
+			authzLog.Ctx(r.Context()).Error("Keycloak client registration failed: %v", err)
+			writeRegistrationError(w, registrationErrorFromUpstream(err))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			authzLog.Ctx(r.Context()).Error("Encoding /register response: %v", err)
+		}
+	}
+}
+
+// newClientSecret returns an unpredictable secret for a registered client
+func newClientSecret() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// registerWithAdminAPI creates a confidential client through the admin REST API
+func (p *keycloakProvider) registerWithAdminAPI(regReq RegisterRequest) (*RegisterResponse, error) {
+	regReq.ClientID = "client-" + randomString(8)
+	regReq.ClientSecret = newClientSecret()
+
+	clientName := regReq.ClientName
+	if clientName == "" {
+		clientName = regReq.ClientID
+	}
+
+	body := map[string]interface{}{
+		"clientId":                  regReq.ClientID,
+		"name":                      clientName,
+		"protocol":                  "openid-connect",
+		"publicClient":              false,
+		"clientAuthenticatorType":   "client-secret",
+		"secret":                    regReq.ClientSecret,
+		"redirectUris":              regReq.RedirectURIs,
+		"standardFlowEnabled":       true,
+		"directAccessGrantsEnabled": false,
+		"serviceAccountsEnabled":    false,
+		"attributes": map[string]string{
+			"pkce.code.challenge.method": "S256",
+		},
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal Keycloak client: %w", err)
+	}
+
+	err = p.postClient(payload)
+	var apiErr *upstreamAPIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusUnauthorized {
+		authzLog.Info("Keycloak rejected the cached admin token, requesting a new one")
+		p.adminToken.Invalidate()
+		err = p.postClient(payload)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	authzLog.Info("Created Keycloak client %s in realm %s", regReq.ClientID, p.cfg.Keycloak.Realm)
+	return &RegisterResponse{
+		ClientID:      regReq.ClientID,
+		ClientSecret:  regReq.ClientSecret,
+		ClientName:    regReq.ClientName,
+		RedirectURIs:  regReq.RedirectURIs,
+		GrantTypes:    regReq.GrantTypes,
+		ResponseTypes: regReq.ResponseTypes,
+	}, nil
+}
+
+// postClient sends a client representation to the admin REST API
+func (p *keycloakProvider) postClient(payload []byte) error {
+	req, err := http.NewRequest(http.MethodPost, keycloakAdminURL(p.cfg.Keycloak), bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create Keycloak admin request: %w", err)
+	}
+
+	token, err := p.adminToken.Get(p.requestAdminToken)
+	if err != nil {
+		return fmt.Errorf("failed to get Keycloak admin token: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := util.OutboundClient().Do(req)
+	if err != nil {
+		return fmt.Errorf("keycloak admin API call failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return &upstreamAPIError{Provider: "Keycloak", StatusCode: resp.StatusCode, Body: string(respBody)}
+	}
+	return nil
+}
+
+// requestAdminToken performs the client credentials grant for the service account client
+func (p *keycloakProvider) requestAdminToken() (string, time.Duration, error) {
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	form.Set("client_id", p.cfg.Keycloak.ClientID)
+	form.Set("client_secret", p.cfg.Keycloak.ClientSecret)
+
+	req, err := http.NewRequest(http.MethodPost, KeycloakOIDCURL(p.cfg.Keycloak)+"/token", strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", 0, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	authzLog.Debug("Requesting admin token for Keycloak with client ID: %s", p.cfg.Keycloak.ClientID)
+
+	resp, err := util.OutboundClient().Do(req)
+	if err != nil {
+		return "", 0, fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", 0, fmt.Errorf("token request failed (%d): %s", resp.StatusCode, string(body))
+	}
+
+	var tokenResp struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+		return "", 0, fmt.Errorf("failed to parse token JSON: %w", err)
+	}
+
+	authzLog.Info("Successfully obtained admin token from Keycloak (expires in %ds)", tokenResp.ExpiresIn)
+	return tokenResp.AccessToken, time.Duration(tokenResp.ExpiresIn) * time.Second, nil
+}
+
+// registerWithDCR forwards the registration to Keycloak's native dynamic
+// client registration endpoint using the configured initial access token
+func (p *keycloakProvider) registerWithDCR(regReq RegisterRequest) (*RegisterResponse, error) {
+	body := map[string]interface{}{
+		"client_name":                regReq.ClientName,
+		"redirect_uris":              regReq.RedirectURIs,
+		"token_endpoint_auth_method": "client_secret_basic",
+	}
+	if len(regReq.GrantTypes) > 0 {
+		body["grant_types"] = regReq.GrantTypes
+	}
+	if len(regReq.ResponseTypes) > 0 {
+		body["response_types"] = regReq.ResponseTypes
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal registration request: %w", err)
+	}
+
+	dcrURL := KeycloakRealmURL(p.cfg.Keycloak) + "/clients-registrations/openid-connect"
+	req, err := http.NewRequest(http.MethodPost, dcrURL, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Keycloak registration request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.cfg.Keycloak.InitialAccessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+p.cfg.Keycloak.InitialAccessToken)
+	}
+
+	resp, err := util.OutboundClient().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("keycloak registration call failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 400 {
+		return nil, &upstreamAPIError{Provider: "Keycloak", StatusCode: resp.StatusCode, Body: string(respBody)}
+	}
+
+	var regResp RegisterResponse
+	if err := json.Unmarshal(respBody, &regResp); err != nil {
+		return nil, fmt.Errorf("failed to parse Keycloak registration response: %w", err)
+	}
+
+	authzLog.Info("Registered Keycloak client %s through dynamic client registration", regResp.ClientID)
+	return &regResp, nil
+}
+
+// MapScopes exposes the realm roles and client roles of a Keycloak token as scopes
+func (p *keycloakProvider) MapScopes(claims map[string]interface{}) []string {
+	var roles []string
+
+	if realmAccess, ok := claims["realm_access"].(map[string]interface{}); ok {
+		roles = append(roles, stringList(realmAccess["roles"])...)
+	}
+
+	if resourceAccess, ok := claims["resource_access"].(map[string]interface{}); ok {
+		clients := make([]string, 0, len(resourceAccess))
+		for client := range resourceAccess {
+			clients = append(clients, client)
+		}
+		sort.Strings(clients)
+
+		for _, client := range clients {
+			access, ok := resourceAccess[client].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			for _, role := range stringList(access["roles"]) {
+				roles = append(roles, client+":"+role)
+			}
+		}
+	}
+
+	mapping := p.cfg.Keycloak.RoleScopes
+	if len(mapping) == 0 {
+		return roles
+	}
+
+	var scopes []string
+	for _, role := range roles {
+		if scope, ok := mapping[role]; ok {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
@@ -0,0 +1,208 @@
+package authz
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+
+	"github.com/wso2/open-mcp-auth-proxy/internal/config"
+)
+
+// fakeKeycloak is an httptest stand-in for the parts of the Keycloak API
+// used by the provider: the token endpoint, the admin clients API and DCR.
+type fakeKeycloak struct {
+	*httptest.Server
+	tokenCalls    int32
+	createdClient map[string]interface{}
+	dcrAuth       string
+}
+
+func startFakeKeycloak(t *testing.T) *fakeKeycloak {
+	fake := &fakeKeycloak{}
+	fake.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/realms/mcp/protocol/openid-connect/token":
+			atomic.AddInt32(&fake.tokenCalls, 1)
+			r.ParseForm()
+			if r.PostForm.Get("client_id") != "proxy-admin" || r.PostForm.Get("client_secret") != "admin-secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"access_token":"kc-admin-token","expires_in":300}`))
+		case "/admin/realms/mcp/clients":
+			if r.Header.Get("Authorization") != "Bearer kc-admin-token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			json.NewDecoder(r.Body).Decode(&fake.createdClient)
+			if fake.createdClient["name"] == "duplicate" {
+				w.WriteHeader(http.StatusConflict)
+				w.Write([]byte(`{"errorMessage":"Client duplicate already exists"}`))
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+		case "/realms/mcp/clients-registrations/openid-connect":
+			fake.dcrAuth = r.Header.Get("Authorization")
+			var req map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&req)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"client_id":      "kc-generated-id",
+				"client_secret":  "kc-generated-secret",
+				"client_name":    req["client_name"],
+				"redirect_uris":  req["redirect_uris"],
+				"grant_types":    []string{"authorization_code", "refresh_token"},
+				"response_types": []string{"code"},
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(fake.Close)
+	return fake
+}
+
+func newTestKeycloakConfig(baseURL, registration string) *config.Config {
+	return &config.Config{
+		Provider: "keycloak",
+		Keycloak: config.KeycloakConfig{
+			BaseURL:            baseURL,
+			Realm:              "mcp",
+			ClientID:           "proxy-admin",
+			ClientSecret:       "admin-secret",
+			Registration:       registration,
+			InitialAccessToken: "initial-token",
+		},
+	}
+}
+
+func TestKeycloakEndpoints(t *testing.T) {
+	kc := config.KeycloakConfig{BaseURL: "https://kc.example.com/", Realm: "mcp"}
+
+	if got := KeycloakRealmURL(kc); got != "https://kc.example.com/realms/mcp" {
+		t.Errorf("Unexpected realm URL: %s", got)
+	}
+	if got := KeycloakOIDCURL(kc); got != "https://kc.example.com/realms/mcp/protocol/openid-connect" {
+		t.Errorf("Unexpected OIDC URL: %s", got)
+	}
+	if got := keycloakAdminURL(kc); got != "https://kc.example.com/admin/realms/mcp/clients" {
+		t.Errorf("Unexpected admin URL: %s", got)
+	}
+}
+
+func TestKeycloakRegisterWithAdminAPI(t *testing.T) {
+	fake := startFakeKeycloak(t)
+	provider := NewKeycloakProvider(newTestKeycloakConfig(fake.URL, ""))
+
+	secrets := map[interface{}]bool{}
+	for i := 0; i < 2; i++ {
+		w, response := doRegister(t, provider, `{"client_name":"inspector","redirect_uris":["http://localhost:6274/cb"]}`)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Expected status Created, got %v: %v", w.Code, response)
+		}
+		if response["client_id"] != fake.createdClient["clientId"] || response["client_secret"] != fake.createdClient["secret"] {
+			t.Errorf("Expected returned credentials to match the created client")
+		}
+		secrets[response["client_secret"]] = true
+	}
+	if secret, _ := fake.createdClient["secret"].(string); len(secrets) != 2 || len(secret) < 43 {
+		t.Errorf("Expected distinct 256-bit client secrets, got %v", secrets)
+	}
+
+	attributes := fake.createdClient["attributes"].(map[string]interface{})
+	if attributes["pkce.code.challenge.method"] != "S256" {
+		t.Errorf("Expected S256 PKCE to be enforced, got %v", attributes)
+	}
+	if calls := atomic.LoadInt32(&fake.tokenCalls); calls != 1 {
+		t.Errorf("Expected admin token to be reused, got %d token requests", calls)
+	}
+}
+
+func TestKeycloakRegisterAdminConflict(t *testing.T) {
+	fake := startFakeKeycloak(t)
+	provider := NewKeycloakProvider(newTestKeycloakConfig(fake.URL, KeycloakRegistrationAdmin))
+
+	w, response := doRegister(t, provider, `{"client_name":"duplicate","redirect_uris":["https://app.example.com/cb"]}`)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status BadRequest, got %v", w.Code)
+	}
+	if response["error"] != errInvalidClientMetadata {
+		t.Errorf("Expected error=%s, got %v", errInvalidClientMetadata, response["error"])
+	}
+}
+
+func TestKeycloakRegisterWithDCR(t *testing.T) {
+	fake := startFakeKeycloak(t)
+	provider := NewKeycloakProvider(newTestKeycloakConfig(fake.URL, KeycloakRegistrationDCR))
+
+	w, response := doRegister(t, provider, `{"client_name":"inspector","redirect_uris":["https://app.example.com/cb"]}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status Created, got %v", w.Code)
+	}
+	if response["client_id"] != "kc-generated-id" || response["client_secret"] != "kc-generated-secret" {
+		t.Errorf("Expected Keycloak issued credentials, got %v", response)
+	}
+	if fake.dcrAuth != "Bearer initial-token" {
+		t.Errorf("Expected initial access token to be sent, got %q", fake.dcrAuth)
+	}
+}
+
+func TestKeycloakRegistrationDisabled(t *testing.T) {
+	provider := NewKeycloakProvider(newTestKeycloakConfig("https://kc.example.com", KeycloakRegistrationNone))
+	if provider.RegisterHandler() != nil {
+		t.Errorf("Expected no register handler when registration is disabled")
+	}
+
+	req := httptest.NewRequest("GET", "/.well-known/oauth-authorization-server", nil)
+	w := httptest.NewRecorder()
+	provider.WellKnownHandler()(w, req)
+
+	var response map[string]interface{}
+	json.NewDecoder(w.Body).Decode(&response)
+	if _, ok := response["registration_endpoint"]; ok {
+		t.Errorf("Expected no registration_endpoint when registration is disabled")
+	}
+	if response["issuer"] != "https://kc.example.com/realms/mcp" {
+		t.Errorf("Expected realm issuer, got %v", response["issuer"])
+	}
+}
+
+func TestKeycloakMapScopes(t *testing.T) {
+	claims := map[string]interface{}{
+		"scope": "openid profile",
+		"realm_access": map[string]interface{}{
+			"roles": []interface{}{"mcp-user", "offline_access"},
+		},
+		"resource_access": map[string]interface{}{
+			"mcp-server": map[string]interface{}{
+				"roles": []interface{}{"tools-admin"},
+			},
+		},
+	}
+
+	provider := NewKeycloakProvider(newTestKeycloakConfig("https://kc.example.com", ""))
+	expected := []string{"openid", "profile", "mcp-user", "offline_access", "mcp-server:tools-admin"}
+	if got := TokenScopes(provider, claims); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected scopes %v, got %v", expected, got)
+	}
+
+	cfg := newTestKeycloakConfig("https://kc.example.com", "")
+	cfg.Keycloak.RoleScopes = map[string]string{
+		"mcp-user":               "mcp:read",
+		"mcp-server:tools-admin": "mcp:tools",
+	}
+	provider = NewKeycloakProvider(cfg)
+	expected = []string{"openid", "profile", "mcp:read", "mcp:tools"}
+	if got := TokenScopes(provider, claims); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected mapped scopes %v, got %v", expected, got)
+	}
+
+	if missing := MissingScopes([]string{"mcp:read", "mcp:write"}, expected); !reflect.DeepEqual(missing, []string{"mcp:write"}) {
+		t.Errorf("Expected mcp:write to be missing, got %v", missing)
+	}
+}
@@ -0,0 +1,45 @@
+package authz
+
+import (
+	"context"
+	"time"
+)
+
+// Principal is the authenticated caller of an MCP request
+type Principal struct {
+	Subject   string
+	ClientID  string
+	Issuer    string
+	Scopes    []string
+	Audience  []string
+	ExpiresAt time.Time
+
+	// Token is the raw access token the principal was authenticated with
+	Token string
+
+	// Claims holds every claim of the access token
+	Claims map[string]interface{}
+}
+
+// HasScope reports whether the principal was granted scope
+func (p *Principal) HasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying the principal
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFromContext returns the principal stored by WithPrincipal, if any
+func PrincipalFromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey{}).(*Principal)
+	return p
+}
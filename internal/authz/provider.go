@@ -1,10 +1,37 @@
 package authz
 
-import "net/http"
+import (
+	"net/http"
+
+	logger "github.com/wso2/open-mcp-auth-proxy/internal/logging"
+)
+
+// authzLog logs token validation and provider activity
+var authzLog = logger.For(logger.Authz)
 
 // Provider is an interface describing how each auth provider
-// will handle /.well-known/oauth-authorization-server and /register
+// will handle /.well-known/oauth-authorization-server and /register,
+// validate bearer tokens on MCP requests and describe the protected resource
 type Provider interface {
 	WellKnownHandler() http.HandlerFunc
 	RegisterHandler() http.HandlerFunc
+
+	// ValidateToken authenticates the access token on an MCP request
+	ValidateToken(r *http.Request) (*Principal, error)
+
+	// ProtectedResourceMetadata returns the RFC 9728 metadata served at
+	// /.well-known/oauth-protected-resource
+	ProtectedResourceMetadata(r *http.Request) *ResourceMetadata
+}
+
+// AuthorizeHook is implemented by providers that handle /authorize
+// themselves instead of having it proxied to the authorization server
+type AuthorizeHook interface {
+	AuthorizeHandler() http.HandlerFunc
+}
+
+// TokenHook is implemented by providers that handle /token
+// themselves instead of having it proxied to the authorization server
+type TokenHook interface {
+	TokenHandler() http.HandlerFunc
 }
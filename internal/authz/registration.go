@@ -0,0 +1,153 @@
+package authz
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Error codes defined by RFC 7591 section 3.2.2, plus the generic OAuth
+// server_error used when the upstream identity provider fails.
+const (
+	errInvalidRedirectURI    = "invalid_redirect_uri"
+	errInvalidClientMetadata = "invalid_client_metadata"
+	errServerError           = "server_error"
+)
+
+// registrationError is a client registration failure that is reported
+// to the caller as an RFC 7591 error response.
+type registrationError struct {
+	Status      int    `json:"-"`
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+func newRegistrationError(status int, code, description string) *registrationError {
+	return &registrationError{Status: status, Code: code, Description: description}
+}
+
+func (e *registrationError) Error() string {
+	if e.Description == "" {
+		return e.Code
+	}
+	return e.Code + ": " + e.Description
+}
+
+// writeRegistrationError writes an RFC 7591 error response
+func writeRegistrationError(w http.ResponseWriter, err *registrationError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(err.Status)
+	if encErr := json.NewEncoder(w).Encode(err); encErr != nil {
+		authzLog.Error("Encoding registration error response: %v", encErr)
+	}
+}
+
+// upstreamAPIError is returned when the identity provider's client
+// management API rejects a request.
+type upstreamAPIError struct {
+	Provider   string
+	StatusCode int
+	Body       string
+}
+
+func (e *upstreamAPIError) Error() string {
+	return fmt.Sprintf("%s creation error (%d): %s", e.Provider, e.StatusCode, e.Body)
+}
+
+// validateRedirectURIs checks that every redirect URI is an absolute https URI,
+// or an http URI on a loopback address, and carries no fragment.
+func validateRedirectURIs(uris []string) *registrationError {
+	if len(uris) == 0 {
+		return newRegistrationError(http.StatusBadRequest, errInvalidRedirectURI, "redirect_uris is required")
+	}
+
+	for _, raw := range uris {
+		u, err := url.Parse(raw)
+		if err != nil || !u.IsAbs() || u.Host == "" {
+			return newRegistrationError(http.StatusBadRequest, errInvalidRedirectURI,
+				fmt.Sprintf("redirect URI %q must be an absolute URI", raw))
+		}
+		if u.Fragment != "" || strings.Contains(raw, "#") {
+			return newRegistrationError(http.StatusBadRequest, errInvalidRedirectURI,
+				fmt.Sprintf("redirect URI %q must not contain a fragment", raw))
+		}
+
+		switch strings.ToLower(u.Scheme) {
+		case "https":
+		case "http":
+			if !isLoopbackHost(u.Hostname()) {
+				return newRegistrationError(http.StatusBadRequest, errInvalidRedirectURI,
+					fmt.Sprintf("redirect URI %q must use https unless it targets a loopback address", raw))
+			}
+		default:
+			return newRegistrationError(http.StatusBadRequest, errInvalidRedirectURI,
+				fmt.Sprintf("redirect URI %q uses unsupported scheme %q", raw, u.Scheme))
+		}
+	}
+
+	return nil
+}
+
+// isLoopbackHost reports whether host is localhost or a loopback IP address
+func isLoopbackHost(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// registrationErrorFromUpstream maps a failure from the identity provider
+// into the error response returned to the registering client.
+func registrationErrorFromUpstream(err error) *registrationError {
+	var regErr *registrationError
+	if errors.As(err, &regErr) {
+		return regErr
+	}
+
+	var apiErr *upstreamAPIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode >= 500 ||
+		apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden {
+		// Network failures, upstream outages and rejected admin credentials are
+		// not the client's fault, so don't echo the upstream details back.
+		return newRegistrationError(http.StatusBadGateway, errServerError,
+			"the authorization server could not register the client")
+	}
+
+	code, description := upstreamErrorDescription(apiErr.Body)
+	if code == errInvalidRedirectURI || code == errInvalidClientMetadata {
+		// The upstream already speaks RFC 7591, e.g. a native DCR endpoint
+		return newRegistrationError(http.StatusBadRequest, code, description)
+	}
+
+	lower := strings.ToLower(description)
+	if strings.Contains(lower, "callback") || strings.Contains(lower, "redirect") {
+		return newRegistrationError(http.StatusBadRequest, errInvalidRedirectURI, description)
+	}
+	return newRegistrationError(http.StatusBadRequest, errInvalidClientMetadata, description)
+}
+
+// upstreamErrorDescription extracts the RFC 7591 error code, if any, and a human
+// readable message from an identity provider error body.
+func upstreamErrorDescription(body string) (string, string) {
+	var parsed struct {
+		Error            string `json:"error"`
+		Message          string `json:"message"`
+		Description      string `json:"description"`
+		ErrorDescription string `json:"error_description"`
+		ErrorMessage     string `json:"errorMessage"`
+	}
+	if err := json.Unmarshal([]byte(body), &parsed); err == nil {
+		for _, candidate := range []string{parsed.Description, parsed.ErrorDescription, parsed.ErrorMessage, parsed.Message} {
+			if candidate != "" {
+				return parsed.Error, candidate
+			}
+		}
+	}
+	return parsed.Error, "the authorization server rejected the client metadata"
+}
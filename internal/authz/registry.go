@@ -0,0 +1,149 @@
+package authz
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/wso2/open-mcp-auth-proxy/internal/config"
+)
+
+// OAuth routes that a provider can either serve itself or have proxied
+const (
+	AuthorizePath = "/authorize"
+	TokenPath     = "/token"
+	RegisterPath  = "/register"
+	WellKnownPath = "/.well-known/oauth-authorization-server"
+)
+
+// DefaultProviderName is used when the configuration doesn't select a provider
+const DefaultProviderName = "default"
+
+// Routes declares which OAuth routes a provider serves with its own
+// handlers and which are forwarded to the authorization server.
+type Routes struct {
+	Served  []string
+	Proxied []string
+}
+
+// Definition describes a provider that can be selected with `provider: <name>`.
+// Custom providers are compiled in by calling Register from an init function.
+type Definition struct {
+	// Name is the value of the `provider` config field that selects this provider
+	Name string
+
+	// ConfigSection is the top-level config key holding the provider settings.
+	// Custom providers read it with config.Config.DecodeSection.
+	ConfigSection string
+
+	// Configure validates the provider settings and derives
+	// AuthServerBaseURL and JWKSURL. Optional.
+	Configure func(cfg *config.Config) error
+
+	// New creates the provider
+	New func(cfg *config.Config) (Provider, error)
+
+	// Routes reports the OAuth routes for the given configuration
+	Routes func(cfg *config.Config) Routes
+}
+
+var (
+	registry      = make(map[string]Definition)
+	registryMutex sync.RWMutex
+)
+
+// Register makes a provider available by name. It panics if the definition
+// is incomplete or the name is already taken.
+func Register(def Definition) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	if def.Name == "" || def.New == nil || def.Routes == nil {
+		panic("authz: Register requires a name, a factory and routes")
+	}
+	if _, exists := registry[def.Name]; exists {
+		panic("authz: Register called twice for provider " + def.Name)
+	}
+	registry[def.Name] = def
+}
+
+// Lookup returns the definition registered under name
+func Lookup(name string) (Definition, bool) {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+	def, ok := registry[name]
+	return def, ok
+}
+
+// Names returns the sorted names of all registered providers
+func Names() []string {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ProviderName returns the provider selected by the configuration
+func ProviderName(cfg *config.Config) string {
+	if cfg.Provider != "" {
+		return cfg.Provider
+	}
+	return DefaultProviderName
+}
+
+// NewProvider configures and creates the provider selected by the configuration
+func NewProvider(cfg *config.Config) (Provider, error) {
+	name := ProviderName(cfg)
+	def, ok := Lookup(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown provider %q (available: %v)", name, Names())
+	}
+
+	cfg.Provider = name
+	if def.Configure != nil {
+		if err := def.Configure(cfg); err != nil {
+			return nil, fmt.Errorf("provider %s: %w", name, err)
+		}
+	}
+
+	provider, err := def.New(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("provider %s: %w", name, err)
+	}
+	return provider, nil
+}
+
+// RoutesFor returns the OAuth routes of the configured provider
+func RoutesFor(cfg *config.Config) Routes {
+	def, ok := Lookup(ProviderName(cfg))
+	if !ok {
+		def, _ = Lookup(DefaultProviderName)
+	}
+	return def.Routes(cfg)
+}
+
+// HandlerFor returns the provider's own handler for a served route,
+// or nil if the provider has none
+func HandlerFor(p Provider, path string) http.HandlerFunc {
+	switch path {
+	case WellKnownPath:
+		return p.WellKnownHandler()
+	case RegisterPath:
+		return p.RegisterHandler()
+	case AuthorizePath:
+		if hook, ok := p.(AuthorizeHook); ok {
+			return hook.AuthorizeHandler()
+		}
+	case TokenPath:
+		if hook, ok := p.(TokenHook); ok {
+			return hook.TokenHandler()
+		}
+	}
+	return nil
+}
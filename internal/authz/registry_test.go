@@ -0,0 +1,115 @@
+package authz
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/wso2/open-mcp-auth-proxy/internal/config"
+)
+
+type customProvider struct {
+	defaultProvider
+	tenant string
+}
+
+func TestRegisterCustomProvider(t *testing.T) {
+	Register(Definition{
+		Name:          "test-custom",
+		ConfigSection: "test_custom",
+		Configure: func(cfg *config.Config) error {
+			cfg.AuthServerBaseURL = "https://idp.example.com"
+			return nil
+		},
+		New: func(cfg *config.Config) (Provider, error) {
+			return &customProvider{defaultProvider: defaultProvider{cfg: cfg}, tenant: "acme"}, nil
+		},
+		Routes: func(cfg *config.Config) Routes {
+			return Routes{Served: []string{WellKnownPath}, Proxied: []string{AuthorizePath, TokenPath}}
+		},
+	})
+
+	cfg := &config.Config{Provider: "test-custom"}
+	provider, err := NewProvider(cfg)
+	if err != nil {
+		t.Fatalf("NewProvider failed: %v", err)
+	}
+	if custom, ok := provider.(*customProvider); !ok || custom.tenant != "acme" {
+		t.Errorf("Expected the custom provider, got %T", provider)
+	}
+	if cfg.AuthServerBaseURL != "https://idp.example.com" {
+		t.Errorf("Expected Configure to run, got AuthServerBaseURL=%s", cfg.AuthServerBaseURL)
+	}
+
+	routes := RoutesFor(cfg)
+	if !reflect.DeepEqual(routes.Proxied, []string{AuthorizePath, TokenPath}) {
+		t.Errorf("Unexpected proxied routes: %v", routes.Proxied)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected duplicate registration to panic")
+		}
+	}()
+	Register(Definition{
+		Name:   "test-custom",
+		New:    func(cfg *config.Config) (Provider, error) { return nil, nil },
+		Routes: func(cfg *config.Config) Routes { return Routes{} },
+	})
+}
+
+func TestNewProviderSelection(t *testing.T) {
+	tests := []struct {
+		name         string
+		config       config.Config
+		expectedName string
+		expectError  bool
+	}{
+		{name: "Default when unset", config: config.Config{}, expectedName: DefaultProviderName},
+		{name: "Provider field", config: config.Config{Provider: "asgardeo", Asgardeo: config.AsgardeoConfig{OrgName: "org"}}, expectedName: "asgardeo"},
+		{name: "Mode field is ignored", config: config.Config{Mode: "demo"}, expectedName: DefaultProviderName},
+		{name: "Unknown provider", config: config.Config{Provider: "nope"}, expectError: true},
+		{name: "Missing Keycloak realm", config: config.Config{Provider: "keycloak"}, expectError: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := tc.config
+			_, err := NewProvider(&cfg)
+			if tc.expectError {
+				if err == nil {
+					t.Errorf("Expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+			if cfg.Provider != tc.expectedName {
+				t.Errorf("Expected provider %s, got %s", tc.expectedName, cfg.Provider)
+			}
+		})
+	}
+}
+
+func TestBuiltinRoutes(t *testing.T) {
+	asgardeo := RoutesFor(&config.Config{Provider: "asgardeo"})
+	if !reflect.DeepEqual(asgardeo.Served, []string{WellKnownPath, RegisterPath}) {
+		t.Errorf("Unexpected Asgardeo served routes: %v", asgardeo.Served)
+	}
+
+	keycloak := RoutesFor(&config.Config{Provider: "keycloak", Keycloak: config.KeycloakConfig{Registration: KeycloakRegistrationNone}})
+	if !reflect.DeepEqual(keycloak.Served, []string{WellKnownPath}) {
+		t.Errorf("Expected Keycloak without registration to serve only well-known, got %v", keycloak.Served)
+	}
+
+	plain := RoutesFor(&config.Config{})
+	if len(plain.Served) != 0 || len(plain.Proxied) != 4 {
+		t.Errorf("Expected default provider to proxy every OAuth route, got %+v", plain)
+	}
+
+	if HandlerFor(NewDefaultProvider(&config.Config{}), AuthorizePath) != nil {
+		t.Errorf("Expected no provider handler for a proxied route")
+	}
+	var _ http.HandlerFunc = HandlerFor(NewDefaultProvider(&config.Config{}), WellKnownPath)
+}
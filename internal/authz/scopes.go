@@ -0,0 +1,74 @@
+package authz
+
+import "strings"
+
+// ScopeMapper is implemented by providers that derive additional scopes
+// from provider-specific token claims, such as Keycloak roles.
+type ScopeMapper interface {
+	MapScopes(claims map[string]interface{}) []string
+}
+
+// TokenScopes returns the scopes granted by a token: the standard "scope"
+// and "scp" claims plus any scopes the provider maps from its own claims.
+func TokenScopes(p Provider, claims map[string]interface{}) []string {
+	seen := make(map[string]bool)
+	var scopes []string
+	add := func(scope string) {
+		if scope != "" && !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+
+	if scope, ok := claims["scope"].(string); ok {
+		for _, s := range strings.Fields(scope) {
+			add(s)
+		}
+	}
+	for _, s := range stringList(claims["scp"]) {
+		add(s)
+	}
+
+	if mapper, ok := p.(ScopeMapper); ok {
+		for _, s := range mapper.MapScopes(claims) {
+			add(s)
+		}
+	}
+
+	return scopes
+}
+
+// MissingScopes returns the required scopes that are not in granted
+func MissingScopes(required, granted []string) []string {
+	have := make(map[string]bool, len(granted))
+	for _, s := range granted {
+		have[s] = true
+	}
+
+	var missing []string
+	for _, s := range required {
+		if !have[s] {
+			missing = append(missing, s)
+		}
+	}
+	return missing
+}
+
+// stringList converts a JSON array or space separated string claim into a slice
+func stringList(v interface{}) []string {
+	switch val := v.(type) {
+	case string:
+		return strings.Fields(val)
+	case []string:
+		return val
+	case []interface{}:
+		out := make([]string, 0, len(val))
+		for _, item := range val {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+package authz
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/wso2/open-mcp-auth-proxy/internal/config"
+	"github.com/wso2/open-mcp-auth-proxy/internal/util"
+)
+
+// ProtectedResourcePath is where the RFC 9728 resource metadata is served
+const ProtectedResourcePath = "/.well-known/oauth-protected-resource"
+
+// ResourceMetadata is the OAuth 2.0 protected resource metadata document (RFC 9728)
+type ResourceMetadata struct {
+	Resource               string   `json:"resource"`
+	AuthorizationServers   []string `json:"authorization_servers"`
+	ScopesSupported        []string `json:"scopes_supported,omitempty"`
+	BearerMethodsSupported []string `json:"bearer_methods_supported,omitempty"`
+
+	DPoPSigningAlgValuesSupported []string `json:"dpop_signing_alg_values_supported,omitempty"`
+	DPoPBoundAccessTokensRequired bool     `json:"dpop_bound_access_tokens_required,omitempty"`
+
+	TLSClientCertificateBoundAccessTokens bool `json:"tls_client_certificate_bound_access_tokens,omitempty"`
+}
+
+// BearerToken extracts the access token from the Authorization header
+func BearerToken(r *http.Request) (string, error) {
+	token, _, err := AccessToken(r)
+	return token, err
+}
+
+// AccessToken extracts the access token and its scheme, Bearer or DPoP,
+// from the Authorization header
+func AccessToken(r *http.Request) (string, string, error) {
+	authHeader := r.Header.Get("Authorization")
+	for _, scheme := range []string{"Bearer", DPoPHeader} {
+		if strings.HasPrefix(authHeader, scheme+" ") {
+			return strings.TrimPrefix(authHeader, scheme+" "), scheme, nil
+		}
+	}
+	return "", "", errors.New("missing or invalid Authorization header")
+}
+
+// validateJWTBearer validates a JWT access token against the loaded JWKS and
+// the expected issuer and audiences, and builds the principal, using p to map
+// provider-specific scopes. DPoP-bound tokens are checked against their proof
+// by dpop, which is nil when disabled.
+func validateJWTBearer(p Provider, checks config.TokenValidationConfig, dpop *dpopVerifier, r *http.Request) (*Principal, error) {
+	token, scheme, err := AccessToken(r)
+	if err != nil {
+		return nil, &validationError{FailureMissingToken, err}
+	}
+
+	claims, err := util.ValidateJWTClaims("Bearer " + token)
+	if err != nil {
+		return nil, &validationError{jwtFailure(err), err}
+	}
+	if err := checkIssuerAndAudience(claims, checks); err != nil {
+		return nil, &validationError{FailureInvalidClaims, err}
+	}
+
+	if err := dpop.checkBinding(r, scheme, token, claims); err != nil {
+		return nil, &validationError{FailureDPoP, err}
+	}
+	if err := checkCertificateBinding(r, claims); err != nil {
+		return nil, &validationError{FailureCertificateBinding, err}
+	}
+
+	return newPrincipal(p, token, claims), nil
+}
+
+// checkIssuerAndAudience verifies the iss and aud claims when the
+// configuration names the expected values
+func checkIssuerAndAudience(claims jwt.MapClaims, checks config.TokenValidationConfig) error {
+	if checks.Issuer != "" && !claims.VerifyIssuer(checks.Issuer, true) {
+		return fmt.Errorf("token issuer %v is not %s", claims["iss"], checks.Issuer)
+	}
+	if len(checks.Audiences) == 0 {
+		return nil
+	}
+	for _, audience := range checks.Audiences {
+		if claims.VerifyAudience(audience, true) {
+			return nil
+		}
+	}
+	return fmt.Errorf("token audience %v contains none of %v", claims["aud"], checks.Audiences)
+}
+
+// Reasons for rejecting an access token, as reported by FailureReason
+const (
+	FailureMissingToken       = "missing_token"
+	FailureMalformed          = "malformed"
+	FailureExpired            = "expired"
+	FailureNotYetValid        = "not_yet_valid"
+	FailureUnknownKey         = "unknown_key"
+	FailureInvalidSignature   = "invalid_signature"
+	FailureInvalidClaims      = "invalid_claims"
+	FailureDPoP               = "dpop"
+	FailureCertificateBinding = "certificate_binding"
+	FailureInvalid            = "invalid_token" // Any other reason
+)
+
+// validationError is a token rejection with the reason it was rejected
+type validationError struct {
+	reason string
+	err    error
+}
+
+func (e *validationError) Error() string { return e.err.Error() }
+func (e *validationError) Unwrap() error { return e.err }
+
+// FailureReason returns why ValidateToken rejected a token, one of the
+// Failure constants
+func FailureReason(err error) string {
+	var v *validationError
+	if errors.As(err, &v) {
+		return v.reason
+	}
+	return FailureInvalid
+}
+
+// jwtFailure classifies an error from parsing and verifying a JWT
+func jwtFailure(err error) string {
+	var v *jwt.ValidationError
+	if !errors.As(err, &v) {
+		return FailureInvalid
+	}
+	switch {
+	case v.Errors&jwt.ValidationErrorMalformed != 0:
+		return FailureMalformed
+	case v.Errors&jwt.ValidationErrorUnverifiable != 0:
+		return FailureUnknownKey
+	case v.Errors&jwt.ValidationErrorSignatureInvalid != 0:
+		return FailureInvalidSignature
+	case v.Errors&jwt.ValidationErrorExpired != 0:
+		return FailureExpired
+	case v.Errors&(jwt.ValidationErrorNotValidYet|jwt.ValidationErrorIssuedAt) != 0:
+		return FailureNotYetValid
+	}
+	return FailureInvalidClaims
+}
+
+// newPrincipal builds a principal from validated token claims
+func newPrincipal(p Provider, token string, claims map[string]interface{}) *Principal {
+	principal := &Principal{
+		Token:    token,
+		Claims:   claims,
+		Scopes:   TokenScopes(p, claims),
+		Audience: stringList(claims["aud"]),
+	}
+	principal.Subject, _ = claims["sub"].(string)
+	principal.Issuer, _ = claims["iss"].(string)
+
+	// Different servers put the client in different claims
+	for _, claim := range []string{"client_id", "azp", "cid"} {
+		if clientID, ok := claims[claim].(string); ok && clientID != "" {
+			principal.ClientID = clientID
+			break
+		}
+	}
+
+	if exp, ok := claims["exp"].(float64); ok {
+		principal.ExpiresAt = time.Unix(int64(exp), 0)
+	}
+	return principal
+}
+
+// checkCertificateBinding verifies that a certificate-bound token (RFC 8705)
+// is presented over a TLS connection with the certificate it was issued to
+func checkCertificateBinding(r *http.Request, claims map[string]interface{}) error {
+	x5t := confirmation(claims, "x5t#S256")
+	if x5t == "" {
+		return nil
+	}
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return errors.New("certificate-bound token presented without a client certificate")
+	}
+	if util.CertificateThumbprint(r.TLS.PeerCertificates[0]) != x5t {
+		return errors.New("client certificate does not match the token's cnf.x5t#S256")
+	}
+	return nil
+}
+
+// defaultResourceMetadata describes the proxy as the protected resource,
+// with the proxy itself advertised as the authorization server
+func defaultResourceMetadata(cfg *config.Config, r *http.Request) *ResourceMetadata {
+	baseURL := util.GetExternalBaseURL(r)
+	return &ResourceMetadata{
+		Resource:               baseURL,
+		AuthorizationServers:   []string{baseURL},
+		ScopesSupported:        cfg.RequiredScopes,
+		BearerMethodsSupported: []string{"header"},
+
+		DPoPSigningAlgValuesSupported: DPoPAlgorithms(cfg),
+		DPoPBoundAccessTokensRequired: cfg.DPoP.Enabled && cfg.DPoP.Required,
+
+		TLSClientCertificateBoundAccessTokens: cfg.TLS.Enabled && cfg.TLS.ClientAuth != "none",
+	}
+}
+
+// ProtectedResourceHandler serves the provider's protected resource metadata
+func ProtectedResourceHandler(p Provider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(p.ProtectedResourceMetadata(r)); err != nil {
+			authzLog.Ctx(r.Context()).Error("Error encoding protected resource metadata: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+	}
+}
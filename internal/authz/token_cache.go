@@ -0,0 +1,60 @@
+package authz
+
+import (
+	"sync"
+	"time"
+)
+
+// maxRefreshSkew caps how early a cached token is refreshed before it expires
+const maxRefreshSkew = 60 * time.Second
+
+// tokenCache holds a single access token and refreshes it ahead of expiry.
+// Concurrent callers share one in-flight fetch.
+type tokenCache struct {
+	mu        sync.Mutex
+	token     string
+	refreshAt time.Time
+	now       func() time.Time
+}
+
+func newTokenCache() *tokenCache {
+	return &tokenCache{now: time.Now}
+}
+
+// Get returns the cached token, calling fetch when there is none or it is
+// about to expire. fetch returns the token and its lifetime from expires_in.
+func (c *tokenCache) Get(fetch func() (string, time.Duration, error)) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token != "" && c.now().Before(c.refreshAt) {
+		return c.token, nil
+	}
+
+	token, lifetime, err := fetch()
+	if err != nil {
+		return "", err
+	}
+
+	if lifetime <= 0 {
+		// Without expires_in there is no safe reuse window
+		c.token = ""
+		return token, nil
+	}
+
+	// Refresh a fifth of the lifetime early, but never more than maxRefreshSkew
+	skew := lifetime / 5
+	if skew > maxRefreshSkew {
+		skew = maxRefreshSkew
+	}
+	c.token = token
+	c.refreshAt = c.now().Add(lifetime - skew)
+	return token, nil
+}
+
+// Invalidate drops the cached token, e.g. after the upstream rejected it
+func (c *tokenCache) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.token = ""
+}
@@ -0,0 +1,276 @@
+package authz
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/wso2/open-mcp-auth-proxy/internal/config"
+	"github.com/wso2/open-mcp-auth-proxy/internal/util"
+)
+
+// loadTestJWKS serves a JWKS with a fresh RSA key, loads it and returns the private key
+func loadTestJWKS(t *testing.T) *rsa.PrivateKey {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key: %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]interface{}{{
+				"kty": "RSA",
+				"kid": "authz-test-key",
+				"n":   base64.RawURLEncoding.EncodeToString(privateKey.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(privateKey.E)).Bytes()),
+			}},
+		})
+	}))
+	defer server.Close()
+
+	if err := util.FetchJWKS(server.URL); err != nil {
+		t.Fatalf("FetchJWKS failed: %v", err)
+	}
+	return privateKey
+}
+
+func signTestToken(t *testing.T, key *rsa.PrivateKey, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "authz-test-key"
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+	return signed
+}
+
+func TestValidateTokenPrincipal(t *testing.T) {
+	key := loadTestJWKS(t)
+	expiry := time.Now().Add(time.Hour).Unix()
+	token := signTestToken(t, key, jwt.MapClaims{
+		"sub":   "user-123",
+		"iss":   "https://idp.example.com",
+		"aud":   []string{"mcp-proxy", "other"},
+		"azp":   "client-abc",
+		"scope": "mcp:read mcp:write",
+		"exp":   expiry,
+	})
+
+	provider := NewDefaultProvider(&config.Config{})
+	req := httptest.NewRequest("GET", "/sse", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	principal, err := provider.ValidateToken(req)
+	if err != nil {
+		t.Fatalf("ValidateToken failed: %v", err)
+	}
+
+	if principal.Subject != "user-123" || principal.ClientID != "client-abc" || principal.Issuer != "https://idp.example.com" {
+		t.Errorf("Unexpected principal identity: %+v", principal)
+	}
+	if !reflect.DeepEqual(principal.Scopes, []string{"mcp:read", "mcp:write"}) || !principal.HasScope("mcp:write") {
+		t.Errorf("Unexpected scopes: %v", principal.Scopes)
+	}
+	if !reflect.DeepEqual(principal.Audience, []string{"mcp-proxy", "other"}) {
+		t.Errorf("Unexpected audience: %v", principal.Audience)
+	}
+	if principal.ExpiresAt.Unix() != expiry || principal.Token != token {
+		t.Errorf("Expected expiry and raw token on the principal")
+	}
+
+	req.Header.Set("Authorization", "Basic dXNlcjpwYXNz")
+	if _, err := provider.ValidateToken(req); err == nil {
+		t.Errorf("Expected error for non-bearer authorization")
+	}
+}
+
+func TestKeycloakValidateTokenMapsRoles(t *testing.T) {
+	key := loadTestJWKS(t)
+	token := signTestToken(t, key, jwt.MapClaims{
+		"sub":          "user-123",
+		"exp":          time.Now().Add(time.Hour).Unix(),
+		"realm_access": map[string]interface{}{"roles": []string{"mcp-user"}},
+	})
+
+	provider := NewKeycloakProvider(newTestKeycloakConfig("https://kc.example.com", ""))
+	req := httptest.NewRequest("GET", "/sse", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	principal, err := provider.ValidateToken(req)
+	if err != nil {
+		t.Fatalf("ValidateToken failed: %v", err)
+	}
+	if !principal.HasScope("mcp-user") {
+		t.Errorf("Expected realm role as scope, got %v", principal.Scopes)
+	}
+}
+
+func TestProtectedResourceHandler(t *testing.T) {
+	provider := NewDefaultProvider(&config.Config{RequiredScopes: []string{"mcp:read"}})
+
+	req := httptest.NewRequest("GET", ProtectedResourcePath, nil)
+	req.Host = "proxy.example.com"
+	req.Header.Set("X-Forwarded-Proto", "https")
+	w := httptest.NewRecorder()
+	ProtectedResourceHandler(provider)(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status OK, got %v", w.Code)
+	}
+
+	var metadata ResourceMetadata
+	if err := json.NewDecoder(w.Body).Decode(&metadata); err != nil {
+		t.Fatalf("Failed to decode response JSON: %v", err)
+	}
+	if metadata.Resource != "https://proxy.example.com" {
+		t.Errorf("Expected resource https://proxy.example.com, got %s", metadata.Resource)
+	}
+	if !reflect.DeepEqual(metadata.AuthorizationServers, []string{"https://proxy.example.com"}) {
+		t.Errorf("Unexpected authorization servers: %v", metadata.AuthorizationServers)
+	}
+	if !reflect.DeepEqual(metadata.ScopesSupported, []string{"mcp:read"}) {
+		t.Errorf("Unexpected scopes: %v", metadata.ScopesSupported)
+	}
+}
+
+func TestCertificateBoundToken(t *testing.T) {
+	key := loadTestJWKS(t)
+	clientCert := &x509.Certificate{Raw: []byte("client certificate")}
+	otherCert := &x509.Certificate{Raw: []byte("other certificate")}
+
+	token := signTestToken(t, key, jwt.MapClaims{
+		"sub": "user-123",
+		"exp": time.Now().Add(time.Hour).Unix(),
+		"cnf": map[string]interface{}{"x5t#S256": util.CertificateThumbprint(clientCert)},
+	})
+	provider := NewDefaultProvider(&config.Config{})
+
+	tests := []struct {
+		name  string
+		state *tls.ConnectionState
+		valid bool
+	}{
+		{"Matching certificate", &tls.ConnectionState{PeerCertificates: []*x509.Certificate{clientCert}}, true},
+		{"Other certificate", &tls.ConnectionState{PeerCertificates: []*x509.Certificate{otherCert}}, false},
+		{"No client certificate", &tls.ConnectionState{}, false},
+		{"Plain HTTP", nil, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/sse", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			req.TLS = tc.state
+
+			_, err := provider.ValidateToken(req)
+			if tc.valid && err != nil {
+				t.Errorf("Expected the token to be accepted, got %v", err)
+			}
+			if !tc.valid && err == nil {
+				t.Errorf("Expected the token to be rejected")
+			}
+		})
+	}
+}
+
+func TestValidateTokenIssuerAndAudience(t *testing.T) {
+	key := loadTestJWKS(t)
+	cfg := &config.Config{TokenValidation: config.TokenValidationConfig{
+		Issuer:    "https://idp.example.com",
+		Audiences: []string{"https://mcp.example.com", "mcp-server"},
+	}}
+	tests := []struct {
+		name  string
+		iss   string
+		aud   interface{}
+		valid bool
+	}{
+		{"Expected issuer and audience", "https://idp.example.com", "mcp-server", true},
+		{"Expected audience among others", "https://idp.example.com", []string{"other", "https://mcp.example.com"}, true},
+		{"Other issuer", "https://evil.example.com", "mcp-server", false},
+		{"Missing issuer", "", "mcp-server", false},
+		{"Other audience", "https://idp.example.com", "other", false},
+		{"Missing audience", "https://idp.example.com", nil, false},
+	}
+
+	provider := NewDefaultProvider(cfg)
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			claims := jwt.MapClaims{"sub": "user-123", "exp": time.Now().Add(time.Hour).Unix()}
+			if tc.iss != "" {
+				claims["iss"] = tc.iss
+			}
+			if tc.aud != nil {
+				claims["aud"] = tc.aud
+			}
+			req := httptest.NewRequest("GET", "/sse", nil)
+			req.Header.Set("Authorization", "Bearer "+signTestToken(t, key, claims))
+
+			_, err := provider.ValidateToken(req)
+			if tc.valid && err != nil {
+				t.Errorf("Expected the token to be accepted, got %v", err)
+			}
+			if !tc.valid && FailureReason(err) != FailureInvalidClaims {
+				t.Errorf("Expected reason %s, got %v", FailureInvalidClaims, err)
+			}
+		})
+	}
+}
+
+func TestValidateTokenFailureReason(t *testing.T) {
+	key := loadTestJWKS(t)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key: %v", err)
+	}
+	valid := jwt.MapClaims{"sub": "user-123", "exp": time.Now().Add(time.Hour).Unix()}
+
+	unknownKid := jwt.NewWithClaims(jwt.SigningMethodRS256, valid)
+	unknownKid.Header["kid"] = "rotated-key"
+	unknownKidToken, _ := unknownKid.SignedString(key)
+
+	tests := []struct {
+		name     string
+		header   string
+		expected string
+	}{
+		{"No token", "", FailureMissingToken},
+		{"Malformed", "Bearer not-a-jwt", FailureMalformed},
+		{"Expired", "Bearer " + signTestToken(t, key, jwt.MapClaims{"sub": "user-123", "exp": time.Now().Add(-time.Hour).Unix()}), FailureExpired},
+		{"Not yet valid", "Bearer " + signTestToken(t, key, jwt.MapClaims{"sub": "user-123", "nbf": time.Now().Add(time.Hour).Unix()}), FailureNotYetValid},
+		{"Unknown key", "Bearer " + unknownKidToken, FailureUnknownKey},
+		{"Wrong signature", "Bearer " + signTestToken(t, otherKey, valid), FailureInvalidSignature},
+	}
+
+	provider := NewDefaultProvider(&config.Config{})
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/sse", nil)
+			if tc.header != "" {
+				req.Header.Set("Authorization", tc.header)
+			}
+			_, err := provider.ValidateToken(req)
+			if err == nil {
+				t.Fatalf("Expected the token to be rejected")
+			}
+			if got := FailureReason(err); got != tc.expected {
+				t.Errorf("Expected reason %s, got %s (%v)", tc.expected, got, err)
+			}
+		})
+	}
+
+	if got := FailureReason(errors.New("introspection failed")); got != FailureInvalid {
+		t.Errorf("Expected reason %s for other errors, got %s", FailureInvalid, got)
+	}
+}
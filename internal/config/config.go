@@ -1,11 +1,16 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"math"
 	"net"
 	"net/url"
 	"os"
+	"regexp"
 	"runtime"
+	"strconv"
 	"strings"
 
 	"gopkg.in/yaml.v2"
@@ -38,12 +43,295 @@ type DemoConfig struct {
 	ClientID     string `yaml:"client_id"`
 	ClientSecret string `yaml:"client_secret"`
 	OrgName      string `yaml:"org_name"`
+
+	AppTemplate     map[string]interface{} `yaml:"app_template,omitempty"`      // Application payload as a YAML sub-tree
+	AppTemplateFile string                 `yaml:"app_template_file,omitempty"` // Go text/template rendering the JSON payload
 }
 
 type AsgardeoConfig struct {
 	ClientID     string `yaml:"client_id"`
 	ClientSecret string `yaml:"client_secret"`
 	OrgName      string `yaml:"org_name"`
+
+	AppTemplate     map[string]interface{} `yaml:"app_template,omitempty"`      // Application payload as a YAML sub-tree
+	AppTemplateFile string                 `yaml:"app_template_file,omitempty"` // Go text/template rendering the JSON payload
+}
+
+// KeycloakConfig contains the realm and client registration settings for Keycloak
+type KeycloakConfig struct {
+	BaseURL      string `yaml:"base_url"`      // Keycloak server URL, e.g. https://keycloak.example.com
+	Realm        string `yaml:"realm"`         // Realm that issues the tokens
+	ClientID     string `yaml:"client_id"`     // Service account client allowed to manage clients
+	ClientSecret string `yaml:"client_secret"` // Secret of the service account client
+
+	// Registration selects how /register creates clients: "admin" uses the
+	// admin REST API, "dcr" uses Keycloak's native dynamic client registration
+	// with InitialAccessToken, and "none" disables registration.
+	Registration       string `yaml:"registration,omitempty"`
+	InitialAccessToken string `yaml:"initial_access_token,omitempty"`
+
+	// RoleScopes maps realm roles ("role") and client roles ("client:role")
+	// to scopes. When empty, every role is exposed as a scope under its own name.
+	RoleScopes map[string]string `yaml:"role_scopes,omitempty"`
+}
+
+// TransportConfig configures an outbound HTTP client
+type TransportConfig struct {
+	CAFile         string `yaml:"ca_file,omitempty"`         // PEM bundle trusted in addition to the system roots
+	ProxyURL       string `yaml:"proxy_url,omitempty"`       // Outbound HTTP proxy, defaults to the environment
+	TimeoutSeconds int    `yaml:"timeout_seconds,omitempty"` // Overall request timeout, defaults to timeout_seconds
+
+	// Client certificate presented to servers that require mutual TLS
+	CertFile string `yaml:"cert_file,omitempty"`
+	KeyFile  string `yaml:"key_file,omitempty"`
+
+	// ServerName overrides the name used for SNI and certificate verification
+	ServerName string `yaml:"server_name,omitempty"`
+
+	DisableHTTP2                 bool `yaml:"disable_http2,omitempty"`
+	MaxIdleConns                 int  `yaml:"max_idle_conns,omitempty"`                  // Defaults to 100
+	MaxIdleConnsPerHost          int  `yaml:"max_idle_conns_per_host,omitempty"`         // Defaults to Go's default of 2
+	IdleConnTimeoutSeconds       int  `yaml:"idle_conn_timeout_seconds,omitempty"`       // Defaults to 90
+	DialTimeoutSeconds           int  `yaml:"dial_timeout_seconds,omitempty"`            // Defaults to 30
+	ResponseHeaderTimeoutSeconds int  `yaml:"response_header_timeout_seconds,omitempty"` // No limit by default
+
+	// InsecureSkipVerify disables TLS certificate verification.
+	// Only meant for local development against self-signed servers.
+	InsecureSkipVerify bool `yaml:"insecure_skip_verify,omitempty"`
+}
+
+// SocketConfig sets the permissions of a Unix socket the proxy listens on
+type SocketConfig struct {
+	Mode  string `yaml:"mode,omitempty"`  // Octal file mode, defaults to 0660
+	Owner string `yaml:"owner,omitempty"` // User name or ID, defaults to the proxy's user
+	Group string `yaml:"group,omitempty"` // Group name or ID, defaults to the proxy's group
+}
+
+// IdentityConfig controls how the authenticated principal is passed to the MCP server
+type IdentityConfig struct {
+	// StripAuthorization removes the user's bearer token before proxying
+	StripAuthorization bool `yaml:"strip_authorization,omitempty"`
+
+	// Headers maps header names to token claims, e.g. X-MCP-User: sub.
+	// "scope" resolves to the granted scopes and "client_id" to the client.
+	Headers map[string]string `yaml:"headers,omitempty"`
+
+	InternalToken InternalTokenConfig `yaml:"internal_token,omitempty"`
+}
+
+// InternalTokenConfig configures the short-lived JWT the proxy signs for the MCP server
+type InternalTokenConfig struct {
+	Enabled        bool   `yaml:"enabled"`
+	Header         string `yaml:"header,omitempty"`           // Defaults to X-MCP-Identity
+	Issuer         string `yaml:"issuer,omitempty"`           // Defaults to open-mcp-auth-proxy
+	Audience       string `yaml:"audience,omitempty"`         // Expected by the MCP server
+	TTLSeconds     int    `yaml:"ttl_seconds,omitempty"`      // Defaults to 60
+	SigningKeyFile string `yaml:"signing_key_file,omitempty"` // PEM RSA, EC or Ed25519 private key
+	HMACSecret     string `yaml:"hmac_secret,omitempty"`      // Shared secret for HS256 of at least 32 bytes, if no key file is set
+	KeyID          string `yaml:"key_id,omitempty"`           // Optional kid header
+}
+
+// TokenExchangeConfig configures OAuth 2.0 Token Exchange (RFC 8693) of the
+// user's token for a token scoped to the MCP server
+type TokenExchangeConfig struct {
+	Enabled            bool   `yaml:"enabled"`
+	TokenEndpoint      string `yaml:"token_endpoint,omitempty"` // Defaults to the auth server's /token
+	ClientID           string `yaml:"client_id"`
+	ClientSecret       string `yaml:"client_secret"`
+	AuthMethod         string `yaml:"auth_method,omitempty"`          // client_secret_basic (default) or client_secret_post
+	Audience           string `yaml:"audience,omitempty"`             // Logical name of the MCP server
+	Resource           string `yaml:"resource,omitempty"`             // URI of the MCP server
+	Scope              string `yaml:"scope,omitempty"`                // Space separated scopes to request
+	RequestedTokenType string `yaml:"requested_token_type,omitempty"` // Defaults to the server's choice
+}
+
+// VaultConfig configures the per-user credential vault for stdio servers.
+// Each user gets their own server process with their credentials in its environment.
+type VaultConfig struct {
+	Enabled            bool               `yaml:"enabled"`
+	File               string             `yaml:"file,omitempty"`                 // Encrypted vault file, defaults to vault.enc
+	KeyFile            string             `yaml:"key_file,omitempty"`             // 32-byte key (raw, hex or base64), or MCP_VAULT_KEY
+	BasePort           int                `yaml:"base_port,omitempty"`            // First port for per-user servers, defaults to port+1
+	MaxProcesses       int                `yaml:"max_processes,omitempty"`        // Defaults to 20
+	IdleTimeoutSeconds int                `yaml:"idle_timeout_seconds,omitempty"` // Stop idle user servers, defaults to 900
+	Credentials        []CredentialConfig `yaml:"credentials"`
+}
+
+// CredentialConfig describes a credential users connect to the vault
+type CredentialConfig struct {
+	Name     string `yaml:"name"`               // e.g. github
+	Env      string `yaml:"env"`                // Environment variable set for the user's server
+	Type     string `yaml:"type,omitempty"`     // "paste" (default) or "oauth"
+	Optional bool   `yaml:"optional,omitempty"` // Start the server even if it's not connected
+
+	// OAuth settings, for type "oauth"
+	AuthorizeURL string   `yaml:"authorize_url,omitempty"`
+	TokenURL     string   `yaml:"token_url,omitempty"`
+	ClientID     string   `yaml:"client_id,omitempty"`
+	ClientSecret string   `yaml:"client_secret,omitempty"`
+	Scopes       []string `yaml:"scopes,omitempty"`
+}
+
+// DPoPConfig configures DPoP sender-constrained access tokens (RFC 9449)
+type DPoPConfig struct {
+	Enabled            bool     `yaml:"enabled"`
+	Required           bool     `yaml:"required,omitempty"`              // Reject tokens that aren't DPoP-bound
+	Algorithms         []string `yaml:"algorithms,omitempty"`            // Proof algorithms, defaults to ES256, RS256, PS256 and EdDSA
+	ProofMaxAgeSeconds int      `yaml:"proof_max_age_seconds,omitempty"` // Accepted proof age, defaults to 300
+	ReplayCacheSize    int      `yaml:"replay_cache_size,omitempty"`     // Remembered proof IDs, defaults to 100000; proofs are rejected when full
+}
+
+// TLSConfig configures TLS termination and client certificates on the proxy listener
+type TLSConfig struct {
+	Enabled               bool     `yaml:"enabled"`
+	CertFile              string   `yaml:"cert_file"`                         // PEM certificate chain, reloaded when it changes
+	KeyFile               string   `yaml:"key_file"`                          // PEM private key, reloaded when it changes
+	MinVersion            string   `yaml:"min_version,omitempty"`             // "1.2" (default) or "1.3"
+	CipherSuites          []string `yaml:"cipher_suites,omitempty"`           // TLS 1.2 suite names, defaults to Go's secure set
+	ClientCAFile          string   `yaml:"client_ca_file,omitempty"`          // CA bundle for client certificates
+	ClientAuth            string   `yaml:"client_auth,omitempty"`             // none, request, require, verify_if_given or require_and_verify
+	ReloadIntervalSeconds int      `yaml:"reload_interval_seconds,omitempty"` // How often to check for new files, defaults to 30
+}
+
+// RateLimitConfig configures token bucket rate limits for auth endpoints and MCP requests
+type RateLimitConfig struct {
+	Enabled           bool            `yaml:"enabled"`
+	TrustForwardedFor bool            `yaml:"trust_forwarded_for,omitempty"` // Take the client IP from X-Forwarded-For
+	TrustedHops       int             `yaml:"trusted_hops,omitempty"`        // Reverse proxies appending to X-Forwarded-For, defaults to 1
+	MaxKeys           int             `yaml:"max_keys,omitempty"`            // Buckets kept in memory, defaults to 100000
+	Auth              []RateLimitRule `yaml:"auth,omitempty"`                // Authorization server endpoints
+	MCP               []RateLimitRule `yaml:"mcp,omitempty"`                 // SSE and message endpoints
+}
+
+// RateLimitRule limits requests that share the same key
+type RateLimitRule struct {
+	// Key is subject, client_id, ip or tool, or a comma-separated
+	// combination such as "subject,tool"
+	Key               string  `yaml:"key"`
+	RequestsPerMinute float64 `yaml:"requests_per_minute"`
+	Burst             int     `yaml:"burst,omitempty"` // Defaults to 10 seconds worth of requests
+}
+
+// SSEConfig limits long-lived SSE streams. Zero values mean no limit.
+type SSEConfig struct {
+	MaxStreams           int `yaml:"max_streams,omitempty"`             // Across all users
+	MaxStreamsPerSubject int `yaml:"max_streams_per_subject,omitempty"` // Per token subject
+	MaxStreamsPerClient  int `yaml:"max_streams_per_client,omitempty"`  // Per OAuth client
+	IdleTimeoutSeconds   int `yaml:"idle_timeout_seconds,omitempty"`    // Without events or messages on the session
+	MaxLifetimeSeconds   int `yaml:"max_lifetime_seconds,omitempty"`    // Regardless of activity
+
+	// HeartbeatIntervalSeconds sends ": ping" comments when the upstream has
+	// been silent this long, so load balancers don't drop idle streams. 0 disables it.
+	HeartbeatIntervalSeconds int `yaml:"heartbeat_interval_seconds,omitempty"`
+
+	// ReplayBufferSize keeps this many recent events per stream, so clients
+	// reconnecting with Last-Event-ID get the events they missed. 0 disables it.
+	ReplayBufferSize       int `yaml:"replay_buffer_size,omitempty"`
+	ReplayRetentionSeconds int `yaml:"replay_retention_seconds,omitempty"` // How long ended streams can be resumed, defaults to 300
+
+	// Streams are closed when the access token they were opened with expires,
+	// unless this is set
+	KeepAfterTokenExpiry bool `yaml:"keep_after_token_expiry,omitempty"`
+}
+
+// SessionConfig controls how MCP sessions are bound to the principal that opened them
+type SessionConfig struct {
+	TTLSeconds int `yaml:"ttl_seconds,omitempty"` // Lifetime of sessions without an SSE stream, defaults to 86400
+}
+
+// LoggingConfig controls the format and levels of log lines
+type LoggingConfig struct {
+	Format     string            `yaml:"format,omitempty"`      // text or json, defaults to text
+	Level      string            `yaml:"level,omitempty"`       // debug, info, warn or error, defaults to info
+	Subsystems map[string]string `yaml:"subsystems,omitempty"`  // Levels of main, proxy, sse, subprocess and authz
+	AdminToken string            `yaml:"admin_token,omitempty"` // Enables changing levels at /admin/log-levels with this bearer token
+}
+
+// MetricsConfig exposes Prometheus metrics on the proxy's listener
+type MetricsConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Path    string `yaml:"path,omitempty"`  // Defaults to /metrics
+	Token   string `yaml:"token,omitempty"` // Bearer token required to scrape, open when empty
+}
+
+// TokenValidationConfig holds the checks of JWT access tokens beyond their signature
+type TokenValidationConfig struct {
+	Issuer             string   `yaml:"issuer,omitempty"`               // Expected iss claim; the asgardeo, demo and keycloak providers derive it
+	Audiences          []string `yaml:"audiences,omitempty"`            // The aud claim must contain one of these, e.g. the MCP server's resource URI
+	JWKSRefreshSeconds int      `yaml:"jwks_refresh_seconds,omitempty"` // Defaults to 3600
+}
+
+// HealthConfig controls the /status document; /healthz and /readyz are always open
+type HealthConfig struct {
+	StatusToken string `yaml:"status_token,omitempty"` // Bearer token for /status; without it, /status takes MCP access tokens
+}
+
+// TracingConfig exports OpenTelemetry spans of proxied requests
+type TracingConfig struct {
+	Enabled              bool              `yaml:"enabled"`
+	Exporter             string            `yaml:"exporter,omitempty"`               // otlp (default) or stdout
+	Endpoint             string            `yaml:"endpoint,omitempty"`               // OTLP/HTTP traces URL, defaults to http://localhost:4318/v1/traces
+	Headers              map[string]string `yaml:"headers,omitempty"`                // Sent with every export, e.g. for authentication
+	ServiceName          string            `yaml:"service_name,omitempty"`           // Defaults to open-mcp-auth-proxy
+	SampleRatio          *float64          `yaml:"sample_ratio,omitempty"`           // Share of new traces recorded, 0 to 1, defaults to 1
+	BatchSize            int               `yaml:"batch_size,omitempty"`             // Defaults to 512
+	FlushIntervalSeconds int               `yaml:"flush_interval_seconds,omitempty"` // Defaults to 5
+	QueueSize            int               `yaml:"queue_size,omitempty"`             // Spans held before dropping, defaults to 2048
+	Transport            TransportConfig   `yaml:"transport,omitempty"`
+}
+
+// RedactionConfig masks sensitive data in log lines and audit records, on top
+// of the built-in rules for JWTs, bearer tokens, client secrets, authorization
+// codes and keys such as password, secret or token
+type RedactionConfig struct {
+	Keys          []string `yaml:"keys,omitempty"`           // Further keys whose values are masked, in JSON, query strings and tool arguments
+	Patterns      []string `yaml:"patterns,omitempty"`       // Regular expressions whose matches are masked
+	ArgumentPaths []string `yaml:"argument_paths,omitempty"` // Dotted paths in tool arguments, "*" matches any key or element
+}
+
+// AuditConfig records one entry per JSON-RPC exchange on the MCP endpoints:
+// who called which method, tool or resource, with which arguments, and the outcome
+type AuditConfig struct {
+	Enabled               bool          `yaml:"enabled"`
+	Sink                  string        `yaml:"sink,omitempty"` // file, stdout or webhook, defaults to stdout
+	File                  string        `yaml:"file,omitempty"` // For the file sink, defaults to audit.log
+	Webhook               WebhookConfig `yaml:"webhook,omitempty"`
+	RedactKeys            []string      `yaml:"redact_keys,omitempty"`             // Argument keys to redact, besides passwords, secrets and tokens
+	MaxArgumentBytes      int           `yaml:"max_argument_bytes,omitempty"`      // Larger arguments are left out, defaults to 4096
+	PendingTimeoutSeconds int           `yaml:"pending_timeout_seconds,omitempty"` // Requests unanswered this long are recorded without a result, defaults to 300
+}
+
+// WebhookConfig posts audit records in JSON array batches to a collector
+type WebhookConfig struct {
+	URL                  string            `yaml:"url"`
+	Headers              map[string]string `yaml:"headers,omitempty"`                // For example the collector's Authorization header
+	BatchSize            int               `yaml:"batch_size,omitempty"`             // Defaults to 100
+	FlushIntervalSeconds int               `yaml:"flush_interval_seconds,omitempty"` // Defaults to 5
+	QueueSize            int               `yaml:"queue_size,omitempty"`             // Records held while the collector is slow or failing, defaults to 10000
+	Transport            TransportConfig   `yaml:"transport,omitempty"`
+}
+
+// ClusterConfig lets several proxy replicas share MCP sessions. Each SSE
+// session lives on the replica holding its stream; message requests that
+// reach another replica are forwarded to it.
+type ClusterConfig struct {
+	Enabled      bool            `yaml:"enabled"`
+	InstanceURL  string          `yaml:"instance_url"`            // How the other replicas reach this one
+	SharedSecret string          `yaml:"shared_secret"`           // Authenticates requests forwarded between replicas
+	SessionStore string          `yaml:"session_store,omitempty"` // file or redis
+	SessionFile  string          `yaml:"session_file,omitempty"`  // File store path, defaults to sessions.json
+	Redis        RedisConfig     `yaml:"redis,omitempty"`
+	Transport    TransportConfig `yaml:"transport,omitempty"` // Connections to the other replicas
+}
+
+// RedisConfig points at a server speaking the Redis protocol
+type RedisConfig struct {
+	Address        string `yaml:"address,omitempty"` // host:port, defaults to localhost:6379
+	Password       string `yaml:"password,omitempty"`
+	DB             int    `yaml:"db,omitempty"`
+	KeyPrefix      string `yaml:"key_prefix,omitempty"`      // Defaults to "mcp-proxy:session:"
+	TimeoutSeconds int    `yaml:"timeout_seconds,omitempty"` // Per command, defaults to 5
+	PoolSize       int    `yaml:"pool_size,omitempty"`       // Concurrent connections, defaults to 8
 }
 
 type CORSConfig struct {
@@ -88,23 +376,74 @@ type DefaultConfig struct {
 
 type Config struct {
 	AuthServerBaseURL string
-	ListenPort        int    `yaml:"listen_port"`
-	BaseURL           string `yaml:"base_url"`
-	Port              int    `yaml:"port"`
-	ExternalHost      string `yaml:"external_host"`
+	ListenPort        int          `yaml:"listen_port"` // Deprecated: use listen
+	Listen            string       `yaml:"listen"`      // "host:port" or "unix:/path.sock", defaults to ":<listen_port>"
+	ListenSocket      SocketConfig `yaml:"listen_socket,omitempty"`
+	BaseURL           string       `yaml:"base_url"`
+	Port              int          `yaml:"port"`
+	ExternalHost      string       `yaml:"external_host"`
 	JWKSURL           string
-	TimeoutSeconds    int               `yaml:"timeout_seconds"`
-	PathMapping       map[string]string `yaml:"path_mapping"`
-	Mode              string            `yaml:"mode"`
-	CORSConfig        CORSConfig        `yaml:"cors"`
-	TransportMode     TransportMode     `yaml:"transport_mode"`
-	Paths             PathsConfig       `yaml:"paths"`
-	Stdio             StdioConfig       `yaml:"stdio"`
+	TimeoutSeconds    int                   `yaml:"timeout_seconds"`
+	PathMapping       map[string]string     `yaml:"path_mapping"`
+	Provider          string                `yaml:"provider"` // Name of the registered auth provider
+	Mode              string                `yaml:"mode"`     // Set to the selected provider; not read from the file
+	CORSConfig        CORSConfig            `yaml:"cors"`
+	TransportMode     TransportMode         `yaml:"transport_mode"`
+	Paths             PathsConfig           `yaml:"paths"`
+	Stdio             StdioConfig           `yaml:"stdio"`
+	Outbound          TransportConfig       `yaml:"outbound"`                  // Identity provider and JWKS calls
+	Upstream          TransportConfig       `yaml:"upstream,omitempty"`        // Connections to the MCP server
+	RequiredScopes    []string              `yaml:"required_scopes,omitempty"` // Scopes every MCP request must carry
+	TokenValidation   TokenValidationConfig `yaml:"token_validation,omitempty"`
+	Identity          IdentityConfig        `yaml:"identity_propagation,omitempty"`
+	TokenExchange     TokenExchangeConfig   `yaml:"token_exchange,omitempty"`
+	Vault             VaultConfig           `yaml:"vault,omitempty"`
+	DPoP              DPoPConfig            `yaml:"dpop,omitempty"`
+	TLS               TLSConfig             `yaml:"tls,omitempty"`
+	RateLimit         RateLimitConfig       `yaml:"rate_limit,omitempty"`
+	SSE               SSEConfig             `yaml:"sse,omitempty"`
+	Sessions          SessionConfig         `yaml:"sessions,omitempty"`
+	Cluster           ClusterConfig         `yaml:"cluster,omitempty"`
+	Audit             AuditConfig           `yaml:"audit,omitempty"`
+	Redaction         RedactionConfig       `yaml:"redaction,omitempty"`
+	Logging           LoggingConfig         `yaml:"logging,omitempty"`
+	Metrics           MetricsConfig         `yaml:"metrics,omitempty"`
+	Tracing           TracingConfig         `yaml:"tracing,omitempty"`
+	Health            HealthConfig          `yaml:"health,omitempty"`
 
 	// Nested config for Asgardeo
 	Demo     DemoConfig     `yaml:"demo"`
 	Asgardeo AsgardeoConfig `yaml:"asgardeo"`
 	Default  DefaultConfig  `yaml:"default"`
+	Keycloak KeycloakConfig `yaml:"keycloak"`
+
+	// Raw top-level sections, used by providers that define their own config
+	sections map[string]interface{}
+
+	// SHA-256 of the config file, empty for configs not loaded from a file
+	hash string
+}
+
+// Hash returns the hex SHA-256 of the config file the config was loaded from
+func (c *Config) Hash() string {
+	return c.hash
+}
+
+// DecodeSection decodes the top-level config section with the given name into out
+func (c *Config) DecodeSection(name string, out interface{}) error {
+	section, ok := c.sections[name]
+	if !ok {
+		return fmt.Errorf("config section %q not found", name)
+	}
+
+	raw, err := yaml.Marshal(section)
+	if err != nil {
+		return fmt.Errorf("failed to read config section %q: %v", name, err)
+	}
+	if err := yaml.Unmarshal(raw, out); err != nil {
+		return fmt.Errorf("invalid config section %q: %v", name, err)
+	}
+	return nil
 }
 
 // GetExternalHost returns the external host with environment variable taking precedence
@@ -220,6 +559,441 @@ func validateBaseURLForTransportMode(baseURL string, transportMode TransportMode
 	return nil
 }
 
+// validateAppTemplate ensures at most one application template source is set
+// and that a template file is readable
+func validateAppTemplate(section string, tree map[string]interface{}, file string) error {
+	if file == "" {
+		return nil
+	}
+	if len(tree) > 0 {
+		return fmt.Errorf("%s.app_template and %s.app_template_file are mutually exclusive", section, section)
+	}
+	if _, err := os.Stat(file); err != nil {
+		return fmt.Errorf("%s.app_template_file is not readable: %v", section, err)
+	}
+	return nil
+}
+
+// validateListen derives the listen address from listen_port when unset and checks it
+func validateListen(c *Config) error {
+	if c.Listen == "" {
+		c.Listen = fmt.Sprintf(":%d", c.ListenPort)
+	}
+
+	if strings.HasPrefix(c.Listen, "unix:") {
+		if SocketPath(c.Listen) == "" {
+			return fmt.Errorf("listen must include a socket path after unix:")
+		}
+		if c.ListenSocket.Mode == "" {
+			c.ListenSocket.Mode = "0660"
+		}
+		if _, err := strconv.ParseUint(c.ListenSocket.Mode, 8, 32); err != nil {
+			return fmt.Errorf("listen_socket.mode must be an octal file mode: %v", err)
+		}
+		return nil
+	}
+
+	if _, _, err := net.SplitHostPort(c.Listen); err != nil {
+		return fmt.Errorf("listen must be host:port or unix:/path: %v", err)
+	}
+	return nil
+}
+
+// SocketPath returns the socket path of a "unix:" address, or "" for other addresses
+func SocketPath(address string) string {
+	path, ok := strings.CutPrefix(address, "unix:")
+	if !ok {
+		return ""
+	}
+	// Accept unix:///path as well as unix:/path
+	return strings.TrimPrefix(path, "//")
+}
+
+// validateTransport checks the settings of an outbound HTTP transport
+func validateTransport(name string, tc *TransportConfig) error {
+	if (tc.CertFile == "") != (tc.KeyFile == "") {
+		return fmt.Errorf("%s.cert_file and %s.key_file must be set together", name, name)
+	}
+	if tc.MaxIdleConns < 0 || tc.MaxIdleConnsPerHost < 0 || tc.IdleConnTimeoutSeconds < 0 ||
+		tc.DialTimeoutSeconds < 0 || tc.ResponseHeaderTimeoutSeconds < 0 || tc.TimeoutSeconds < 0 {
+		return fmt.Errorf("%s connection limits and timeouts must not be negative", name)
+	}
+	return nil
+}
+
+// validateIdentity applies internal token defaults and checks that it can be signed
+func validateIdentity(identity *IdentityConfig) error {
+	token := &identity.InternalToken
+	if !token.Enabled {
+		return nil
+	}
+	if token.SigningKeyFile == "" && token.HMACSecret == "" {
+		return fmt.Errorf("identity_propagation.internal_token requires signing_key_file or hmac_secret")
+	}
+	if token.SigningKeyFile != "" {
+		if _, err := os.Stat(token.SigningKeyFile); err != nil {
+			return fmt.Errorf("identity_propagation.internal_token.signing_key_file is not readable: %v", err)
+		}
+	} else if len(token.HMACSecret) < 32 {
+		return fmt.Errorf("identity_propagation.internal_token.hmac_secret must be at least 32 bytes")
+	}
+	if token.Header == "" {
+		token.Header = "X-MCP-Identity"
+	}
+	if token.Issuer == "" {
+		token.Issuer = "open-mcp-auth-proxy"
+	}
+	if token.TTLSeconds <= 0 {
+		token.TTLSeconds = 60
+	}
+	return nil
+}
+
+// validateTokenExchange checks that an enabled token exchange names its target
+func validateTokenExchange(exchange *TokenExchangeConfig) error {
+	if !exchange.Enabled {
+		return nil
+	}
+	if exchange.Audience == "" && exchange.Resource == "" {
+		return fmt.Errorf("token_exchange requires an audience or resource")
+	}
+	switch exchange.AuthMethod {
+	case "":
+		exchange.AuthMethod = "client_secret_basic"
+	case "client_secret_basic", "client_secret_post":
+	default:
+		return fmt.Errorf("token_exchange.auth_method must be client_secret_basic or client_secret_post, got %q", exchange.AuthMethod)
+	}
+	return nil
+}
+
+// validateVault applies vault defaults and checks the credential definitions
+func validateVault(vault *VaultConfig, port int) error {
+	if !vault.Enabled {
+		return nil
+	}
+	if vault.File == "" {
+		vault.File = "vault.enc"
+	}
+	if vault.BasePort == 0 {
+		vault.BasePort = port + 1
+	}
+	if vault.MaxProcesses <= 0 {
+		vault.MaxProcesses = 20
+	}
+	if vault.IdleTimeoutSeconds <= 0 {
+		vault.IdleTimeoutSeconds = 900
+	}
+
+	names := make(map[string]bool)
+	for i := range vault.Credentials {
+		cred := &vault.Credentials[i]
+		if cred.Name == "" || cred.Env == "" {
+			return fmt.Errorf("vault.credentials[%d] requires a name and env", i)
+		}
+		if names[cred.Name] {
+			return fmt.Errorf("vault.credentials: duplicate name %q", cred.Name)
+		}
+		names[cred.Name] = true
+
+		switch cred.Type {
+		case "":
+			cred.Type = "paste"
+		case "paste":
+		case "oauth":
+			if cred.AuthorizeURL == "" || cred.TokenURL == "" || cred.ClientID == "" {
+				return fmt.Errorf("vault.credentials %q: oauth requires authorize_url, token_url and client_id", cred.Name)
+			}
+		default:
+			return fmt.Errorf("vault.credentials %q: type must be paste or oauth, got %q", cred.Name, cred.Type)
+		}
+	}
+	return nil
+}
+
+// validateDPoP applies DPoP defaults and rejects symmetric proof algorithms
+func validateDPoP(dpop *DPoPConfig) error {
+	if !dpop.Enabled {
+		return nil
+	}
+	if len(dpop.Algorithms) == 0 {
+		dpop.Algorithms = []string{"ES256", "RS256", "PS256", "EdDSA"}
+	}
+	for _, alg := range dpop.Algorithms {
+		if alg == "none" || strings.HasPrefix(alg, "HS") {
+			return fmt.Errorf("dpop.algorithms: %s can't be used for proofs", alg)
+		}
+	}
+	if dpop.ProofMaxAgeSeconds <= 0 {
+		dpop.ProofMaxAgeSeconds = 300
+	}
+	if dpop.ReplayCacheSize <= 0 {
+		dpop.ReplayCacheSize = 100000
+	}
+	return nil
+}
+
+// validateTLS applies TLS listener defaults and checks the client authentication mode
+func validateTLS(t *TLSConfig) error {
+	if !t.Enabled {
+		return nil
+	}
+	if t.CertFile == "" || t.KeyFile == "" {
+		return fmt.Errorf("tls requires cert_file and key_file")
+	}
+	switch t.MinVersion {
+	case "":
+		t.MinVersion = "1.2"
+	case "1.2", "1.3":
+	default:
+		return fmt.Errorf("tls.min_version must be 1.2 or 1.3, got %q", t.MinVersion)
+	}
+	switch t.ClientAuth {
+	case "":
+		t.ClientAuth = "none"
+	case "none", "request", "require", "verify_if_given", "require_and_verify":
+	default:
+		return fmt.Errorf("tls.client_auth %q is not supported", t.ClientAuth)
+	}
+	if (t.ClientAuth == "verify_if_given" || t.ClientAuth == "require_and_verify") && t.ClientCAFile == "" {
+		return fmt.Errorf("tls.client_auth %s requires client_ca_file", t.ClientAuth)
+	}
+	if t.ReloadIntervalSeconds <= 0 {
+		t.ReloadIntervalSeconds = 30
+	}
+	return nil
+}
+
+// validateRateLimit applies rate limit defaults and checks the rule keys
+func validateRateLimit(rl *RateLimitConfig) error {
+	if !rl.Enabled {
+		return nil
+	}
+	if rl.MaxKeys <= 0 {
+		rl.MaxKeys = 100000
+	}
+	if rl.TrustedHops < 0 {
+		return fmt.Errorf("rate_limit.trusted_hops must not be negative")
+	}
+	if rl.TrustedHops == 0 {
+		rl.TrustedHops = 1
+	}
+
+	validate := func(section string, rules []RateLimitRule, keys map[string]bool) error {
+		for i := range rules {
+			rule := &rules[i]
+			if rule.RequestsPerMinute <= 0 {
+				return fmt.Errorf("rate_limit.%s[%d].requests_per_minute must be positive", section, i)
+			}
+			for _, key := range strings.Split(rule.Key, ",") {
+				if !keys[strings.TrimSpace(key)] {
+					return fmt.Errorf("rate_limit.%s[%d].key %q is not supported", section, i, rule.Key)
+				}
+			}
+			if rule.Burst <= 0 {
+				rule.Burst = int(math.Ceil(rule.RequestsPerMinute / 6))
+			}
+		}
+		return nil
+	}
+
+	// Auth requests carry no token, so only the caller can be identified
+	if err := validate("auth", rl.Auth, map[string]bool{"client_id": true, "ip": true}); err != nil {
+		return err
+	}
+	return validate("mcp", rl.MCP, map[string]bool{"subject": true, "client_id": true, "ip": true, "tool": true})
+}
+
+// validateLogging checks the log format and levels
+func validateLogging(l *LoggingConfig) error {
+	switch l.Format {
+	case "":
+		l.Format = "text"
+	case "text", "json":
+	default:
+		return fmt.Errorf("logging.format must be text or json, got %q", l.Format)
+	}
+
+	valid := map[string]bool{"": true, "debug": true, "info": true, "warn": true, "error": true}
+	if !valid[strings.ToLower(l.Level)] {
+		return fmt.Errorf("logging.level must be debug, info, warn or error, got %q", l.Level)
+	}
+	for name, level := range l.Subsystems {
+		if !valid[strings.ToLower(level)] {
+			return fmt.Errorf("logging.subsystems.%s must be debug, info, warn or error, got %q", name, level)
+		}
+	}
+	if l.AdminToken != "" && len(l.AdminToken) < 16 {
+		return fmt.Errorf("logging.admin_token must be at least 16 characters")
+	}
+	return nil
+}
+
+// validateMetrics applies the default metrics path and checks the token
+func validateMetrics(m *MetricsConfig) error {
+	if !m.Enabled {
+		return nil
+	}
+	if m.Path == "" {
+		m.Path = "/metrics"
+	}
+	if !strings.HasPrefix(m.Path, "/") {
+		return fmt.Errorf("metrics.path must start with /, got %q", m.Path)
+	}
+	if m.Token != "" && len(m.Token) < 16 {
+		return fmt.Errorf("metrics.token must be at least 16 characters")
+	}
+	return nil
+}
+
+// validateTokenValidation applies the JWKS refresh default
+func validateTokenValidation(v *TokenValidationConfig) error {
+	if v.JWKSRefreshSeconds < 0 {
+		return fmt.Errorf("token_validation.jwks_refresh_seconds must not be negative")
+	}
+	if v.JWKSRefreshSeconds == 0 {
+		v.JWKSRefreshSeconds = 3600
+	}
+	return nil
+}
+
+// validateHealth checks the status endpoint settings
+func validateHealth(h *HealthConfig) error {
+	if h.StatusToken != "" && len(h.StatusToken) < 16 {
+		return fmt.Errorf("health.status_token must be at least 16 characters")
+	}
+	return nil
+}
+
+// validateTracing applies tracing defaults and checks the exporter
+func validateTracing(t *TracingConfig) error {
+	if !t.Enabled {
+		return nil
+	}
+	if t.ServiceName == "" {
+		t.ServiceName = "open-mcp-auth-proxy"
+	}
+	if t.SampleRatio == nil {
+		ratio := 1.0
+		t.SampleRatio = &ratio
+	} else if *t.SampleRatio < 0 || *t.SampleRatio > 1 {
+		return fmt.Errorf("tracing.sample_ratio must be between 0 and 1, got %v", *t.SampleRatio)
+	}
+	if t.BatchSize <= 0 {
+		t.BatchSize = 512
+	}
+	if t.FlushIntervalSeconds <= 0 {
+		t.FlushIntervalSeconds = 5
+	}
+	if t.QueueSize <= 0 {
+		t.QueueSize = 2048
+	}
+
+	switch t.Exporter {
+	case "", "otlp":
+		t.Exporter = "otlp"
+		if t.Endpoint == "" {
+			t.Endpoint = "http://localhost:4318/v1/traces"
+		}
+		endpoint, err := url.Parse(t.Endpoint)
+		if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+			return fmt.Errorf("tracing.endpoint must be an http or https URL")
+		}
+		return validateTransport("tracing.transport", &t.Transport)
+	case "stdout":
+	default:
+		return fmt.Errorf("tracing.exporter must be otlp or stdout, got %q", t.Exporter)
+	}
+	return nil
+}
+
+// validateRedaction checks that the redaction patterns compile
+func validateRedaction(r *RedactionConfig) error {
+	for _, pattern := range r.Patterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid redaction pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// validateAudit applies audit defaults and checks the sink
+func validateAudit(a *AuditConfig) error {
+	if !a.Enabled {
+		return nil
+	}
+	if a.MaxArgumentBytes <= 0 {
+		a.MaxArgumentBytes = 4096
+	}
+	if a.PendingTimeoutSeconds <= 0 {
+		a.PendingTimeoutSeconds = 300
+	}
+
+	switch a.Sink {
+	case "", "stdout":
+		a.Sink = "stdout"
+	case "file":
+		if a.File == "" {
+			a.File = "audit.log"
+		}
+	case "webhook":
+		hook, err := url.Parse(a.Webhook.URL)
+		if err != nil || (hook.Scheme != "http" && hook.Scheme != "https") || hook.Host == "" {
+			return fmt.Errorf("audit.webhook.url must be an http or https URL")
+		}
+		if a.Webhook.BatchSize <= 0 {
+			a.Webhook.BatchSize = 100
+		}
+		if a.Webhook.FlushIntervalSeconds <= 0 {
+			a.Webhook.FlushIntervalSeconds = 5
+		}
+		if a.Webhook.QueueSize <= 0 {
+			a.Webhook.QueueSize = 10000
+		}
+		return validateTransport("audit.webhook.transport", &a.Webhook.Transport)
+	default:
+		return fmt.Errorf("audit.sink must be file, stdout or webhook, got %q", a.Sink)
+	}
+	return nil
+}
+
+// validateCluster applies cluster defaults and checks that replicas can reach each other
+func validateCluster(cl *ClusterConfig) error {
+	if !cl.Enabled {
+		return nil
+	}
+	instance, err := url.Parse(cl.InstanceURL)
+	if err != nil || instance.Scheme == "" || instance.Host == "" {
+		return fmt.Errorf("cluster.instance_url must be an absolute URL")
+	}
+	if len(cl.SharedSecret) < 32 {
+		return fmt.Errorf("cluster.shared_secret must be at least 32 characters")
+	}
+
+	switch cl.SessionStore {
+	case "file":
+		if cl.SessionFile == "" {
+			cl.SessionFile = "sessions.json"
+		}
+	case "redis":
+		if cl.Redis.Address == "" {
+			cl.Redis.Address = "localhost:6379"
+		}
+		if cl.Redis.KeyPrefix == "" {
+			cl.Redis.KeyPrefix = "mcp-proxy:session:"
+		}
+		if cl.Redis.TimeoutSeconds <= 0 {
+			cl.Redis.TimeoutSeconds = 5
+		}
+		if cl.Redis.PoolSize <= 0 {
+			cl.Redis.PoolSize = 8
+		}
+	default:
+		return fmt.Errorf("cluster.session_store must be file or redis, got %q", cl.SessionStore)
+	}
+	return validateTransport("cluster.transport", &cl.Transport)
+}
+
 // Validate checks if the config is valid based on transport mode
 func (c *Config) Validate() error {
 	// Validate based on transport mode
@@ -231,12 +1005,87 @@ func (c *Config) Validate() error {
 			return fmt.Errorf("stdio.user_command is required in stdio transport mode")
 		}
 
+		// The stdio server is started on a TCP port
+		if SocketPath(c.BaseURL) != "" {
+			return fmt.Errorf("BaseURL validation failed: stdio transport mode does not support unix: base URLs")
+		}
+
 		// Validate that BaseURL points to localhost when using stdio
 		if err := validateBaseURLForTransportMode(c.BaseURL, c.TransportMode); err != nil {
 			return fmt.Errorf("BaseURL validation failed: %v", err)
 		}
 	}
 
+	// Validate Asgardeo application templates
+	if err := validateAppTemplate("demo", c.Demo.AppTemplate, c.Demo.AppTemplateFile); err != nil {
+		return err
+	}
+	if err := validateAppTemplate("asgardeo", c.Asgardeo.AppTemplate, c.Asgardeo.AppTemplateFile); err != nil {
+		return err
+	}
+
+	if err := validateListen(c); err != nil {
+		return err
+	}
+	if err := validateTransport("outbound", &c.Outbound); err != nil {
+		return err
+	}
+	if err := validateTransport("upstream", &c.Upstream); err != nil {
+		return err
+	}
+	if err := validateIdentity(&c.Identity); err != nil {
+		return err
+	}
+	if err := validateTokenValidation(&c.TokenValidation); err != nil {
+		return err
+	}
+	if err := validateTokenExchange(&c.TokenExchange); err != nil {
+		return err
+	}
+	if err := validateVault(&c.Vault, c.Port); err != nil {
+		return err
+	}
+	if err := validateDPoP(&c.DPoP); err != nil {
+		return err
+	}
+	if err := validateTLS(&c.TLS); err != nil {
+		return err
+	}
+	if err := validateRateLimit(&c.RateLimit); err != nil {
+		return err
+	}
+	if c.SSE.MaxStreams < 0 || c.SSE.MaxStreamsPerSubject < 0 || c.SSE.MaxStreamsPerClient < 0 ||
+		c.SSE.IdleTimeoutSeconds < 0 || c.SSE.MaxLifetimeSeconds < 0 || c.SSE.HeartbeatIntervalSeconds < 0 {
+		return fmt.Errorf("sse limits and timeouts must not be negative")
+	}
+	if c.SSE.ReplayBufferSize > 0 && c.SSE.ReplayRetentionSeconds <= 0 {
+		c.SSE.ReplayRetentionSeconds = 300
+	}
+	if c.Sessions.TTLSeconds <= 0 {
+		c.Sessions.TTLSeconds = 86400
+	}
+	if err := validateCluster(&c.Cluster); err != nil {
+		return err
+	}
+	if err := validateAudit(&c.Audit); err != nil {
+		return err
+	}
+	if err := validateRedaction(&c.Redaction); err != nil {
+		return err
+	}
+	if err := validateLogging(&c.Logging); err != nil {
+		return err
+	}
+	if err := validateMetrics(&c.Metrics); err != nil {
+		return err
+	}
+	if err := validateTracing(&c.Tracing); err != nil {
+		return err
+	}
+	if err := validateHealth(&c.Health); err != nil {
+		return err
+	}
+
 	// Validate paths
 	if c.Paths.SSE == "" {
 		c.Paths.SSE = "/sse" // Default value
@@ -285,17 +1134,22 @@ func (c *Config) BuildExecCommand() string {
 
 // LoadConfig reads a YAML config file into Config struct.
 func LoadConfig(path string) (*Config, error) {
-	f, err := os.Open(path)
+	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
-	defer f.Close()
 
 	var cfg Config
-	decoder := yaml.NewDecoder(f)
-	if err := decoder.Decode(&cfg); err != nil {
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+
+	// Keep the raw sections for providers with their own configuration
+	if err := yaml.Unmarshal(data, &cfg.sections); err != nil {
 		return nil, err
 	}
+	sum := sha256.Sum256(data)
+	cfg.hash = hex.EncodeToString(sum[:])
 
 	// Set default values
 	if cfg.TimeoutSeconds == 0 {
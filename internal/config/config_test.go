@@ -1,6 +1,8 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"os"
 	"path/filepath"
 	"testing"
@@ -45,6 +47,9 @@ cors:
 	if cfg.ListenPort != 8080 {
 		t.Errorf("Expected ListenPort=8080, got %d", cfg.ListenPort)
 	}
+	if cfg.Listen != ":8080" {
+		t.Errorf("Expected Listen=:8080, got %s", cfg.Listen)
+	}
 	if cfg.BaseURL != "http://localhost:8000" {
 		t.Errorf("Expected BaseURL=http://localhost:8000, got %s", cfg.BaseURL)
 	}
@@ -65,6 +70,10 @@ cors:
 	if cfg.Port != 8000 {
 		t.Errorf("Expected default Port=8000, got %d", cfg.Port)
 	}
+	sum := sha256.Sum256([]byte(validConfig))
+	if cfg.Hash() != hex.EncodeToString(sum[:]) {
+		t.Errorf("Expected the hash of the config file, got %s", cfg.Hash())
+	}
 }
 
 func TestValidate(t *testing.T) {
@@ -118,6 +127,211 @@ func TestValidate(t *testing.T) {
 			},
 			expectError: true,
 		},
+		{
+			name: "Valid unix socket listen address",
+			config: Config{
+				TransportMode: SSETransport,
+				Listen:        "unix:/run/mcp-proxy.sock",
+				BaseURL:       "unix:/run/mcp-server.sock",
+			},
+			expectError: false,
+		},
+		{
+			name: "Invalid listen address",
+			config: Config{
+				TransportMode: SSETransport,
+				Listen:        "8080",
+			},
+			expectError: true,
+		},
+		{
+			name: "Invalid listen socket mode",
+			config: Config{
+				TransportMode: SSETransport,
+				Listen:        "unix:/run/mcp-proxy.sock",
+				ListenSocket:  SocketConfig{Mode: "rw-rw----"},
+			},
+			expectError: true,
+		},
+		{
+			name: "Invalid stdio config - unix base URL",
+			config: Config{
+				TransportMode: StdioTransport,
+				Stdio: StdioConfig{
+					Enabled:     true,
+					UserCommand: "some-command",
+				},
+				BaseURL: "unix:/run/mcp-server.sock",
+			},
+			expectError: true,
+		},
+		{
+			name: "Valid cluster config",
+			config: Config{
+				TransportMode: SSETransport,
+				Cluster: ClusterConfig{
+					Enabled:      true,
+					InstanceURL:  "http://proxy-1:8080",
+					SharedSecret: "0123456789abcdef0123456789abcdef",
+					SessionStore: "redis",
+				},
+			},
+			expectError: false,
+		},
+		{
+			name: "Invalid cluster config - short secret",
+			config: Config{
+				TransportMode: SSETransport,
+				Cluster: ClusterConfig{
+					Enabled:      true,
+					InstanceURL:  "http://proxy-1:8080",
+					SharedSecret: "secret",
+					SessionStore: "file",
+				},
+			},
+			expectError: true,
+		},
+		{
+			name: "Invalid cluster config - unknown store",
+			config: Config{
+				TransportMode: SSETransport,
+				Cluster: ClusterConfig{
+					Enabled:      true,
+					InstanceURL:  "http://proxy-1:8080",
+					SharedSecret: "0123456789abcdef0123456789abcdef",
+					SessionStore: "memcached",
+				},
+			},
+			expectError: true,
+		},
+		{
+			name: "Valid audit webhook config",
+			config: Config{
+				TransportMode: SSETransport,
+				Audit: AuditConfig{
+					Enabled: true,
+					Sink:    "webhook",
+					Webhook: WebhookConfig{URL: "https://collector.example.com/audit"},
+				},
+			},
+			expectError: false,
+		},
+		{
+			name: "Invalid audit config - webhook without URL",
+			config: Config{
+				TransportMode: SSETransport,
+				Audit:         AuditConfig{Enabled: true, Sink: "webhook"},
+			},
+			expectError: true,
+		},
+		{
+			name: "Invalid audit config - unknown sink",
+			config: Config{
+				TransportMode: SSETransport,
+				Audit:         AuditConfig{Enabled: true, Sink: "syslog"},
+			},
+			expectError: true,
+		},
+		{
+			name: "Invalid redaction pattern",
+			config: Config{
+				TransportMode: SSETransport,
+				Redaction:     RedactionConfig{Patterns: []string{"[unclosed"}},
+			},
+			expectError: true,
+		},
+		{
+			name: "Valid logging config",
+			config: Config{
+				TransportMode: SSETransport,
+				Logging: LoggingConfig{
+					Format:     "json",
+					Level:      "warn",
+					Subsystems: map[string]string{"sse": "debug"},
+				},
+			},
+			expectError: false,
+		},
+		{
+			name: "Invalid logging config - unknown level",
+			config: Config{
+				TransportMode: SSETransport,
+				Logging:       LoggingConfig{Subsystems: map[string]string{"proxy": "verbose"}},
+			},
+			expectError: true,
+		},
+		{
+			name: "Invalid logging config - short admin token",
+			config: Config{
+				TransportMode: SSETransport,
+				Logging:       LoggingConfig{AdminToken: "secret"},
+			},
+			expectError: true,
+		},
+		{
+			name: "Valid metrics config",
+			config: Config{
+				TransportMode: SSETransport,
+				Metrics:       MetricsConfig{Enabled: true},
+			},
+			expectError: false,
+		},
+		{
+			name: "Invalid metrics config - relative path",
+			config: Config{
+				TransportMode: SSETransport,
+				Metrics:       MetricsConfig{Enabled: true, Path: "metrics"},
+			},
+			expectError: true,
+		},
+		{
+			name: "Valid tracing config",
+			config: Config{
+				TransportMode: SSETransport,
+				Tracing:       TracingConfig{Enabled: true, Endpoint: "https://otel.example.com/v1/traces"},
+			},
+			expectError: false,
+		},
+		{
+			name: "Invalid tracing config - unknown exporter",
+			config: Config{
+				TransportMode: SSETransport,
+				Tracing:       TracingConfig{Enabled: true, Exporter: "zipkin"},
+			},
+			expectError: true,
+		},
+		{
+			name: "Invalid tracing config - sample ratio",
+			config: Config{
+				TransportMode: SSETransport,
+				Tracing:       TracingConfig{Enabled: true, SampleRatio: func() *float64 { r := 1.5; return &r }()},
+			},
+			expectError: true,
+		},
+		{
+			name: "Invalid identity config - short HMAC secret",
+			config: Config{
+				TransportMode: SSETransport,
+				Identity:      IdentityConfig{InternalToken: InternalTokenConfig{Enabled: true, HMACSecret: "shared-secret"}},
+			},
+			expectError: true,
+		},
+		{
+			name: "Invalid token validation config - negative JWKS refresh",
+			config: Config{
+				TransportMode:   SSETransport,
+				TokenValidation: TokenValidationConfig{JWKSRefreshSeconds: -1},
+			},
+			expectError: true,
+		},
+		{
+			name: "Invalid health config - short status token",
+			config: Config{
+				TransportMode: SSETransport,
+				Health:        HealthConfig{StatusToken: "secret"},
+			},
+			expectError: true,
+		},
 	}
 
 	for _, tc := range tests {
@@ -194,3 +408,42 @@ func TestBuildExecCommand(t *testing.T) {
 		})
 	}
 }
+
+func TestDecodeSection(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "custom_provider.yaml")
+	content := `
+listen_port: 8080
+provider: "acme"
+acme:
+  tenant: "example"
+  scopes:
+    - "read"
+    - "write"
+`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create test config file: %v", err)
+	}
+
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if cfg.Provider != "acme" {
+		t.Errorf("Expected Provider=acme, got %s", cfg.Provider)
+	}
+
+	var section struct {
+		Tenant string   `yaml:"tenant"`
+		Scopes []string `yaml:"scopes"`
+	}
+	if err := cfg.DecodeSection("acme", &section); err != nil {
+		t.Fatalf("DecodeSection failed: %v", err)
+	}
+	if section.Tenant != "example" || len(section.Scopes) != 2 {
+		t.Errorf("Unexpected section contents: %+v", section)
+	}
+
+	if err := cfg.DecodeSection("missing", &section); err == nil {
+		t.Errorf("Expected error for missing section")
+	}
+}
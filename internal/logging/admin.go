@@ -0,0 +1,63 @@
+package logger
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// LevelsPath is where the log levels can be read and changed at runtime
+const LevelsPath = "/admin/log-levels"
+
+// levelChange is the body of a request changing a level
+type levelChange struct {
+	Subsystem string `json:"subsystem"` // Empty for every subsystem
+	Level     string `json:"level"`
+}
+
+// LevelsHandler serves the current levels on GET and changes a level on PUT
+// or POST. Requests must carry the admin token as a bearer token.
+func LevelsHandler(token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		presented := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "" || subtle.ConstantTimeCompare([]byte(presented), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut, http.MethodPost:
+			var change levelChange
+			if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&change); err != nil {
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+			level, err := ParseLevel(change.Level)
+			if err == nil {
+				err = SetLevel(change.Subsystem, level)
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			subsystem := change.Subsystem
+			if subsystem == "" {
+				subsystem = "all subsystems"
+			}
+			Info("Log level of %s set to %s", subsystem, strings.ToLower(level.String()))
+		default:
+			w.Header().Set("Allow", "GET, PUT, POST")
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"levels": Levels(),
+			"debug":  forceDebug.Load(),
+		})
+	})
+}
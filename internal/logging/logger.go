@@ -1,34 +1,328 @@
 package logger
 
 import (
-	"log"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/wso2/open-mcp-auth-proxy/internal/config"
+)
+
+// Subsystems whose levels can be set on their own. Lines logged through the
+// package-level functions belong to Main.
+const (
+	Main       = "main"
+	Proxy      = "proxy"
+	SSE        = "sse"
+	Subprocess = "subprocess"
+	Authz      = "authz"
+)
+
+// Subsystems lists the subsystems with their own level
+var Subsystems = []string{Main, Proxy, SSE, Subprocess, Authz}
+
+var (
+	mu      sync.RWMutex
+	handler slog.Handler = newHandler(os.Stderr, "text")
+	levels               = newLevels()
+
+	// forceDebug logs everything at debug level, set by -debug or toggled by signal
+	forceDebug atomic.Bool
 )
 
-var isDebug = false
+// subsystemLevels holds the level of each subsystem, changeable at runtime
+type subsystemLevels map[string]*slog.LevelVar
+
+func newLevels() subsystemLevels {
+	l := make(subsystemLevels)
+	for _, name := range Subsystems {
+		l[name] = new(slog.LevelVar) // Info
+	}
+	return l
+}
+
+// Configure sets the output format and levels. Lines are written to stderr.
+func Configure(cfg config.LoggingConfig) error {
+	base, err := ParseLevel(cfg.Level)
+	if err != nil {
+		return err
+	}
+	configured := newLevels()
+	for _, name := range Subsystems {
+		configured[name].Set(base)
+	}
+	for name, value := range cfg.Subsystems {
+		level, err := ParseLevel(value)
+		if err != nil {
+			return err
+		}
+		if configured[name] == nil {
+			return fmt.Errorf("unknown log subsystem %q, expected one of %s", name, strings.Join(Subsystems, ", "))
+		}
+		configured[name].Set(level)
+	}
+
+	h := newHandler(os.Stderr, cfg.Format)
+	mu.Lock()
+	handler = h
+	levels = configured
+	mu.Unlock()
+
+	// Lines of the standard log package, such as http.Server errors, go through the same handler
+	slog.SetDefault(slog.New(stdlogHandler{}))
+	return nil
+}
+
+// SetOutput writes log lines to w in the given format, text or json
+func SetOutput(w io.Writer, format string) {
+	h := newHandler(w, format)
+	mu.Lock()
+	defer mu.Unlock()
+	handler = h
+}
+
+func newHandler(w io.Writer, format string) slog.Handler {
+	// Levels are checked per subsystem before records reach the handler
+	opts := &slog.HandlerOptions{Level: slog.LevelDebug}
+	if format == "json" {
+		return &redactingHandler{slog.NewJSONHandler(w, opts)}
+	}
+	return &redactingHandler{slog.NewTextHandler(w, opts)}
+}
+
+// ParseLevel parses debug, info, warn or error; empty means info
+func ParseLevel(value string) (slog.Level, error) {
+	var level slog.Level
+	if value == "" {
+		return slog.LevelInfo, nil
+	}
+	if err := level.UnmarshalText([]byte(value)); err != nil {
+		return 0, fmt.Errorf("invalid log level %q, expected debug, info, warn or error", value)
+	}
+	return level, nil
+}
+
+// SetLevel changes the level of a subsystem, or of all subsystems when name is empty
+func SetLevel(name string, level slog.Level) error {
+	mu.RLock()
+	defer mu.RUnlock()
+	if name == "" {
+		for _, v := range levels {
+			v.Set(level)
+		}
+		return nil
+	}
+	v, ok := levels[name]
+	if !ok {
+		return fmt.Errorf("unknown log subsystem %q", name)
+	}
+	v.Set(level)
+	return nil
+}
+
+// Levels returns the current level of each subsystem
+func Levels() map[string]string {
+	mu.RLock()
+	defer mu.RUnlock()
+	out := make(map[string]string, len(levels))
+	for name, v := range levels {
+		out[name] = strings.ToLower(v.Level().String())
+	}
+	return out
+}
 
-// SetDebug enables or disables debug logging
+// SetDebug enables or disables debug logging for every subsystem
 func SetDebug(debug bool) {
-	isDebug = debug
+	forceDebug.Store(debug)
 }
 
-// Debug logs a debug-level message
-func Debug(format string, v ...interface{}) {
-	if isDebug {
-		log.Printf("DEBUG: "+format, v...)
+// ToggleDebug switches debug logging for every subsystem on or off and
+// reports whether it's now on
+func ToggleDebug() bool {
+	for {
+		current := forceDebug.Load()
+		if forceDebug.CompareAndSwap(current, !current) {
+			return !current
+		}
 	}
 }
 
-// Info logs an info-level message
-func Info(format string, v ...interface{}) {
-	log.Printf("INFO: "+format, v...)
+func enabled(subsystem string, level slog.Level) bool {
+	if forceDebug.Load() {
+		return true
+	}
+	mu.RLock()
+	v, ok := levels[subsystem]
+	if !ok {
+		v = levels[Main]
+	}
+	mu.RUnlock()
+	return level >= v.Level()
+}
+
+func currentHandler() slog.Handler {
+	mu.RLock()
+	defer mu.RUnlock()
+	return handler
+}
+
+// Logger logs printf-style messages for a subsystem, with structured fields
+type Logger struct {
+	subsystem string
+	attrs     []slog.Attr
+}
+
+// For returns the logger of a subsystem
+func For(subsystem string) *Logger {
+	return &Logger{subsystem: subsystem}
+}
+
+// With returns a logger adding the key-value pairs to every line
+func (l *Logger) With(args ...interface{}) *Logger {
+	return &Logger{subsystem: l.subsystem, attrs: append(append([]slog.Attr{}, l.attrs...), toAttrs(args)...)}
+}
+
+// Ctx returns a logger adding the request fields stored in ctx to every line
+func (l *Logger) Ctx(ctx context.Context) *Logger {
+	fields, _ := ctx.Value(fieldsKey{}).([]slog.Attr)
+	if len(fields) == 0 {
+		return l
+	}
+	return &Logger{subsystem: l.subsystem, attrs: append(append([]slog.Attr{}, fields...), l.attrs...)}
 }
 
+// Debug logs a debug-level message
+func (l *Logger) Debug(format string, v ...interface{}) { l.log(slog.LevelDebug, format, v...) }
+
+// Info logs an info-level message
+func (l *Logger) Info(format string, v ...interface{}) { l.log(slog.LevelInfo, format, v...) }
+
 // Warn logs a warning-level message
-func Warn(format string, v ...interface{}) {
-	log.Printf("WARN: "+format, v...)
+func (l *Logger) Warn(format string, v ...interface{}) { l.log(slog.LevelWarn, format, v...) }
+
+// Error logs an error-level message
+func (l *Logger) Error(format string, v ...interface{}) { l.log(slog.LevelError, format, v...) }
+
+func (l *Logger) log(level slog.Level, format string, v ...interface{}) {
+	if !enabled(l.subsystem, level) {
+		return
+	}
+	record := slog.NewRecord(time.Now(), level, fmt.Sprintf(format, v...), 0)
+	record.AddAttrs(slog.String("subsystem", l.subsystem))
+	record.AddAttrs(l.attrs...)
+	if err := currentHandler().Handle(context.Background(), record); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write log line: %v\n", err)
+	}
 }
 
+var mainLogger = For(Main)
+
+// Debug logs a debug-level message
+func Debug(format string, v ...interface{}) { mainLogger.log(slog.LevelDebug, format, v...) }
+
+// Info logs an info-level message
+func Info(format string, v ...interface{}) { mainLogger.log(slog.LevelInfo, format, v...) }
+
+// Warn logs a warning-level message
+func Warn(format string, v ...interface{}) { mainLogger.log(slog.LevelWarn, format, v...) }
+
 // Error logs an error-level message
-func Error(format string, v ...interface{}) {
-	log.Printf("ERROR: "+format, v...)
+func Error(format string, v ...interface{}) { mainLogger.log(slog.LevelError, format, v...) }
+
+type fieldsKey struct{}
+
+// WithFields returns a context carrying request fields, as key-value pairs,
+// that loggers obtained through Ctx add to their lines
+func WithFields(ctx context.Context, args ...interface{}) context.Context {
+	fields, _ := ctx.Value(fieldsKey{}).([]slog.Attr)
+	return context.WithValue(ctx, fieldsKey{}, append(append([]slog.Attr{}, fields...), toAttrs(args)...))
+}
+
+func toAttrs(args []interface{}) []slog.Attr {
+	var attrs []slog.Attr
+	for i := 0; i+1 < len(args); i += 2 {
+		key, _ := args[i].(string)
+		if value, ok := args[i+1].(string); ok && value == "" {
+			continue // Unknown fields, such as the subject of an anonymous request, are left out
+		}
+		attrs = append(attrs, slog.Any(key, args[i+1]))
+	}
+	return attrs
+}
+
+// redactingHandler masks secrets in messages and string fields
+type redactingHandler struct {
+	next slog.Handler
+}
+
+func (h *redactingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *redactingHandler) Handle(ctx context.Context, r slog.Record) error {
+	redacted := slog.NewRecord(r.Time, r.Level, Redact(r.Message), r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		redacted.AddAttrs(redactAttr(a))
+		return true
+	})
+	return h.next.Handle(ctx, redacted)
+}
+
+func (h *redactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redacted[i] = redactAttr(a)
+	}
+	return &redactingHandler{h.next.WithAttrs(redacted)}
+}
+
+func (h *redactingHandler) WithGroup(name string) slog.Handler {
+	return &redactingHandler{h.next.WithGroup(name)}
+}
+
+func redactAttr(a slog.Attr) slog.Attr {
+	a.Value = a.Value.Resolve()
+	switch a.Value.Kind() {
+	case slog.KindString:
+		if CurrentRedactor().Sensitive(a.Key) {
+			return slog.String(a.Key, Redacted)
+		}
+		return slog.String(a.Key, Redact(a.Value.String()))
+	case slog.KindGroup:
+		group := a.Value.Group()
+		redacted := make([]interface{}, len(group))
+		for i, member := range group {
+			redacted[i] = redactAttr(member)
+		}
+		return slog.Group(a.Key, redacted...)
+	}
+	return a
+}
+
+// stdlogHandler passes lines of the standard log package to the current
+// handler as the main subsystem
+type stdlogHandler struct{}
+
+func (stdlogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return enabled(Main, level)
+}
+
+func (stdlogHandler) Handle(ctx context.Context, r slog.Record) error {
+	r = r.Clone()
+	r.AddAttrs(slog.String("subsystem", Main))
+	return currentHandler().Handle(ctx, r)
+}
+
+func (h stdlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return currentHandler().WithAttrs(attrs)
+}
+
+func (h stdlogHandler) WithGroup(name string) slog.Handler {
+	return currentHandler().WithGroup(name)
 }
@@ -0,0 +1,145 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/wso2/open-mcp-auth-proxy/internal/config"
+)
+
+// resetLogging restores the default output and levels after a test
+func resetLogging(t *testing.T) {
+	t.Cleanup(func() {
+		if err := Configure(config.LoggingConfig{}); err != nil {
+			t.Errorf("Failed to reset logging: %v", err)
+		}
+		SetOutput(os.Stderr, "text")
+		SetDebug(false)
+	})
+}
+
+func TestSubsystemLevels(t *testing.T) {
+	resetLogging(t)
+	err := Configure(config.LoggingConfig{
+		Level:      "warn",
+		Subsystems: map[string]string{SSE: "debug"},
+	})
+	if err != nil {
+		t.Fatalf("Configure failed: %v", err)
+	}
+	var buf bytes.Buffer
+	SetOutput(&buf, "text")
+
+	For(Proxy).Info("proxy info")
+	For(Proxy).Warn("proxy warning")
+	For(SSE).Debug("sse debug")
+	Info("main info")
+
+	out := buf.String()
+	for _, expected := range []string{"proxy warning", "sse debug"} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("Expected %q to be logged, got %q", expected, out)
+		}
+	}
+	for _, unexpected := range []string{"proxy info", "main info"} {
+		if strings.Contains(out, unexpected) {
+			t.Errorf("Expected %q to be filtered, got %q", unexpected, out)
+		}
+	}
+
+	// Levels change at runtime, and -debug overrides them
+	if err := SetLevel(Proxy, slog.LevelInfo); err != nil {
+		t.Fatalf("SetLevel failed: %v", err)
+	}
+	SetDebug(true)
+	Debug("main debug")
+	For(Proxy).Info("proxy info again")
+	if out := buf.String(); !strings.Contains(out, "main debug") || !strings.Contains(out, "proxy info again") {
+		t.Errorf("Expected runtime level changes to apply, got %q", out)
+	}
+
+	if err := Configure(config.LoggingConfig{Subsystems: map[string]string{"database": "info"}}); err == nil {
+		t.Errorf("Expected an unknown subsystem to be rejected")
+	}
+}
+
+func TestJSONOutputWithRequestFields(t *testing.T) {
+	resetLogging(t)
+	if err := Configure(config.LoggingConfig{Format: "json"}); err != nil {
+		t.Fatalf("Configure failed: %v", err)
+	}
+	var buf bytes.Buffer
+	SetOutput(&buf, "json")
+
+	ctx := WithFields(context.Background(), "request_id", "req-1", "path", "/messages")
+	ctx = WithFields(ctx, "subject", "alice", "session", "")
+	For(Authz).Ctx(ctx).Warn("Token rejected: %s", "expired")
+
+	var line map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("Expected a JSON line, got %q: %v", buf.String(), err)
+	}
+	expected := map[string]string{
+		"level":      "WARN",
+		"msg":        "Token rejected: expired",
+		"subsystem":  Authz,
+		"request_id": "req-1",
+		"path":       "/messages",
+		"subject":    "alice",
+	}
+	for key, value := range expected {
+		if line[key] != value {
+			t.Errorf("Expected %s to be %q, got %v", key, value, line[key])
+		}
+	}
+	if _, ok := line["session"]; ok {
+		t.Errorf("Expected empty fields to be left out, got %v", line)
+	}
+}
+
+func TestLevelsHandler(t *testing.T) {
+	resetLogging(t)
+	const token = "admin-token-0123456789"
+	handler := LevelsHandler(token)
+
+	tests := []struct {
+		name           string
+		method         string
+		auth           string
+		body           string
+		expectedStatus int
+	}{
+		{"No token", http.MethodGet, "", "", http.StatusUnauthorized},
+		{"Wrong token", http.MethodGet, "Bearer wrong-token-0123456789", "", http.StatusUnauthorized},
+		{"Read levels", http.MethodGet, "Bearer " + token, "", http.StatusOK},
+		{"Set level", http.MethodPut, "Bearer " + token, `{"subsystem":"sse","level":"debug"}`, http.StatusOK},
+		{"Unknown subsystem", http.MethodPut, "Bearer " + token, `{"subsystem":"db","level":"debug"}`, http.StatusBadRequest},
+		{"Invalid level", http.MethodPut, "Bearer " + token, `{"level":"loud"}`, http.StatusBadRequest},
+		{"Wrong method", http.MethodDelete, "Bearer " + token, "", http.StatusMethodNotAllowed},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, LevelsPath, strings.NewReader(tc.body))
+			if tc.auth != "" {
+				req.Header.Set("Authorization", tc.auth)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tc.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tc.expectedStatus, rec.Code)
+			}
+		})
+	}
+
+	if got := Levels()[SSE]; got != "debug" {
+		t.Errorf("Expected the sse level to be debug, got %q", got)
+	}
+}
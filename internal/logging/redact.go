@@ -0,0 +1,227 @@
+package logger
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/wso2/open-mcp-auth-proxy/internal/config"
+)
+
+// Redacted replaces masked values
+const Redacted = "[REDACTED]"
+
+// defaultRedactKeys mask the values of keys containing them, ignoring case,
+// "-" and "_", in JSON, query strings and form bodies
+var defaultRedactKeys = []string{
+	"password", "passwd", "secret", "token", "apikey", "authorization",
+	"cookie", "privatekey", "credential", "assertion", "codeverifier",
+}
+
+// safeKeySuffixes mark keys that merely describe a secret, such as
+// token_type or token_endpoint, so their values stay readable
+var safeKeySuffixes = []string{"type", "endpoint", "method", "methods", "supported", "expiresin"}
+
+var (
+	// Signed or encrypted JWTs, whose header always encodes to "eyJ"
+	jwtPattern = regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_.-]*`)
+	// Credentials of HTTP authorization schemes, long enough not to be parameters
+	authSchemePattern = regexp.MustCompile(`(?i)\b(Bearer|DPoP|Basic)(\s+)[A-Za-z0-9\-._~+/]{16,}=*`)
+	// "key": "value" pairs in JSON
+	jsonPairPattern = regexp.MustCompile(`"([^"\\]{1,100})"(\s*:\s*)"(?:[^"\\]|\\.)*"`)
+	// key=value pairs in query strings, form bodies and plain text
+	queryPairPattern = regexp.MustCompile(`(^|[?&;,\s(])([A-Za-z0-9_.\-\[\]]{1,100})=([^&\s"'<>,;)]*)`)
+)
+
+// Redactor masks secrets in log lines and audit records: JWTs, bearer tokens,
+// values of sensitive keys, configured patterns and configured paths in tool arguments
+type Redactor struct {
+	keys     []string // Normalized
+	patterns []*regexp.Regexp
+	paths    [][]string
+}
+
+// NewRedactor builds a redactor with the built-in rules plus those in cfg
+func NewRedactor(cfg config.RedactionConfig) (*Redactor, error) {
+	r := &Redactor{keys: append([]string{}, defaultRedactKeys...)}
+	r.addKeys(cfg.Keys)
+	for _, pattern := range cfg.Patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	for _, path := range cfg.ArgumentPaths {
+		if path = strings.TrimPrefix(strings.TrimSpace(path), "$."); path != "" {
+			r.paths = append(r.paths, strings.Split(path, "."))
+		}
+	}
+	return r, nil
+}
+
+// WithKeys returns a copy of the redactor that also masks keys
+func (r *Redactor) WithKeys(keys []string) *Redactor {
+	c := &Redactor{
+		keys:     append([]string{}, r.keys...),
+		patterns: r.patterns,
+		paths:    r.paths,
+	}
+	c.addKeys(keys)
+	return c
+}
+
+func (r *Redactor) addKeys(keys []string) {
+	for _, key := range keys {
+		if normalized := normalizeKey(key); normalized != "" {
+			r.keys = append(r.keys, normalized)
+		}
+	}
+}
+
+// String masks the secrets in free text, such as a log line
+func (r *Redactor) String(s string) string {
+	s = jwtPattern.ReplaceAllString(s, Redacted)
+	s = authSchemePattern.ReplaceAllString(s, "${1}${2}"+Redacted)
+	s = jsonPairPattern.ReplaceAllStringFunc(s, func(pair string) string {
+		m := jsonPairPattern.FindStringSubmatch(pair)
+		if !r.Sensitive(m[1]) {
+			return pair
+		}
+		return `"` + m[1] + `"` + m[2] + `"` + Redacted + `"`
+	})
+	s = queryPairPattern.ReplaceAllStringFunc(s, func(pair string) string {
+		m := queryPairPattern.FindStringSubmatch(pair)
+		// Authorization codes go by the plain name "code" in OAuth redirects
+		if m[3] == "" || (!r.Sensitive(m[2]) && m[2] != "code") {
+			return pair
+		}
+		return m[1] + m[2] + "=" + Redacted
+	})
+	for _, re := range r.patterns {
+		s = re.ReplaceAllString(s, Redacted)
+	}
+	return s
+}
+
+// JSON masks the values of sensitive keys at any depth and the values at the
+// configured argument paths. Numbers and booleans under sensitive keys, such
+// as max_tokens, are kept. Data that isn't JSON is masked entirely.
+func (r *Redactor) JSON(data json.RawMessage) json.RawMessage {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return json.RawMessage(`"` + Redacted + `"`)
+	}
+	for _, path := range r.paths {
+		value = maskPath(value, path)
+	}
+	out, err := json.Marshal(r.walk(value))
+	if err != nil {
+		return json.RawMessage(`"` + Redacted + `"`)
+	}
+	return out
+}
+
+func (r *Redactor) walk(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if r.Sensitive(key) {
+				switch field.(type) {
+				case float64, bool, nil:
+				default:
+					v[key] = Redacted
+					continue
+				}
+			}
+			v[key] = r.walk(field)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = r.walk(item)
+		}
+	case string:
+		return r.String(v)
+	}
+	return value
+}
+
+// maskPath masks the value at path, where "*" matches any key or element and
+// numbers match array elements
+func maskPath(value interface{}, path []string) interface{} {
+	if len(path) == 0 {
+		return Redacted
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if path[0] == "*" || path[0] == key {
+				v[key] = maskPath(field, path[1:])
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			if path[0] == "*" || path[0] == strconv.Itoa(i) {
+				v[i] = maskPath(item, path[1:])
+			}
+		}
+	}
+	return value
+}
+
+// Sensitive reports whether the value of key should be masked
+func (r *Redactor) Sensitive(key string) bool {
+	normalized := normalizeKey(key)
+	for _, suffix := range safeKeySuffixes {
+		if strings.HasSuffix(normalized, suffix) {
+			return false
+		}
+	}
+	for _, k := range r.keys {
+		if strings.Contains(normalized, k) {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeKey(key string) string {
+	return strings.NewReplacer("-", "", "_", "", " ", "", ".", "").Replace(strings.ToLower(key))
+}
+
+var (
+	redactorMu sync.RWMutex
+	redactor   = defaultRedactor()
+)
+
+func defaultRedactor() *Redactor {
+	r, _ := NewRedactor(config.RedactionConfig{}) // Only configured patterns can fail
+	return r
+}
+
+// ConfigureRedaction applies the configured rules to every log line and to
+// the redactor returned by CurrentRedactor
+func ConfigureRedaction(cfg config.RedactionConfig) error {
+	r, err := NewRedactor(cfg)
+	if err != nil {
+		return err
+	}
+	redactorMu.Lock()
+	defer redactorMu.Unlock()
+	redactor = r
+	return nil
+}
+
+// CurrentRedactor returns the redactor applied to log lines
+func CurrentRedactor() *Redactor {
+	redactorMu.RLock()
+	defer redactorMu.RUnlock()
+	return redactor
+}
+
+// Redact masks the secrets in s with the configured rules
+func Redact(s string) string {
+	return CurrentRedactor().String(s)
+}
@@ -0,0 +1,24 @@
+//go:build !windows
+
+package logger
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// WatchSignals toggles debug logging for every subsystem on SIGUSR1
+func WatchSignals() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1)
+	go func() {
+		for range signals {
+			if ToggleDebug() {
+				Info("Debug logging enabled by SIGUSR1")
+			} else {
+				Info("Debug logging disabled by SIGUSR1")
+			}
+		}
+	}()
+}
@@ -0,0 +1,7 @@
+//go:build windows
+
+package logger
+
+// WatchSignals does nothing on Windows, which has no SIGUSR1; use the admin
+// endpoint to change levels instead
+func WatchSignals() {}
@@ -0,0 +1,118 @@
+// Package metrics exposes what the proxy is doing in the Prometheus text
+// exposition format
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"sync"
+)
+
+// Route classes of HTTP requests
+const (
+	RouteAuth      = "auth"       // OAuth endpoints: /authorize, /token, /register
+	RouteWellKnown = "well-known" // Discovery metadata
+	RouteMCP       = "mcp"        // MCP traffic, including SSE streams
+	RouteVault     = "vault"      // Credential vault API
+	RouteAdmin     = "admin"      // Metrics, log levels and status
+	RouteHealth    = "health"     // Liveness and readiness probes
+	RouteOther     = "other"
+)
+
+// Results of a JWKS fetch
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+)
+
+// TokenValid is the token validation outcome of accepted tokens; rejected
+// tokens are counted by failure reason
+const TokenValid = "valid"
+
+var (
+	registryMu sync.Mutex
+	registry   []collector
+)
+
+func register(c collector) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry = append(registry, c)
+}
+
+// latencyBuckets spans fast metadata lookups to slow tool calls, in seconds
+var latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+var (
+	// HTTPRequests counts requests by route class and status code
+	HTTPRequests = newCounterVec("mcp_proxy_http_requests_total",
+		"HTTP requests handled, by route class and status code.", "route", "status")
+
+	// HTTPRequestDuration measures request latency by route class and status
+	// code. SSE streams are observed when they end.
+	HTTPRequestDuration = newHistogramVec("mcp_proxy_http_request_duration_seconds",
+		"Time to handle HTTP requests, by route class and status code. SSE streams are observed when they close.",
+		latencyBuckets, "route", "status")
+
+	// SSEConnections is the number of open SSE streams
+	SSEConnections = newGaugeVec("mcp_proxy_sse_connections_active",
+		"SSE streams currently open.")
+
+	// TokenValidations counts access token checks by outcome: valid, or the reason for rejecting the token
+	TokenValidations = newCounterVec("mcp_proxy_token_validations_total",
+		"Access token validations of MCP requests, by outcome: valid or the failure reason.", "outcome")
+
+	// JWKSRefreshes counts JWKS fetches by result
+	JWKSRefreshes = newCounterVec("mcp_proxy_jwks_refreshes_total",
+		"JWKS fetches, by result.", "result")
+
+	// JWKSLastRefresh is when the JWKS was last fetched successfully
+	JWKSLastRefresh = newGaugeVec("mcp_proxy_jwks_last_refresh_timestamp_seconds",
+		"Unix time of the last successful JWKS fetch.")
+
+	// JWKSKeys is the number of signing keys loaded from the JWKS
+	JWKSKeys = newGaugeVec("mcp_proxy_jwks_keys",
+		"Signing keys loaded from the JWKS.")
+
+	// UpstreamErrors counts requests that failed to reach the upstream server, by route class
+	UpstreamErrors = newCounterVec("mcp_proxy_upstream_errors_total",
+		"Requests that failed to reach the auth or MCP server, by route class.", "route")
+
+	// SubprocessRestarts counts restarts of per-user MCP servers by reason
+	SubprocessRestarts = newCounterVec("mcp_proxy_subprocess_restarts_total",
+		"Restarts of MCP server processes, by reason: exited or credentials_changed.", "reason")
+
+	// SubprocessUptime is how long the stdio MCP server has been running
+	SubprocessUptime = newGaugeFunc("mcp_proxy_subprocess_uptime_seconds",
+		"Time since the stdio MCP server process started, 0 when it isn't running.")
+
+	// RPCRequests counts JSON-RPC messages sent by MCP clients, by method
+	RPCRequests = newCounterVec("mcp_proxy_jsonrpc_requests_total",
+		"JSON-RPC messages sent by MCP clients, by method.", "method")
+
+	// AuditRecordsDropped counts audit records the webhook sink lost, by reason
+	AuditRecordsDropped = newCounterVec("mcp_proxy_audit_records_dropped_total",
+		"Audit records lost by the webhook sink, by reason: queue_full or post_failed.", "reason")
+)
+
+// Handler serves every metric in the Prometheus text format
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		registryMu.Lock()
+		collectors := append([]collector{}, registry...)
+		registryMu.Unlock()
+
+		var buf bytes.Buffer
+		for _, c := range collectors {
+			c.write(&buf)
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.Write(buf.Bytes())
+	})
+}
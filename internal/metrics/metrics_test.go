@@ -0,0 +1,73 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestExpositionFormat(t *testing.T) {
+	counter := newCounterVec("test_events_total", "Events seen.", "kind")
+	counter.Inc("a")
+	counter.Add(2, `quote"and\slash`)
+	gauge := newGaugeVec("test_open", "Open things.")
+	gauge.Inc()
+	gauge.Inc()
+	gauge.Dec()
+	histogram := newHistogramVec("test_duration_seconds", "Durations.", []float64{0.1, 1}, "route")
+	histogram.Observe(0.05, "mcp")
+	histogram.Observe(0.1, "mcp")
+	histogram.Observe(3, "mcp")
+	uptime := newGaugeFunc("test_uptime_seconds", "Uptime.")
+	uptime.Set(func() float64 { return 42 })
+	unset := newGaugeFunc("test_unset_seconds", "Never set.")
+
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, req)
+
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Errorf("Expected the text exposition content type, got %q", w.Header().Get("Content-Type"))
+	}
+	out := w.Body.String()
+	expected := []string{
+		"# HELP test_events_total Events seen.\n# TYPE test_events_total counter\n",
+		`test_events_total{kind="a"} 1` + "\n",
+		`test_events_total{kind="quote\"and\\slash"} 2` + "\n",
+		"# TYPE test_open gauge\ntest_open 1\n",
+		"# TYPE test_duration_seconds histogram\n",
+		`test_duration_seconds_bucket{route="mcp",le="0.1"} 2` + "\n",
+		`test_duration_seconds_bucket{route="mcp",le="1"} 2` + "\n",
+		`test_duration_seconds_bucket{route="mcp",le="+Inf"} 3` + "\n",
+		`test_duration_seconds_sum{route="mcp"} 3.15` + "\n",
+		`test_duration_seconds_count{route="mcp"} 3` + "\n",
+		"test_uptime_seconds 42\n",
+		// Metrics without labels are exposed before anything happens
+		"# TYPE mcp_proxy_sse_connections_active gauge\nmcp_proxy_sse_connections_active ",
+	}
+	for _, line := range expected {
+		if !strings.Contains(out, line) {
+			t.Errorf("Expected %q in output:\n%s", line, out)
+		}
+	}
+	if strings.Contains(out, unset.name) {
+		t.Errorf("Expected a gauge without a function to be left out")
+	}
+
+	w = httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest("POST", "/metrics", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status Method Not Allowed, got %d", w.Code)
+	}
+}
+
+func TestLabelCountMismatchPanics(t *testing.T) {
+	counter := newCounterVec("test_mismatch_total", "Mismatch.", "a", "b")
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected a panic for missing label values")
+		}
+	}()
+	counter.Inc("only-one")
+}
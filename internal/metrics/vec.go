@@ -0,0 +1,304 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// collector writes its series in the text exposition format
+type collector interface {
+	write(w io.Writer)
+}
+
+// desc names a metric and its labels
+type desc struct {
+	name   string
+	help   string
+	kind   string // counter, gauge or histogram
+	labels []string
+}
+
+func (d desc) writeHeader(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.name, d.help, d.name, d.kind)
+}
+
+// key identifies a series by its label values
+func (d desc) key(values []string) string {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metric %s takes %d label values, got %d", d.name, len(d.labels), len(values)))
+	}
+	return strings.Join(values, "\xff")
+}
+
+// CounterVec counts events, partitioned by label values
+type CounterVec struct {
+	desc
+	mu     sync.Mutex
+	series map[string]*sample
+}
+
+// GaugeVec holds values that go up and down, partitioned by label values
+type GaugeVec struct {
+	desc
+	mu     sync.Mutex
+	series map[string]*sample
+}
+
+type sample struct {
+	labels []string
+	value  float64
+}
+
+func newCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{desc: desc{name, help, "counter", labels}, series: make(map[string]*sample)}
+	if len(labels) == 0 {
+		c.series[""] = &sample{} // Exposed as 0 before anything happens
+	}
+	register(c)
+	return c
+}
+
+func newGaugeVec(name, help string, labels ...string) *GaugeVec {
+	g := &GaugeVec{desc: desc{name, help, "gauge", labels}, series: make(map[string]*sample)}
+	if len(labels) == 0 {
+		g.series[""] = &sample{}
+	}
+	register(g)
+	return g
+}
+
+// Inc adds one to the series with the label values
+func (c *CounterVec) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add adds v, which must not be negative, to the series with the label values
+func (c *CounterVec) Add(v float64, values ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	lookup(c.series, c.key(values), values).value += v
+}
+
+// Value returns the current count of the series with the label values
+func (c *CounterVec) Value(values ...string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if s, ok := c.series[c.key(values)]; ok {
+		return s.value
+	}
+	return 0
+}
+
+func (c *CounterVec) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	writeSamples(w, c.desc, c.series)
+}
+
+// Set sets the series with the label values to v
+func (g *GaugeVec) Set(v float64, values ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	lookup(g.series, g.key(values), values).value = v
+}
+
+// Add adds v, possibly negative, to the series with the label values
+func (g *GaugeVec) Add(v float64, values ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	lookup(g.series, g.key(values), values).value += v
+}
+
+// Inc adds one to the series with the label values
+func (g *GaugeVec) Inc(values ...string) { g.Add(1, values...) }
+
+// Dec subtracts one from the series with the label values
+func (g *GaugeVec) Dec(values ...string) { g.Add(-1, values...) }
+
+// Value returns the current value of the series with the label values
+func (g *GaugeVec) Value(values ...string) float64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if s, ok := g.series[g.key(values)]; ok {
+		return s.value
+	}
+	return 0
+}
+
+func (g *GaugeVec) write(w io.Writer) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	writeSamples(w, g.desc, g.series)
+}
+
+func lookup(series map[string]*sample, key string, values []string) *sample {
+	s, ok := series[key]
+	if !ok {
+		s = &sample{labels: append([]string{}, values...)}
+		series[key] = s
+	}
+	return s
+}
+
+func writeSamples(w io.Writer, d desc, series map[string]*sample) {
+	d.writeHeader(w)
+	for _, key := range sortedKeys(series) {
+		s := series[key]
+		fmt.Fprintf(w, "%s%s %s\n", d.name, formatLabels(d.labels, s.labels, "", ""), formatValue(s.value))
+	}
+}
+
+// GaugeFunc reports a value read when metrics are scraped, such as an uptime
+type GaugeFunc struct {
+	desc
+	mu sync.Mutex
+	fn func() float64
+}
+
+func newGaugeFunc(name, help string) *GaugeFunc {
+	g := &GaugeFunc{desc: desc{name: name, help: help, kind: "gauge"}}
+	register(g)
+	return g
+}
+
+// Set reads the gauge from fn; a nil fn leaves the gauge out
+func (g *GaugeFunc) Set(fn func() float64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.fn = fn
+}
+
+func (g *GaugeFunc) write(w io.Writer) {
+	g.mu.Lock()
+	fn := g.fn
+	g.mu.Unlock()
+	if fn == nil {
+		return
+	}
+	g.writeHeader(w)
+	fmt.Fprintf(w, "%s %s\n", g.name, formatValue(fn()))
+}
+
+// HistogramVec samples observations, such as latencies, into cumulative
+// buckets, partitioned by label values
+type HistogramVec struct {
+	desc
+	buckets []float64 // Upper bounds, ascending, without +Inf
+	mu      sync.Mutex
+	series  map[string]*histogram
+}
+
+type histogram struct {
+	labels []string
+	counts []uint64 // Per bucket, not cumulative; the last one is +Inf
+	sum    float64
+	count  uint64
+}
+
+func newHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{
+		desc:    desc{name, help, "histogram", labels},
+		buckets: buckets,
+		series:  make(map[string]*histogram),
+	}
+	register(h)
+	return h
+}
+
+// Observe adds v to the series with the label values
+func (h *HistogramVec) Observe(v float64, values ...string) {
+	key := h.key(values)
+	i := sort.SearchFloat64s(h.buckets, v) // First bound >= v
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogram{labels: append([]string{}, values...), counts: make([]uint64, len(h.buckets)+1)}
+		h.series[key] = s
+	}
+	s.counts[i]++
+	s.sum += v
+	s.count++
+}
+
+// Count returns the number of observations of the series with the label values
+func (h *HistogramVec) Count(values ...string) uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if s, ok := h.series[h.key(values)]; ok {
+		return s.count
+	}
+	return 0
+}
+
+func (h *HistogramVec) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.writeHeader(w)
+	for _, key := range sortedKeys(h.series) {
+		s := h.series[key]
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += s.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, s.labels, "le", formatValue(bound)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, s.labels, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, formatLabels(h.labels, s.labels, "", ""), formatValue(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, formatLabels(h.labels, s.labels, "", ""), s.count)
+	}
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// formatLabels renders {name="value",...}, with an extra label when extraName is set
+func formatLabels(names, values []string, extraName, extraValue string) string {
+	if len(names) == 0 && extraName == "" {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, "%s=\"%s\"", name, escapeLabel(values[i]))
+	}
+	if extraName != "" {
+		if len(names) > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, "%s=\"%s\"", extraName, extraValue)
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
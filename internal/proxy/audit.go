@@ -0,0 +1,124 @@
+package proxy
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/wso2/open-mcp-auth-proxy/internal/audit"
+	"github.com/wso2/open-mcp-auth-proxy/internal/authz"
+	"github.com/wso2/open-mcp-auth-proxy/internal/config"
+	"github.com/wso2/open-mcp-auth-proxy/internal/sse"
+)
+
+// auditScope groups the exchanges of a message request. Responses to the
+// legacy SSE transport arrive on the session's stream; Streamable HTTP
+// responses come in the body of the request's own response.
+type auditScope struct {
+	id         string // Empty when the request isn't audited
+	perRequest bool
+}
+
+// auditRequest records the JSON-RPC messages posted to the messages endpoint
+func auditRequest(r *http.Request, cfg *config.Config, auditor *audit.Auditor, principal *authz.Principal) auditScope {
+	if auditor == nil || principal == nil || r.Method != http.MethodPost || !strings.HasPrefix(r.URL.Path, cfg.Paths.Messages) {
+		return auditScope{}
+	}
+
+	scope := auditScope{id: sessionIDFromQuery(r)}
+	if scope.id == "" {
+		scope = auditScope{id: randomID(), perRequest: true}
+	}
+	auditor.Request(scope.id, audit.Caller{
+		Subject:  principal.Subject,
+		ClientID: principal.ClientID,
+		Session:  sessionIDFromRequest(r),
+	}, peekBody(r))
+	return scope
+}
+
+// auditResponse completes the exchanges answered in the HTTP response of a
+// message request
+func auditResponse(resp *http.Response, auditor *audit.Auditor, scope auditScope) {
+	if scope.id == "" {
+		return
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	switch {
+	case strings.Contains(contentType, "application/json"):
+		peek, _ := io.ReadAll(io.LimitReader(resp.Body, maxRPCPeek))
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(peek), resp.Body), resp.Body}
+		auditor.Response(scope.id, peek)
+		abandonRequestScope(auditor, scope)
+	case strings.Contains(contentType, "text/event-stream"):
+		resp.Body = newAuditingBody(resp.Body, auditor, scope)
+	default:
+		abandonRequestScope(auditor, scope)
+	}
+}
+
+// abandonRequestScope records the requests left unanswered when their HTTP response is over
+func abandonRequestScope(auditor *audit.Auditor, scope auditScope) {
+	if scope.perRequest {
+		auditor.Abandon(scope.id)
+	}
+}
+
+// auditingBody passes an SSE response through while a goroutine reads the
+// JSON-RPC responses in its events
+type auditingBody struct {
+	io.ReadCloser
+	pw   *io.PipeWriter
+	done chan struct{} // Closed once the last event is audited
+	once sync.Once
+}
+
+func newAuditingBody(body io.ReadCloser, auditor *audit.Auditor, scope auditScope) io.ReadCloser {
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		reader := sse.NewReader(pr)
+		for {
+			event, err := reader.Next()
+			if err != nil {
+				break
+			}
+			if event.HasData && (event.Type == "" || event.Type == "message") {
+				auditor.Response(scope.id, []byte(event.Data))
+			}
+		}
+		pr.Close()
+		abandonRequestScope(auditor, scope)
+	}()
+	return &auditingBody{ReadCloser: body, pw: pw, done: done}
+}
+
+func (b *auditingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.pw.Write(p[:n])
+	}
+	if err != nil {
+		b.finish()
+	}
+	return n, err
+}
+
+func (b *auditingBody) Close() error {
+	b.finish()
+	return b.ReadCloser.Close()
+}
+
+// finish ends the events and waits until they are audited, so the records
+// are written by the time the response is complete
+func (b *auditingBody) finish() {
+	b.once.Do(func() { b.pw.Close() })
+	<-b.done
+}
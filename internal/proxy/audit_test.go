@@ -0,0 +1,129 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/wso2/open-mcp-auth-proxy/internal/audit"
+	"github.com/wso2/open-mcp-auth-proxy/internal/authz"
+	"github.com/wso2/open-mcp-auth-proxy/internal/config"
+	"github.com/wso2/open-mcp-auth-proxy/internal/session"
+)
+
+// captureSink keeps the audit records written to it
+type captureSink struct {
+	mu      sync.Mutex
+	records []audit.Record
+}
+
+func (s *captureSink) Write(r audit.Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records = append(s.records, r)
+	return nil
+}
+
+func (s *captureSink) Close() error { return nil }
+
+func (s *captureSink) all() []audit.Record {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]audit.Record{}, s.records...)
+}
+
+func newTestAuditor() (*audit.Auditor, *captureSink) {
+	sink := &captureSink{}
+	return audit.New(config.AuditConfig{Enabled: true, MaxArgumentBytes: 4096, PendingTimeoutSeconds: 300}, sink), sink
+}
+
+func TestAuditStreamableHTTPResponses(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+	}{
+		{"JSON response", "application/json", `{"jsonrpc":"2.0","id":1,"result":{}}`},
+		{"SSE response", "text/event-stream", "event: message\ndata: {\"jsonrpc\":\"2.0\",\"id\":1,\"result\":{}}\n\n"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tc.contentType)
+				io.WriteString(w, tc.body)
+			}))
+			defer backend.Close()
+
+			auditor, sink := newTestAuditor()
+			router := NewRouter(newTestConfig(backend.URL), newStubProvider(), WithAuditor(auditor), withTestSessions("streamable-1"))
+
+			req := httptest.NewRequest("POST", "/messages", strings.NewReader(
+				`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"search","arguments":{"token":"abc"}}}`))
+			req.Header.Set("Authorization", "Bearer good-token")
+			req.Header.Set(SessionIDHeader, "streamable-1")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if !strings.Contains(w.Body.String(), `"result"`) {
+				t.Errorf("Expected the response to reach the client, got %q", w.Body.String())
+			}
+			records := sink.all()
+			if len(records) != 1 {
+				t.Fatalf("Expected 1 record, got %+v", records)
+			}
+			r := records[0]
+			if r.Subject != "user-1" || r.Session != "streamable-1" || r.Tool != "search" || r.Outcome != audit.OutcomeSuccess {
+				t.Errorf("Expected a successful search by user-1 on streamable-1, got %+v", r)
+			}
+			if string(r.Arguments) != `{"token":"[REDACTED]"}` {
+				t.Errorf("Expected the token argument to be redacted, got %s", r.Arguments)
+			}
+		})
+	}
+}
+
+func TestAuditResponseOnSSEStream(t *testing.T) {
+	auditor, sink := newTestAuditor()
+	auditor.Request("abc", audit.Caller{Subject: "alice", Session: "abc"},
+		[]byte(`[{"jsonrpc":"2.0","id":1,"method":"tools/list"},{"jsonrpc":"2.0","id":2,"method":"tools/list"}]`))
+
+	body, writer := io.Pipe()
+	go func() {
+		io.WriteString(writer, "event: endpoint\ndata: /messages?sessionId=abc\n\n")
+		io.WriteString(writer, "event: message\ndata: {\"jsonrpc\":\"2.0\",\"id\":1,\"result\":{\"tools\":[]}}\n\n")
+		writer.Close()
+	}()
+	transport := &sseTransport{
+		Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": []string{"text/event-stream"}},
+				Body:       body,
+				Request:    req,
+			}, nil
+		}),
+		proxyHost: "localhost:8080",
+		config:    newTestConfig("http://localhost:8000"),
+		principal: &authz.Principal{Subject: "alice"},
+		sessions:  session.NewMemoryStore(),
+		audit:     auditor,
+	}
+	resp, err := transport.RoundTrip(httptest.NewRequest("GET", "http://localhost:8000/sse", nil))
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	io.ReadAll(resp.Body)
+
+	// The answered request completes; the other is recorded when the stream ends
+	records := sink.all()
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 records, got %+v", records)
+	}
+	outcomes := map[string]bool{records[0].Outcome: true, records[1].Outcome: true}
+	if !outcomes[audit.OutcomeSuccess] || !outcomes[audit.OutcomeNoResponse] {
+		t.Errorf("Expected one success and one unanswered request, got %+v", records)
+	}
+}
@@ -0,0 +1,138 @@
+package proxy
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wso2/open-mcp-auth-proxy/internal/config"
+	"github.com/wso2/open-mcp-auth-proxy/internal/tracing"
+	"github.com/wso2/open-mcp-auth-proxy/internal/util"
+)
+
+// ForwardedHeader marks a request forwarded by another replica. Its value is
+// <unix time>.<hex HMAC-SHA256 of the time, method and request URI>.
+const ForwardedHeader = "X-MCP-Proxy-Forwarded"
+
+// forwardedMaxAge bounds the clock skew and delay tolerated on forwarded requests
+const forwardedMaxAge = 60 * time.Second
+
+// peerForwarder sends requests on sessions held by another replica to that replica
+type peerForwarder struct {
+	self      string // Instance URL of this replica
+	secret    []byte
+	transport http.RoundTripper
+	now       func() time.Time
+}
+
+// newPeerForwarder returns nil when the proxy doesn't run as a cluster
+func newPeerForwarder(cfg config.ClusterConfig) (*peerForwarder, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	transport, err := util.NewTransport(cfg.Transport)
+	if err != nil {
+		return nil, err
+	}
+	return &peerForwarder{
+		self:      strings.TrimRight(cfg.InstanceURL, "/"),
+		secret:    []byte(cfg.SharedSecret),
+		transport: tracing.Transport(transport),
+		now:       time.Now,
+	}, nil
+}
+
+// instance returns the URL sessions held by this replica are recorded with
+func (f *peerForwarder) instance() string {
+	if f == nil {
+		return ""
+	}
+	return f.self
+}
+
+// isRemote reports whether owner is another replica
+func (f *peerForwarder) isRemote(owner string) bool {
+	return f != nil && owner != "" && strings.TrimRight(owner, "/") != f.self
+}
+
+func (f *peerForwarder) sign(timestamp, method, requestURI string) string {
+	mac := hmac.New(sha256.New, f.secret)
+	mac.Write([]byte(timestamp + "\n" + method + "\n" + requestURI))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// verify reports whether the request was forwarded by a replica of the
+// cluster. A header that doesn't verify is removed so it can't reach the MCP server.
+func (f *peerForwarder) verify(r *http.Request) bool {
+	value := r.Header.Get(ForwardedHeader)
+	if value == "" {
+		return false
+	}
+	r.Header.Del(ForwardedHeader)
+	if f == nil {
+		return false
+	}
+
+	timestamp, signature, found := strings.Cut(value, ".")
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if !found || err != nil {
+		proxyLog.Warn("Ignoring malformed %s header from %s", ForwardedHeader, r.RemoteAddr)
+		return false
+	}
+	age := f.now().Sub(time.Unix(seconds, 0))
+	if age < -forwardedMaxAge || age > forwardedMaxAge {
+		proxyLog.Warn("Ignoring stale %s header from %s", ForwardedHeader, r.RemoteAddr)
+		return false
+	}
+	expected := f.sign(timestamp, r.Method, r.URL.RequestURI())
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		proxyLog.Warn("Ignoring %s header with an invalid signature from %s", ForwardedHeader, r.RemoteAddr)
+		return false
+	}
+	return true
+}
+
+// forward proxies the request, as the client sent it, to the replica holding
+// its session. The owner authenticates the request again.
+func (f *peerForwarder) forward(w http.ResponseWriter, r *http.Request, owner string) {
+	target, err := url.Parse(owner)
+	if err != nil {
+		proxyLog.Error("Invalid owner %q of session: %v", owner, err)
+		http.Error(w, "Bad Gateway", http.StatusBadGateway)
+		return
+	}
+
+	// The owner sees the external URL the client used, for DPoP and metadata URLs
+	externalBase, _ := url.Parse(util.GetExternalBaseURL(r))
+
+	rp := &httputil.ReverseProxy{
+		Transport: f.transport,
+		Director: func(req *http.Request) {
+			req.URL.Scheme = target.Scheme
+			req.URL.Host = target.Host
+			if externalBase != nil {
+				if req.Header.Get("X-Forwarded-Proto") == "" {
+					req.Header.Set("X-Forwarded-Proto", externalBase.Scheme)
+				}
+				if req.Header.Get("X-Forwarded-Host") == "" {
+					req.Header.Set("X-Forwarded-Host", externalBase.Host)
+				}
+			}
+			timestamp := strconv.FormatInt(f.now().Unix(), 10)
+			req.Header.Set(ForwardedHeader, timestamp+"."+f.sign(timestamp, req.Method, req.URL.RequestURI()))
+			proxyLog.Debug("Forwarding %s to session owner %s", r.URL.Path, target.Host)
+		},
+		ErrorHandler: func(rw http.ResponseWriter, req *http.Request, err error) {
+			proxyLog.Error("Error forwarding to session owner %s: %v", target.Host, err)
+			http.Error(rw, "Bad Gateway", http.StatusBadGateway)
+		},
+		FlushInterval: -1,
+	}
+	rp.ServeHTTP(w, r)
+}
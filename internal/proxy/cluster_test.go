@@ -0,0 +1,137 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wso2/open-mcp-auth-proxy/internal/config"
+	"github.com/wso2/open-mcp-auth-proxy/internal/session"
+)
+
+const testClusterSecret = "0123456789abcdef0123456789abcdef"
+
+// newClusterReplica starts a replica proxying to its own backend and sharing store
+func newClusterReplica(t *testing.T, store session.Store) (*httptest.Server, **http.Request) {
+	t.Helper()
+	backend, last := newTestBackend(t)
+
+	var router http.Handler
+	replica := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		router.ServeHTTP(w, r)
+	}))
+	t.Cleanup(replica.Close)
+
+	cfg := newTestConfig(backend.URL)
+	cfg.Cluster = config.ClusterConfig{Enabled: true, InstanceURL: replica.URL, SharedSecret: testClusterSecret}
+	router = NewRouter(cfg, newStubProvider(), WithSessionStore(store))
+	return replica, last
+}
+
+func TestMessageForwardedToSessionOwner(t *testing.T) {
+	store := session.NewMemoryStore()
+	owner, ownerLast := newClusterReplica(t, store)
+	other, otherLast := newClusterReplica(t, store)
+	store.Put(session.Session{ID: "abc", Subject: "user-1", ClientID: "client-1", Owner: owner.URL})
+
+	tests := []struct {
+		name   string
+		target string
+		header string // Forwarded header sent by the client
+	}{
+		{"Request to the owner", owner.URL, ""},
+		{"Request to another replica", other.URL, ""},
+		{"Forged forwarded header", other.URL, "1.deadbeef"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			*ownerLast, *otherLast = nil, nil
+			req, _ := http.NewRequest("POST", tc.target+"/messages?sessionId=abc", strings.NewReader(`{}`))
+			req.Header.Set("Authorization", "Bearer good-token")
+			if tc.header != "" {
+				req.Header.Set(ForwardedHeader, tc.header)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != http.StatusAccepted {
+				t.Errorf("Expected status 202, got %d", resp.StatusCode)
+			}
+			if *otherLast != nil {
+				t.Errorf("Expected the other replica's backend not to be called")
+			}
+			if *ownerLast == nil {
+				t.Fatalf("Expected the owner's backend to receive the message")
+			}
+			if got := (*ownerLast).Header.Get(ForwardedHeader); got != "" {
+				t.Errorf("Expected the forwarded header not to reach the backend, got %q", got)
+			}
+		})
+	}
+}
+
+func TestForwardedRequestNotForwardedAgain(t *testing.T) {
+	store := session.NewMemoryStore()
+	replica, last := newClusterReplica(t, store)
+
+	// The store names another replica, but a forwarded request is served locally
+	store.Put(session.Session{ID: "abc", Subject: "user-1", ClientID: "client-1", Owner: "http://127.0.0.1:1"})
+
+	f := &peerForwarder{secret: []byte(testClusterSecret), now: time.Now}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req, _ := http.NewRequest("POST", replica.URL+"/messages?sessionId=abc", strings.NewReader(`{}`))
+	req.Header.Set("Authorization", "Bearer good-token")
+	req.Header.Set(ForwardedHeader, timestamp+"."+f.sign(timestamp, "POST", "/messages?sessionId=abc"))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted || *last == nil {
+		t.Errorf("Expected the forwarded request to be served locally, got status %d", resp.StatusCode)
+	}
+}
+
+func TestVerifyForwardedHeader(t *testing.T) {
+	now := time.Unix(1000000, 0)
+	f := &peerForwarder{secret: []byte(testClusterSecret), now: func() time.Time { return now }}
+	sign := func(at time.Time, method, uri string) string {
+		timestamp := strconv.FormatInt(at.Unix(), 10)
+		return timestamp + "." + f.sign(timestamp, method, uri)
+	}
+
+	tests := []struct {
+		name     string
+		header   string
+		expected bool
+	}{
+		{"Valid", sign(now, "POST", "/messages?sessionId=abc"), true},
+		{"Slightly old", sign(now.Add(-30*time.Second), "POST", "/messages?sessionId=abc"), true},
+		{"Stale", sign(now.Add(-2*time.Minute), "POST", "/messages?sessionId=abc"), false},
+		{"Other method", sign(now, "GET", "/messages?sessionId=abc"), false},
+		{"Other session", sign(now, "POST", "/messages?sessionId=xyz"), false},
+		{"Malformed", "not-a-signature", false},
+		{"Missing", "", false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/messages?sessionId=abc", nil)
+			if tc.header != "" {
+				req.Header.Set(ForwardedHeader, tc.header)
+			}
+			if got := f.verify(req); got != tc.expected {
+				t.Errorf("Expected %v, got %v", tc.expected, got)
+			}
+			if req.Header.Get(ForwardedHeader) != "" {
+				t.Errorf("Expected the header to be removed")
+			}
+		})
+	}
+}
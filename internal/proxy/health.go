@@ -0,0 +1,279 @@
+package proxy
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/wso2/open-mcp-auth-proxy/internal/authz"
+	"github.com/wso2/open-mcp-auth-proxy/internal/config"
+	"github.com/wso2/open-mcp-auth-proxy/internal/metrics"
+	"github.com/wso2/open-mcp-auth-proxy/internal/subprocess"
+	"github.com/wso2/open-mcp-auth-proxy/internal/util"
+)
+
+// Paths of the endpoints for orchestrators and operators. They are served
+// by the proxy itself, without CORS checks.
+const (
+	HealthPath = "/healthz" // Liveness: the process is serving requests
+	ReadyPath  = "/readyz"  // Readiness: the proxy can serve MCP traffic
+	StatusPath = "/status"  // Authenticated status document
+)
+
+// readinessTimeout bounds each readiness check that reaches another server
+const readinessTimeout = 2 * time.Second
+
+// startedAt is when the proxy started, for the uptime in /status
+var startedAt = time.Now()
+
+// isHealthPath reports whether path is a liveness or readiness probe
+func isHealthPath(path string) bool {
+	return path == HealthPath || path == ReadyPath
+}
+
+// healthHandler answers liveness probes
+func healthHandler(w http.ResponseWriter, r *http.Request) {
+	if !allowProbeMethod(w, r) {
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// readinessCheck is the outcome of one readiness condition
+type readinessCheck struct {
+	Name  string `json:"name"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// readiness checks whether the proxy can serve MCP traffic: the JWKS is
+// loaded, the provider metadata was fetched, the MCP server is reachable and
+// the stdio server is running
+type readiness struct {
+	cfg         *config.Config
+	metadataURL string // Empty when the provider serves its metadata itself
+	subprocess  *subprocess.Manager
+	userServers bool // Per-user servers start on demand, so there is no MCP server to check
+
+	metadataFetched atomic.Bool
+}
+
+func newReadiness(cfg *config.Config, opts *handlerOptions) *readiness {
+	rd := &readiness{cfg: cfg, subprocess: opts.subprocess, userServers: opts.pool != nil}
+
+	// Providers that don't serve the discovery document proxy it from the authorization server
+	served := false
+	for _, path := range authz.RoutesFor(cfg).Served {
+		if path == authz.WellKnownPath {
+			served = true
+		}
+	}
+	if !served && cfg.AuthServerBaseURL != "" {
+		path := authz.WellKnownPath
+		if mapped, ok := cfg.PathMapping[path]; ok {
+			path = mapped
+		}
+		rd.metadataURL = strings.TrimRight(cfg.AuthServerBaseURL, "/") + path
+	}
+	return rd
+}
+
+// check runs the readiness checks that apply to the configuration
+func (rd *readiness) check(ctx context.Context) []readinessCheck {
+	var checks []readinessCheck
+	add := func(name string, err error) {
+		c := readinessCheck{Name: name, OK: err == nil}
+		if err != nil {
+			c.Error = err.Error()
+		}
+		checks = append(checks, c)
+	}
+
+	if rd.cfg.JWKSURL != "" {
+		var err error
+		if !util.JWKSLoaded() {
+			err = fmt.Errorf("no public keys loaded from %s", rd.cfg.JWKSURL)
+		}
+		add("jwks", err)
+	}
+	if rd.metadataURL != "" {
+		add("provider_metadata", rd.fetchMetadata(ctx))
+	}
+	if !rd.userServers {
+		add("mcp_server", rd.dialMCPServer(ctx))
+	}
+	if rd.subprocess != nil {
+		var err error
+		if !rd.subprocess.IsRunning() {
+			err = fmt.Errorf("the MCP server process isn't running")
+		}
+		add("subprocess", err)
+	}
+	return checks
+}
+
+// fetchMetadata fetches the authorization server metadata until it succeeds once
+func (rd *readiness) fetchMetadata(ctx context.Context) error {
+	if rd.metadataFetched.Load() {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, readinessTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rd.metadataURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := util.OutboundClient().Do(req)
+	if err != nil {
+		return fmt.Errorf("fetching provider metadata: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("provider metadata returned %s", resp.Status)
+	}
+	rd.metadataFetched.Store(true)
+	return nil
+}
+
+// dialMCPServer opens and closes a connection to the MCP server
+func (rd *readiness) dialMCPServer(ctx context.Context) error {
+	network, address := "unix", config.SocketPath(rd.cfg.BaseURL)
+	if address == "" {
+		base, err := url.Parse(rd.cfg.BaseURL)
+		if err != nil {
+			return err
+		}
+		network, address = "tcp", base.Host
+		if base.Port() == "" {
+			port := "80"
+			if base.Scheme == "https" {
+				port = "443"
+			}
+			address = net.JoinHostPort(base.Hostname(), port)
+		}
+	}
+	dialer := net.Dialer{Timeout: readinessTimeout}
+	conn, err := dialer.DialContext(ctx, network, address)
+	if err != nil {
+		return fmt.Errorf("MCP server unreachable: %w", err)
+	}
+	return conn.Close()
+}
+
+// ServeHTTP answers readiness probes with 200 when every check passes and 503 otherwise
+func (rd *readiness) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !allowProbeMethod(w, r) {
+		return
+	}
+	checks := rd.check(r.Context())
+	status, code := "ready", http.StatusOK
+	for _, c := range checks {
+		if !c.OK {
+			status, code = "not_ready", http.StatusServiceUnavailable
+			proxyLog.Ctx(r.Context()).Debug("Readiness check %s failed: %s", c.Name, c.Error)
+		}
+	}
+	writeJSON(w, code, map[string]interface{}{"status": status, "checks": checks})
+}
+
+// statusDocument describes the running proxy
+type statusDocument struct {
+	Version        string             `json:"version"`
+	StartedAt      time.Time          `json:"started_at"`
+	UptimeSeconds  int64              `json:"uptime_seconds"`
+	ConfigHash     string             `json:"config_hash,omitempty"`
+	ActiveSessions int                `json:"active_sessions"`
+	Subprocess     *subprocessStatus  `json:"subprocess,omitempty"`
+	JWKS           *jwksRefreshStatus `json:"jwks,omitempty"`
+}
+
+type subprocessStatus struct {
+	Running       bool  `json:"running"`
+	PID           int   `json:"pid,omitempty"`
+	UptimeSeconds int64 `json:"uptime_seconds"`
+	Restarts      int64 `json:"restarts"` // Restarts of per-user servers
+}
+
+type jwksRefreshStatus struct {
+	LastRefresh *time.Time `json:"last_refresh,omitempty"` // Last successful fetch
+	Keys        int        `json:"keys"`
+}
+
+// statusHandler serves the status document to holders of the status token,
+// or of a valid MCP access token when no status token is configured
+func statusHandler(cfg *config.Config, provider authz.Provider, opts *handlerOptions) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !allowProbeMethod(w, r) {
+			return
+		}
+		if !authorizeStatus(r, cfg.Health, provider) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		doc := statusDocument{
+			Version:        opts.version,
+			StartedAt:      startedAt.UTC(),
+			UptimeSeconds:  int64(time.Since(startedAt).Seconds()),
+			ConfigHash:     cfg.Hash(),
+			ActiveSessions: opts.streams.active(),
+		}
+		if doc.Version == "" {
+			doc.Version = "dev"
+		}
+		if opts.subprocess != nil || opts.pool != nil {
+			doc.Subprocess = &subprocessStatus{
+				Restarts: int64(metrics.SubprocessRestarts.Value(subprocess.RestartExited) +
+					metrics.SubprocessRestarts.Value(subprocess.RestartCredentialsChanged)),
+			}
+			if opts.subprocess != nil {
+				doc.Subprocess.Running = opts.subprocess.IsRunning()
+				doc.Subprocess.PID = opts.subprocess.PID()
+				doc.Subprocess.UptimeSeconds = int64(opts.subprocess.Uptime().Seconds())
+			}
+		}
+		if cfg.JWKSURL != "" {
+			doc.JWKS = &jwksRefreshStatus{Keys: int(metrics.JWKSKeys.Value())}
+			if last := metrics.JWKSLastRefresh.Value(); last > 0 {
+				refreshed := time.Unix(int64(last), 0).UTC()
+				doc.JWKS.LastRefresh = &refreshed
+			}
+		}
+		writeJSON(w, http.StatusOK, doc)
+	}
+}
+
+// authorizeStatus checks the bearer token of a status request
+func authorizeStatus(r *http.Request, cfg config.HealthConfig, provider authz.Provider) bool {
+	if cfg.StatusToken != "" {
+		presented := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		return subtle.ConstantTimeCompare([]byte(presented), []byte(cfg.StatusToken)) == 1
+	}
+	_, err := provider.ValidateToken(r)
+	return err == nil
+}
+
+// allowProbeMethod rejects methods other than GET and HEAD
+func allowProbeMethod(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return true
+	}
+	w.Header().Set("Allow", "GET, HEAD")
+	http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, code int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(body)
+}
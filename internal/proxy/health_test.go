@@ -0,0 +1,157 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/wso2/open-mcp-auth-proxy/internal/authz"
+	"github.com/wso2/open-mcp-auth-proxy/internal/metrics"
+)
+
+func TestHealthSkipsCORS(t *testing.T) {
+	router := NewRouter(newTestConfig("http://localhost:8000"), newStubProvider())
+
+	req := httptest.NewRequest("GET", HealthPath, nil)
+	req.Header.Set("Origin", "http://evil.example.com")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status OK from a disallowed origin, got %v", w.Code)
+	}
+	if w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("Expected no CORS headers on probes")
+	}
+}
+
+func TestReadiness(t *testing.T) {
+	var metadataFetches atomic.Int32
+	authServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != authz.WellKnownPath {
+			http.NotFound(w, r)
+			return
+		}
+		metadataFetches.Add(1)
+		w.Write([]byte(`{"issuer":"http://auth.example.com"}`))
+	}))
+	defer authServer.Close()
+
+	backend, _ := newTestBackend(t)
+	cfg := newTestConfig(backend.URL)
+	cfg.AuthServerBaseURL = authServer.URL
+	router := NewRouter(cfg, newStubProvider())
+
+	probe := func() (int, map[string]bool) {
+		req := httptest.NewRequest("GET", ReadyPath, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var body struct {
+			Checks []readinessCheck `json:"checks"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+			t.Fatalf("Failed to decode readiness: %v", err)
+		}
+		checks := make(map[string]bool)
+		for _, c := range body.Checks {
+			checks[c.Name] = c.OK
+		}
+		return w.Code, checks
+	}
+
+	code, checks := probe()
+	if code != http.StatusOK || !checks["provider_metadata"] || !checks["mcp_server"] {
+		t.Fatalf("Expected the proxy to be ready, got %v: %v", code, checks)
+	}
+
+	// The metadata is fetched once; the MCP server is checked on every probe
+	backend.Close()
+	code, checks = probe()
+	if code != http.StatusServiceUnavailable || checks["mcp_server"] || !checks["provider_metadata"] {
+		t.Errorf("Expected the proxy not to be ready without its MCP server, got %v: %v", code, checks)
+	}
+	if metadataFetches.Load() != 1 {
+		t.Errorf("Expected the metadata to be fetched once, got %d", metadataFetches.Load())
+	}
+}
+
+func TestStatusReportsJWKSRefresh(t *testing.T) {
+	cfg := newTestConfig("http://localhost:8000")
+	cfg.JWKSURL = "http://auth.example.com/jwks"
+	router := NewRouter(cfg, newStubProvider())
+
+	status := func() *jwksRefreshStatus {
+		req := httptest.NewRequest("GET", StatusPath, nil)
+		req.Header.Set("Authorization", "Bearer good-token")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var doc statusDocument
+		if err := json.NewDecoder(w.Body).Decode(&doc); err != nil {
+			t.Fatalf("Failed to decode status: %v", err)
+		}
+		if doc.JWKS == nil {
+			t.Fatalf("Expected JWKS status with a JWKS URL configured")
+		}
+		return doc.JWKS
+	}
+
+	// Each refresh updates the gauges the status document reads
+	refreshed := time.Now().Add(-time.Minute).Truncate(time.Second)
+	metrics.JWKSLastRefresh.Set(float64(refreshed.Unix()))
+	metrics.JWKSKeys.Set(2)
+	if s := status(); s.LastRefresh == nil || !s.LastRefresh.Equal(refreshed) || s.Keys != 2 {
+		t.Errorf("Expected the first refresh, got %+v", s)
+	}
+
+	refreshed = refreshed.Add(30 * time.Second)
+	metrics.JWKSLastRefresh.Set(float64(refreshed.Unix()))
+	if s := status(); s.LastRefresh == nil || !s.LastRefresh.Equal(refreshed) {
+		t.Errorf("Expected the later refresh, got %+v", s)
+	}
+}
+
+func TestStatusRequiresAuthentication(t *testing.T) {
+	cfg := newTestConfig("http://localhost:8000")
+	tests := []struct {
+		name        string
+		statusToken string
+		bearer      string
+		expected    int
+	}{
+		{"No token", "", "", http.StatusUnauthorized},
+		{"MCP access token", "", "good-token", http.StatusOK},
+		{"MCP access token with a status token configured", "status-token-0123456789", "good-token", http.StatusUnauthorized},
+		{"Status token", "status-token-0123456789", "status-token-0123456789", http.StatusOK},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg.Health.StatusToken = tc.statusToken
+			router := NewRouter(cfg, newStubProvider(), WithVersion("1.2.3"))
+			req := httptest.NewRequest("GET", StatusPath, nil)
+			req.Header.Set("Origin", "http://evil.example.com")
+			if tc.bearer != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.bearer)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tc.expected {
+				t.Fatalf("Expected status %v, got %v", tc.expected, w.Code)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+			var doc statusDocument
+			if err := json.NewDecoder(w.Body).Decode(&doc); err != nil {
+				t.Fatalf("Failed to decode status: %v", err)
+			}
+			if doc.Version != "1.2.3" || doc.ActiveSessions != 0 || doc.Subprocess != nil {
+				t.Errorf("Unexpected status %+v", doc)
+			}
+		})
+	}
+}
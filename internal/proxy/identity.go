@@ -0,0 +1,177 @@
+package proxy
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/wso2/open-mcp-auth-proxy/internal/authz"
+	"github.com/wso2/open-mcp-auth-proxy/internal/config"
+)
+
+// identityPropagator passes the authenticated principal to the MCP server
+// as plain headers and an internal JWT signed by the proxy
+type identityPropagator struct {
+	cfg    config.IdentityConfig
+	method jwt.SigningMethod
+	key    interface{}
+	now    func() time.Time
+}
+
+// newIdentityPropagator loads the signing key for the internal token, if enabled
+func newIdentityPropagator(cfg config.IdentityConfig) (*identityPropagator, error) {
+	p := &identityPropagator{cfg: cfg, now: time.Now}
+	if !cfg.InternalToken.Enabled {
+		return p, nil
+	}
+
+	if cfg.InternalToken.SigningKeyFile == "" {
+		p.method = jwt.SigningMethodHS256
+		p.key = []byte(cfg.InternalToken.HMACSecret)
+		return p, nil
+	}
+
+	method, key, err := loadSigningKey(cfg.InternalToken.SigningKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	p.method, p.key = method, key
+	return p, nil
+}
+
+// loadSigningKey reads a PEM private key and picks the matching algorithm
+func loadSigningKey(path string) (jwt.SigningMethod, interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read signing key: %v", err)
+	}
+	if key, err := jwt.ParseRSAPrivateKeyFromPEM(data); err == nil {
+		return jwt.SigningMethodRS256, key, nil
+	}
+	if key, err := jwt.ParseECPrivateKeyFromPEM(data); err == nil {
+		return jwt.SigningMethodES256, key, nil
+	}
+	if key, err := jwt.ParseEdPrivateKeyFromPEM(data); err == nil {
+		return jwt.SigningMethodEdDSA, key, nil
+	}
+	return nil, nil, fmt.Errorf("signing key %s is not an RSA, EC or Ed25519 private key", path)
+}
+
+// headerNames returns every header the propagator owns
+func (p *identityPropagator) headerNames() []string {
+	names := make([]string, 0, len(p.cfg.Headers)+1)
+	for name := range p.cfg.Headers {
+		names = append(names, name)
+	}
+	if p.cfg.InternalToken.Enabled {
+		names = append(names, p.cfg.InternalToken.Header)
+	}
+	return names
+}
+
+// Apply rewrites the outgoing headers for the principal. Identity headers sent
+// by the client are always removed so they can't be spoofed.
+func (p *identityPropagator) Apply(header http.Header, principal *authz.Principal) error {
+	for _, name := range p.headerNames() {
+		header.Del(name)
+	}
+	if principal == nil {
+		return nil
+	}
+
+	// A DPoP proof is only useful together with the token it is bound to
+	if p.cfg.StripAuthorization {
+		header.Del("Authorization")
+		header.Del("DPoP")
+	}
+
+	for name, claim := range p.cfg.Headers {
+		if value := claimValue(principal, claim); value != "" {
+			header.Set(name, value)
+		}
+	}
+
+	if p.cfg.InternalToken.Enabled {
+		token, err := p.internalToken(principal)
+		if err != nil {
+			return err
+		}
+		header.Set(p.cfg.InternalToken.Header, token)
+	}
+	return nil
+}
+
+// internalToken signs a short-lived JWT describing the principal
+func (p *identityPropagator) internalToken(principal *authz.Principal) (string, error) {
+	now := p.now()
+	claims := jwt.MapClaims{
+		"iss":   p.cfg.InternalToken.Issuer,
+		"sub":   principal.Subject,
+		"iat":   now.Unix(),
+		"nbf":   now.Unix(),
+		"exp":   now.Add(time.Duration(p.cfg.InternalToken.TTLSeconds) * time.Second).Unix(),
+		"jti":   randomID(),
+		"scope": strings.Join(principal.Scopes, " "),
+	}
+	if p.cfg.InternalToken.Audience != "" {
+		claims["aud"] = p.cfg.InternalToken.Audience
+	}
+	if principal.ClientID != "" {
+		claims["client_id"] = principal.ClientID
+	}
+	if principal.Issuer != "" {
+		// Issuer of the user's token, for backends that trust several servers
+		claims["upstream_iss"] = principal.Issuer
+	}
+
+	token := jwt.NewWithClaims(p.method, claims)
+	if p.cfg.InternalToken.KeyID != "" {
+		token.Header["kid"] = p.cfg.InternalToken.KeyID
+	}
+	signed, err := token.SignedString(p.key)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign internal token: %v", err)
+	}
+	return signed, nil
+}
+
+// claimValue resolves a header mapping to a string value
+func claimValue(principal *authz.Principal, claim string) string {
+	switch claim {
+	case "sub":
+		return principal.Subject
+	case "scope", "scopes":
+		return strings.Join(principal.Scopes, " ")
+	case "client_id":
+		return principal.ClientID
+	}
+
+	switch value := principal.Claims[claim].(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case []interface{}:
+		parts := make([]string, 0, len(value))
+		for _, v := range value {
+			parts = append(parts, fmt.Sprint(v))
+		}
+		return strings.Join(parts, ",")
+	case float64:
+		return fmt.Sprintf("%.0f", value)
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// randomID returns a random hex identifier
+func randomID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
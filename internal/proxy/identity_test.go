@@ -0,0 +1,116 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/wso2/open-mcp-auth-proxy/internal/authz"
+	"github.com/wso2/open-mcp-auth-proxy/internal/config"
+)
+
+func TestIdentityHeaders(t *testing.T) {
+	identity, err := newIdentityPropagator(config.IdentityConfig{
+		StripAuthorization: true,
+		Headers: map[string]string{
+			"X-MCP-User":   "sub",
+			"X-MCP-Scopes": "scope",
+			"X-MCP-Groups": "groups",
+		},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	principal := &authz.Principal{
+		Subject: "alice",
+		Scopes:  []string{"mcp:read", "mcp:write"},
+		Claims:  map[string]interface{}{"groups": []interface{}{"admins", "devs"}},
+	}
+
+	tests := []struct {
+		name      string
+		principal *authz.Principal
+		expected  map[string]string
+	}{
+		{
+			name:      "Authenticated",
+			principal: principal,
+			expected: map[string]string{
+				"Authorization": "",
+				"DPoP":          "",
+				"X-MCP-User":    "alice",
+				"X-MCP-Scopes":  "mcp:read mcp:write",
+				"X-MCP-Groups":  "admins,devs",
+			},
+		},
+		{
+			name:      "Spoofed headers without principal",
+			principal: nil,
+			expected: map[string]string{
+				"Authorization": "Bearer user-token",
+				"DPoP":          "proof",
+				"X-MCP-User":    "",
+				"X-MCP-Scopes":  "",
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			header := http.Header{}
+			header.Set("Authorization", "Bearer user-token")
+			header.Set("DPoP", "proof")
+			header.Set("X-MCP-User", "mallory")
+			header.Set("X-MCP-Scopes", "admin")
+
+			if err := identity.Apply(header, tc.principal); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			for name, value := range tc.expected {
+				if got := header.Get(name); got != value {
+					t.Errorf("Expected %s=%q, got %q", name, value, got)
+				}
+			}
+		})
+	}
+}
+
+func TestIdentityInternalToken(t *testing.T) {
+	backend, last := newTestBackend(t)
+	cfg := newTestConfig(backend.URL)
+	cfg.Identity.InternalToken = config.InternalTokenConfig{
+		Enabled:    true,
+		Audience:   "mcp-server",
+		HMACSecret: "shared-secret-of-at-least-32-bytes",
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Unexpected validation error: %v", err)
+	}
+	router := NewRouter(cfg, newStubProvider(), withTestSessions("abc"))
+
+	req := httptest.NewRequest("POST", "/messages?sessionId=abc", strings.NewReader(`{}`))
+	req.Header.Set("Authorization", "Bearer good-token")
+	req.Header.Set("X-MCP-Identity", "forged")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	if *last == nil {
+		t.Fatalf("Expected request to reach the backend")
+	}
+	raw := (*last).Header.Get("X-MCP-Identity")
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte("shared-secret-of-at-least-32-bytes"), nil
+	})
+	if err != nil {
+		t.Fatalf("Expected a valid internal token, got %q: %v", raw, err)
+	}
+	if claims["sub"] != "user-1" || claims["client_id"] != "client-1" || claims["aud"] != "mcp-server" {
+		t.Errorf("Unexpected internal token claims: %v", claims)
+	}
+	if claims["iss"] != "open-mcp-auth-proxy" {
+		t.Errorf("Expected default issuer, got %v", claims["iss"])
+	}
+}
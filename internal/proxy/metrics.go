@@ -0,0 +1,147 @@
+package proxy
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wso2/open-mcp-auth-proxy/internal/config"
+	logger "github.com/wso2/open-mcp-auth-proxy/internal/logging"
+	"github.com/wso2/open-mcp-auth-proxy/internal/metrics"
+	"github.com/wso2/open-mcp-auth-proxy/internal/vault"
+)
+
+// rpcMethods are the JSON-RPC methods MCP clients send. Others are counted
+// as "other" so clients can't create series at will.
+var rpcMethods = map[string]bool{
+	"initialize":                         true,
+	"ping":                               true,
+	"tools/list":                         true,
+	"tools/call":                         true,
+	"resources/list":                     true,
+	"resources/templates/list":           true,
+	"resources/read":                     true,
+	"resources/subscribe":                true,
+	"resources/unsubscribe":              true,
+	"prompts/list":                       true,
+	"prompts/get":                        true,
+	"completion/complete":                true,
+	"logging/setLevel":                   true,
+	"notifications/initialized":          true,
+	"notifications/cancelled":            true,
+	"notifications/progress":             true,
+	"notifications/roots/list_changed":   true,
+	"notifications/elicitation/complete": true,
+}
+
+// routeClass groups a request path for the request metrics
+func routeClass(path string, cfg *config.Config) string {
+	switch {
+	case isHealthPath(path):
+		return metrics.RouteHealth
+	case path == logger.LevelsPath || path == StatusPath || (cfg.Metrics.Enabled && path == cfg.Metrics.Path):
+		return metrics.RouteAdmin
+	case strings.HasPrefix(path, "/.well-known/"):
+		return metrics.RouteWellKnown
+	case isAuthPath(path):
+		return metrics.RouteAuth
+	case strings.HasPrefix(path, vault.PathPrefix):
+		return metrics.RouteVault
+	case isMCPPath(path, cfg):
+		return metrics.RouteMCP
+	}
+	for mapped := range cfg.PathMapping {
+		if path == mapped {
+			return metrics.RouteMCP
+		}
+	}
+	return metrics.RouteOther
+}
+
+// withMetrics counts every request and measures how long it took, by route
+// class and status code
+func withMetrics(cfg *config.Config, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+
+		route := routeClass(r.URL.Path, cfg)
+		status := strconv.Itoa(recorder.Status())
+		metrics.HTTPRequests.Inc(route, status)
+		metrics.HTTPRequestDuration.Observe(time.Since(start).Seconds(), route, status)
+	})
+}
+
+// statusRecorder remembers the status code written to the response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	if s.status == 0 {
+		s.status = code
+	}
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	return s.ResponseWriter.Write(b)
+}
+
+// Flush keeps SSE streams flowing through the recorder
+func (s *statusRecorder) Flush() {
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// Status returns the status code of the response, 200 if none was written
+func (s *statusRecorder) Status() int {
+	if s.status == 0 {
+		return http.StatusOK
+	}
+	return s.status
+}
+
+// countRPCMethods counts the JSON-RPC messages posted by an MCP client
+func countRPCMethods(r *http.Request) {
+	for _, m := range peekRPC(r) {
+		if m.Method == "" {
+			continue // Responses to server requests
+		}
+		method := m.Method
+		if !rpcMethods[method] {
+			method = "other"
+		}
+		metrics.RPCRequests.Inc(method)
+	}
+}
+
+// metricsHandler serves the metrics, to holders of the configured token if any
+func metricsHandler(cfg config.MetricsConfig) http.Handler {
+	serve := metrics.Handler()
+	if cfg.Token == "" {
+		return serve
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		presented := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(presented), []byte(cfg.Token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		serve.ServeHTTP(w, r)
+	})
+}
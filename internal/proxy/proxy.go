@@ -2,22 +2,38 @@ package proxy
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
 	"strings"
 	"time"
 
+	"github.com/wso2/open-mcp-auth-proxy/internal/audit"
 	"github.com/wso2/open-mcp-auth-proxy/internal/authz"
 	"github.com/wso2/open-mcp-auth-proxy/internal/config"
 	logger "github.com/wso2/open-mcp-auth-proxy/internal/logging"
+	"github.com/wso2/open-mcp-auth-proxy/internal/metrics"
+	"github.com/wso2/open-mcp-auth-proxy/internal/ratelimit"
+	"github.com/wso2/open-mcp-auth-proxy/internal/session"
+	"github.com/wso2/open-mcp-auth-proxy/internal/subprocess"
+	"github.com/wso2/open-mcp-auth-proxy/internal/tracing"
 	"github.com/wso2/open-mcp-auth-proxy/internal/util"
+	"github.com/wso2/open-mcp-auth-proxy/internal/vault"
+)
+
+// Loggers of the request handling and SSE streaming subsystems
+var (
+	proxyLog = logger.For(logger.Proxy)
+	sseLog   = logger.For(logger.SSE)
 )
 
 // NewRouter builds an http.ServeMux that routes
 // * /authorize, /token, /register, /.well-known to the provider or proxy
 // * MCP paths to the MCP server, etc.
-func NewRouter(cfg *config.Config, provider authz.Provider) http.Handler {
+func NewRouter(cfg *config.Config, provider authz.Provider, options ...RouterOption) http.Handler {
 	mux := http.NewServeMux()
 
 	modifiers := map[string]RequestModifier{
@@ -26,41 +42,72 @@ func NewRouter(cfg *config.Config, provider authz.Provider) http.Handler {
 		"/register":  &RegisterModifier{Config: cfg},
 	}
 
+	identity, err := newIdentityPropagator(cfg.Identity)
+	if err != nil {
+		proxyLog.Error("Invalid identity propagation config: %v", err)
+		panic(err) // Fatal error that prevents startup
+	}
+
+	cluster, err := newPeerForwarder(cfg.Cluster)
+	if err != nil {
+		proxyLog.Error("Invalid cluster transport config: %v", err)
+		panic(err) // Fatal error that prevents startup
+	}
+
+	opts := &handlerOptions{
+		modifiers: modifiers,
+		identity:  identity,
+		exchanger: newTokenExchanger(cfg),
+		streams:   newStreamTracker(cfg.SSE),
+		replay:    newReplayBuffers(cfg.SSE),
+		cluster:   cluster,
+	}
+	for _, option := range options {
+		option(opts)
+	}
+	if opts.sessions == nil {
+		opts.sessions = session.NewMemoryStore()
+	}
+	opts.limiter = ratelimit.New(cfg.RateLimit, opts.rateLimitStore)
+
 	registeredPaths := make(map[string]bool)
 
-	var defaultPaths []string
+	// The provider declares which OAuth routes it serves and which are proxied
+	routes := authz.RoutesFor(cfg)
+	for _, path := range routes.Served {
+		handler := authz.HandlerFor(provider, path)
+		if handler == nil {
+			proxyLog.Warn("Provider %s declares %s but has no handler for it", authz.ProviderName(cfg), path)
+			continue
+		}
+		mux.HandleFunc(path, withAuthRateLimit(cfg, opts.limiter, handler))
+		registeredPaths[path] = true
+	}
+
+	defaultPaths := routes.Proxied
 
-	// Handle based on mode configuration
-	if cfg.Mode == "demo" || cfg.Mode == "asgardeo" {
-		// Demo/Asgardeo mode: Custom handlers for well-known and register
-		mux.HandleFunc("/.well-known/oauth-authorization-server", provider.WellKnownHandler())
-		registeredPaths["/.well-known/oauth-authorization-server"] = true
+	// Protected resource metadata (RFC 9728) always comes from the provider
+	mux.HandleFunc(authz.ProtectedResourcePath, authz.ProtectedResourceHandler(provider))
+	registeredPaths[authz.ProtectedResourcePath] = true
 
-		mux.HandleFunc("/register", provider.RegisterHandler())
-		registeredPaths["/register"] = true
+	// Probes and status for orchestrators, outside CORS and the proxied routes
+	mux.HandleFunc(HealthPath, healthHandler)
+	mux.Handle(ReadyPath, newReadiness(cfg, opts))
+	mux.HandleFunc(StatusPath, statusHandler(cfg, provider, opts))
+	registeredPaths[HealthPath] = true
+	registeredPaths[ReadyPath] = true
+	registeredPaths[StatusPath] = true
 
-		// Authorize and token will be proxied with parameter modification
-		defaultPaths = []string{"/authorize", "/token"}
-	} else {
-		// Default provider mode
-		if cfg.Default.Path != nil {
-			// Check if we have custom response for well-known
-			wellKnownConfig, exists := cfg.Default.Path["/.well-known/oauth-authorization-server"]
-			if exists && wellKnownConfig.Response != nil {
-				// If there's a custom response defined, use our handler
-				mux.HandleFunc("/.well-known/oauth-authorization-server", provider.WellKnownHandler())
-				registeredPaths["/.well-known/oauth-authorization-server"] = true
-			} else {
-				// No custom response, add well-known to proxy paths
-				defaultPaths = append(defaultPaths, "/.well-known/oauth-authorization-server")
+	// Credential vault API for per-user servers
+	if opts.broker != nil {
+		mux.Handle(vault.PathPrefix, withCORS(cfg, vault.NewHandler(opts.broker, func(r *http.Request) (string, error) {
+			principal, err := provider.ValidateToken(r)
+			if err != nil {
+				return "", err
 			}
-
-			defaultPaths = append(defaultPaths, "/authorize")
-			defaultPaths = append(defaultPaths, "/token")
-			defaultPaths = append(defaultPaths, "/register")
-		} else {
-			defaultPaths = []string{"/authorize", "/token", "/register", "/.well-known/oauth-authorization-server"}
-		}
+			return principal.Subject, nil
+		})))
+		registeredPaths[vault.PathPrefix] = true
 	}
 
 	// Remove duplicates from defaultPaths
@@ -76,7 +123,7 @@ func NewRouter(cfg *config.Config, provider authz.Provider) http.Handler {
 
 	for _, path := range defaultPaths {
 		if !registeredPaths[path] {
-			mux.HandleFunc(path, buildProxyHandler(cfg, modifiers))
+			mux.HandleFunc(path, buildProxyHandler(cfg, provider, opts))
 			registeredPaths[path] = true
 		}
 	}
@@ -84,46 +131,129 @@ func NewRouter(cfg *config.Config, provider authz.Provider) http.Handler {
 	// MCP paths
 	mcpPaths := cfg.GetMCPPaths()
 	for _, path := range mcpPaths {
-		mux.HandleFunc(path, buildProxyHandler(cfg, modifiers))
+		mux.HandleFunc(path, buildProxyHandler(cfg, provider, opts))
 		registeredPaths[path] = true
 	}
 
 	// Register paths from PathMapping that haven't been registered yet
 	for path := range cfg.PathMapping {
 		if !registeredPaths[path] {
-			mux.HandleFunc(path, buildProxyHandler(cfg, modifiers))
+			mux.HandleFunc(path, buildProxyHandler(cfg, provider, opts))
 			registeredPaths[path] = true
 		}
 	}
 
-	return mux
+	// Log levels can be changed at runtime by whoever holds the admin token
+	if cfg.Logging.AdminToken != "" {
+		mux.Handle(logger.LevelsPath, logger.LevelsHandler(cfg.Logging.AdminToken))
+	}
+
+	if cfg.Metrics.Enabled {
+		mux.Handle(cfg.Metrics.Path, metricsHandler(cfg.Metrics))
+	}
+
+	return withMetrics(cfg, withRequestFields(withTracing(cfg, mux)))
+}
+
+// handlerOptions holds the state shared by the proxy handlers of a router
+type handlerOptions struct {
+	modifiers map[string]RequestModifier
+	identity  *identityPropagator
+	exchanger *tokenExchanger // nil unless token exchange is enabled
+	streams   *streamTracker
+	replay    *replayBuffers // nil unless SSE replay is enabled
+	sessions  session.Store  // Owners of MCP sessions
+	cluster   *peerForwarder // nil unless the proxy runs as a cluster
+	audit     *audit.Auditor // nil unless auditing is enabled
+
+	// Per-user servers, nil unless the credential vault is enabled
+	broker *vault.Broker
+	pool   *subprocess.Pool
+
+	limiter        *ratelimit.Limiter // nil unless rate limiting is enabled
+	rateLimitStore ratelimit.Store    // Shared bucket store, in memory when nil
+
+	version    string              // Reported by /status
+	subprocess *subprocess.Manager // nil unless the proxy runs a stdio server
+}
+
+// RouterOption enables optional router features
+type RouterOption func(*handlerOptions)
+
+// WithUserServers routes each user's MCP traffic to their own server process,
+// started with the credentials they connected to the vault
+func WithUserServers(broker *vault.Broker, pool *subprocess.Pool) RouterOption {
+	return func(opts *handlerOptions) {
+		opts.broker = broker
+		opts.pool = pool
+	}
+}
+
+// WithSessionStore records session owners in a store shared between proxy instances
+func WithSessionStore(store session.Store) RouterOption {
+	return func(opts *handlerOptions) {
+		opts.sessions = store
+	}
+}
+
+// WithAuditor records the JSON-RPC exchanges of MCP clients
+func WithAuditor(auditor *audit.Auditor) RouterOption {
+	return func(opts *handlerOptions) {
+		opts.audit = auditor
+	}
+}
+
+// WithRateLimitStore keeps rate limit buckets in a store shared between proxy instances
+func WithRateLimitStore(store ratelimit.Store) RouterOption {
+	return func(opts *handlerOptions) {
+		opts.rateLimitStore = store
+	}
+}
+
+// WithVersion sets the version reported by the status endpoint
+func WithVersion(version string) RouterOption {
+	return func(opts *handlerOptions) {
+		opts.version = version
+	}
+}
+
+// WithSubprocess reports the stdio MCP server in the readiness and status endpoints
+func WithSubprocess(manager *subprocess.Manager) RouterOption {
+	return func(opts *handlerOptions) {
+		opts.subprocess = manager
+	}
 }
 
-func buildProxyHandler(cfg *config.Config, modifiers map[string]RequestModifier) http.HandlerFunc {
+func buildProxyHandler(cfg *config.Config, provider authz.Provider, opts *handlerOptions) http.HandlerFunc {
 	// Parse the base URLs up front
 	authBase, err := url.Parse(cfg.AuthServerBaseURL)
 	if err != nil {
-		logger.Error("Invalid auth server URL: %v", err)
+		proxyLog.Error("Invalid auth server URL: %v", err)
 		panic(err) // Fatal error that prevents startup
 	}
 
 	mcpBase, err := url.Parse(cfg.BaseURL)
 	if err != nil {
-		logger.Error("Invalid MCP server URL: %v", err)
+		proxyLog.Error("Invalid MCP server URL: %v", err)
 		panic(err) // Fatal error that prevents startup
 	}
+	if config.SocketPath(cfg.BaseURL) != "" {
+		// The upstream transport dials the socket; the URL only sets the Host header
+		mcpBase = &url.URL{Scheme: "http", Host: "localhost"}
+	}
 
 	// Detect SSE paths from config
 	ssePaths := make(map[string]bool)
 	ssePaths[cfg.Paths.SSE] = true
 
 	return func(w http.ResponseWriter, r *http.Request) {
+		reqLog := proxyLog.Ctx(r.Context())
 		origin := r.Header.Get("Origin")
 		allowedOrigin := getAllowedOrigin(origin, cfg)
 		// Handle OPTIONS
 		if r.Method == http.MethodOptions {
 			if allowedOrigin == "" {
-				logger.Warn("Preflight request from disallowed origin: %s", origin)
+				reqLog.Warn("Preflight request from disallowed origin: %s", origin)
 				http.Error(w, "CORS origin not allowed", http.StatusForbidden)
 				return
 			}
@@ -133,7 +263,7 @@ func buildProxyHandler(cfg *config.Config, modifiers map[string]RequestModifier)
 		}
 
 		if allowedOrigin == "" {
-			logger.Warn("Request from disallowed origin: %s for %s", origin, r.URL.Path)
+			reqLog.Warn("Request from disallowed origin: %s for %s", origin, r.URL.Path)
 			http.Error(w, "CORS origin not allowed", http.StatusForbidden)
 			return
 		}
@@ -141,23 +271,81 @@ func buildProxyHandler(cfg *config.Config, modifiers map[string]RequestModifier)
 		// Add CORS headers to all responses
 		addCORSHeaders(w, cfg, allowedOrigin, "")
 
+		forwarded := opts.cluster.verify(r)
+
 		// Decide whether the request should go to the auth server or MCP
 		var targetURL *url.URL
+		var owner session.Session // Known session the request is made on
 		isSSE := false
 
 		if isAuthPath(r.URL.Path) {
+			if !allowAuthRequest(w, r, cfg, opts.limiter) {
+				return
+			}
 			targetURL = authBase
 		} else if isMCPPath(r.URL.Path, cfg) {
-			// Validate JWT for MCP paths if required
-			// Placeholder for JWT validation logic
-			if err := util.ValidateJWT(r.Header.Get("Authorization")); err != nil {
-				logger.Warn("Unauthorized request to %s: %v", r.URL.Path, err)
+			// Validate the access token with the provider
+			_, validation := tracing.Start(r.Context(), "authz.validate_token", tracing.KindInternal)
+			principal, err := provider.ValidateToken(r)
+			if err != nil {
+				validation.SetAttributes("authz.failure_reason", authz.FailureReason(err))
+				validation.SetError(err)
+				validation.End()
+				metrics.TokenValidations.Inc(authz.FailureReason(err))
+				reqLog.Warn("Unauthorized request to %s: %v", r.URL.Path, err)
+				resourceMetadata := util.GetExternalBaseURL(r) + authz.ProtectedResourcePath
+				if !cfg.DPoP.Required {
+					w.Header().Add("WWW-Authenticate", fmt.Sprintf(`Bearer resource_metadata="%s"`, resourceMetadata))
+				}
+				if algs := authz.DPoPAlgorithms(cfg); algs != nil {
+					w.Header().Add("WWW-Authenticate", fmt.Sprintf(`DPoP algs="%s", resource_metadata="%s"`,
+						strings.Join(algs, " "), resourceMetadata))
+				}
 				http.Error(w, "Unauthorized", http.StatusUnauthorized)
 				return
 			}
+			validation.End()
+			metrics.TokenValidations.Inc(metrics.TokenValid)
+			if missing := authz.MissingScopes(cfg.RequiredScopes, principal.Scopes); len(missing) > 0 {
+				reqLog.Warn("Forbidden request to %s: missing scopes %v", r.URL.Path, missing)
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer error="insufficient_scope", scope="%s"`,
+					strings.Join(cfg.RequiredScopes, " ")))
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+			var ok bool
+			owner, ok = checkSessionOwner(w, r, opts.sessions, principal)
+			if !ok {
+				return
+			}
+			// Forwarded requests were rate limited by the replica they reached first
+			if !forwarded && !allowMCPRequest(w, r, cfg, opts.limiter, principal) {
+				return
+			}
+			// Sessions live on the replica holding their stream. Forwarded
+			// requests are served here even if the store disagrees, so they can't loop.
+			if !forwarded && opts.cluster.isRemote(owner.Owner) {
+				opts.cluster.forward(w, r, owner.Owner)
+				return
+			}
+			ctx := logger.WithFields(r.Context(), "subject", principal.Subject, "session", sessionIDFromRequest(r))
+			r = r.WithContext(authz.WithPrincipal(ctx, principal))
+			reqLog = proxyLog.Ctx(r.Context())
+			countRPCMethods(r)
 			targetURL = mcpBase
+			if opts.pool != nil {
+				// Open requests and streams keep the user's server running
+				userURL, release, ok := userServerURL(w, r, opts, principal)
+				if !ok {
+					return
+				}
+				defer release()
+				targetURL = userURL
+			}
 			if ssePaths[r.URL.Path] {
 				isSSE = true
+			} else {
+				opts.streams.touch(sessionIDFromQuery(r))
 			}
 		} else {
 			http.Error(w, "Forbidden", http.StatusForbidden)
@@ -165,18 +353,43 @@ func buildProxyHandler(cfg *config.Config, modifiers map[string]RequestModifier)
 		}
 
 		// Apply request modifiers to add parameters
-		if modifier, exists := modifiers[r.URL.Path]; exists {
+		if modifier, exists := opts.modifiers[r.URL.Path]; exists {
 			var err error
 			r, err = modifier.ModifyRequest(r)
 			if err != nil {
-				logger.Error("Error modifying request: %v", err)
+				reqLog.Error("Error modifying request: %v", err)
 				http.Error(w, "Bad Request", http.StatusBadRequest)
 				return
 			}
 		}
 
+		// Replace any client-supplied identity headers with the authenticated principal
+		principal := authz.PrincipalFromContext(r.Context())
+		if err := opts.identity.Apply(r.Header, principal); err != nil {
+			reqLog.Error("Error propagating identity: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		// Send the MCP server a token issued for it instead of the user's token
+		if opts.exchanger != nil && principal != nil {
+			token, err := opts.exchanger.Exchange(r.Context(), principal)
+			if err != nil {
+				reqLog.Error("Token exchange failed for %s: %v", principal.Subject, err)
+				http.Error(w, "Bad Gateway", http.StatusBadGateway)
+				return
+			}
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		// Record the messages once nothing stops them from reaching the MCP server
+		scope := auditRequest(r, cfg, opts.audit, principal)
+		r, spans := startRPCSpans(r)
+		defer spans.end()
+
 		// Build the reverse proxy
 		rp := &httputil.ReverseProxy{
+			Transport: tracing.Transport(util.UpstreamTransport()),
 			Director: func(req *http.Request) {
 				// Path rewriting if needed
 				mapped := r.URL.Path
@@ -191,52 +404,88 @@ func buildProxyHandler(cfg *config.Config, modifiers map[string]RequestModifier)
 				req.Host = targetURL.Host
 
 				cleanHeaders := http.Header{}
-
-				// Set proper origin header to match the target
-				if isSSE {
-					// For SSE, ensure origin matches the target
-					req.Header.Set("Origin", targetURL.Scheme+"://"+targetURL.Host)
-				}
-
 				for k, v := range r.Header {
 					// Skip hop-by-hop headers
 					if skipHeader(k) {
 						continue
 					}
 
-					// Set only the first value to avoid duplicates
-					cleanHeaders.Set(k, v[0])
+					// Keep every value, such as repeated tracestate or Accept headers
+					cleanHeaders[k] = append([]string(nil), v...)
 				}
 
 				req.Header = cleanHeaders
 
+				// Set proper origin header to match the target
+				if isSSE {
+					// For SSE, ensure origin matches the target
+					req.Header.Set("Origin", targetURL.Scheme+"://"+targetURL.Host)
+				}
+
 				// Ensure X-Accel-Buffering header is set for SSE requests
 				if isSSE {
 					req.Header.Set("X-Accel-Buffering", "no")
 				}
 
-				logger.Debug("%s -> %s%s", r.URL.Path, req.URL.Host, req.URL.Path)
+				reqLog.Debug("%s -> %s%s", r.URL.Path, req.URL.Host, req.URL.Path)
 			},
 			ModifyResponse: func(resp *http.Response) error {
-				logger.Debug("Response from %s%s: %d", resp.Request.URL.Host, resp.Request.URL.Path, resp.StatusCode)
+				reqLog.Debug("Response from %s%s: %d", resp.Request.URL.Host, resp.Request.URL.Path, resp.StatusCode)
+				if resp.StatusCode >= 500 {
+					spans.fail(fmt.Errorf("MCP server returned %s", resp.Status))
+				}
 				resp.Header.Del("Access-Control-Allow-Origin") // Avoid upstream conflicts
+				trackSessionResponse(resp, opts.sessions, principal, owner, opts.cluster.instance(), time.Duration(cfg.Sessions.TTLSeconds)*time.Second)
+				auditResponse(resp, opts.audit, scope)
 				return nil
 			},
 			ErrorHandler: func(rw http.ResponseWriter, req *http.Request, err error) {
-				logger.Error("Error proxying: %v", err)
+				reqLog.Error("Error proxying: %v", err)
+				metrics.UpstreamErrors.Inc(routeClass(r.URL.Path, cfg))
+				spans.fail(err)
+				abandonRequestScope(opts.audit, scope)
 				http.Error(rw, "Bad Gateway", http.StatusBadGateway)
 			},
 			FlushInterval: -1, // immediate flush for SSE
 		}
 
 		if isSSE {
+			stream, err := opts.streams.acquire(principal)
+			if err != nil {
+				reqLog.Warn("Rejected SSE stream for %s: %v", principal.Subject, err)
+				writeStreamLimited(w, err)
+				return
+			}
+			defer opts.streams.release(stream)
+			metrics.SSEConnections.Inc()
+			defer metrics.SSEConnections.Dec()
+
 			// Add special response handling for SSE connections to rewrite endpoint URLs
-			rp.Transport = &sseTransport{
-				Transport:  http.DefaultTransport,
+			transport := &sseTransport{
+				Transport:  tracing.Transport(util.UpstreamTransport()),
 				proxyHost:  r.Host,
 				targetHost: targetURL.Host,
 				config:     cfg,
+				stream:     stream,
+				tracker:    opts.streams,
+				principal:  principal,
+				sessions:   opts.sessions,
+				instance:   opts.cluster.instance(),
+				audit:      opts.audit,
+				replay:     opts.replay,
 			}
+			if opts.replay != nil {
+				var upstreamID string
+				transport.buffer, transport.missed, upstreamID = opts.replay.open(principal.Subject, r.Header.Get("Last-Event-ID"))
+				defer opts.replay.close(transport.buffer)
+
+				// The MCP server only knows its own event IDs
+				r.Header.Del("Last-Event-ID")
+				if upstreamID != "" {
+					r.Header.Set("Last-Event-ID", upstreamID)
+				}
+			}
+			rp.Transport = transport
 
 			// Set SSE-specific headers BEFORE proxying
 			// This ensures they are sent to the client regardless of upstream response
@@ -255,12 +504,76 @@ func buildProxyHandler(cfg *config.Config, modifiers map[string]RequestModifier)
 	}
 }
 
+// userServerURL returns the URL of the principal's own server, writing an
+// error response if it can't be used. The server is held until release is
+// called, when the request or stream ends.
+func userServerURL(w http.ResponseWriter, r *http.Request, opts *handlerOptions, principal *authz.Principal) (*url.URL, func(), bool) {
+	if principal.Subject == "" {
+		http.Error(w, "Token has no subject", http.StatusForbidden)
+		return nil, nil, false
+	}
+
+	env, missing := opts.broker.Environment(r.Context(), principal.Subject)
+	if len(missing) > 0 {
+		proxyLog.Info("Credentials %v not connected for %s", missing, principal.Subject)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":             "credentials_required",
+			"error_description": "Connect the missing credentials before using this server",
+			"missing":           missing,
+			"credentials_uri":   util.GetExternalBaseURL(r) + vault.PathPrefix + "credentials",
+		})
+		return nil, nil, false
+	}
+
+	rawURL, release, err := opts.pool.Acquire(principal.Subject, env)
+	if errors.Is(err, subprocess.ErrPoolFull) {
+		proxyLog.Warn("No server slot for %s: %v", principal.Subject, err)
+		w.Header().Set("Retry-After", "30")
+		http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+		return nil, nil, false
+	}
+	if err != nil {
+		proxyLog.Error("Failed to start server for %s: %v", principal.Subject, err)
+		http.Error(w, "Bad Gateway", http.StatusBadGateway)
+		return nil, nil, false
+	}
+
+	userURL, err := url.Parse(rawURL)
+	if err != nil {
+		release()
+		http.Error(w, "Bad Gateway", http.StatusBadGateway)
+		return nil, nil, false
+	}
+	return userURL, release, true
+}
+
+// withCORS applies the configured CORS policy to handlers outside the proxy
+func withCORS(cfg *config.Config, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		allowedOrigin := getAllowedOrigin(r.Header.Get("Origin"), cfg)
+		if allowedOrigin != "" {
+			addCORSHeaders(w, cfg, allowedOrigin, r.Header.Get("Access-Control-Request-Headers"))
+		}
+		if r.Method == http.MethodOptions {
+			if allowedOrigin == "" {
+				http.Error(w, "CORS origin not allowed", http.StatusForbidden)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 func getAllowedOrigin(origin string, cfg *config.Config) string {
 	if origin == "" {
 		return cfg.CORSConfig.AllowedOrigins[0] // Default to first allowed origin
 	}
 	for _, allowed := range cfg.CORSConfig.AllowedOrigins {
-		logger.Debug("Checking CORS origin: %s against allowed: %s", origin, allowed)
+		proxyLog.Debug("Checking CORS origin: %s against allowed: %s", origin, allowed)
 		if allowed == origin {
 			return allowed
 		}
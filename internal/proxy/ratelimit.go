@@ -0,0 +1,230 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wso2/open-mcp-auth-proxy/internal/authz"
+	"github.com/wso2/open-mcp-auth-proxy/internal/config"
+	"github.com/wso2/open-mcp-auth-proxy/internal/ratelimit"
+)
+
+// maxRPCPeek bounds how much of a message body is inspected for tool calls
+const maxRPCPeek = 1 << 20
+
+// jsonRPCRateLimited is the JSON-RPC error code of rate limited messages,
+// from the range reserved for implementation-defined server errors
+const jsonRPCRateLimited = -32029
+
+// rpcRequest is the part of a JSON-RPC request the proxy inspects
+type rpcRequest struct {
+	ID     json.RawMessage `json:"id,omitempty"`
+	Method string          `json:"method"`
+	Params struct {
+		Name string `json:"name"`
+	} `json:"params"`
+}
+
+// peekBody returns the start of a POST body without consuming it
+func peekBody(r *http.Request) []byte {
+	if r.Body == nil || r.Method != http.MethodPost {
+		return nil
+	}
+	peek, err := io.ReadAll(io.LimitReader(r.Body, maxRPCPeek))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(peek), r.Body), r.Body}
+	if err != nil {
+		return nil
+	}
+	return peek
+}
+
+// peekRPC parses the JSON-RPC message or batch in the request body without
+// consuming it. Bodies that aren't JSON-RPC yield no requests.
+func peekRPC(r *http.Request) []rpcRequest {
+	trimmed := bytes.TrimSpace(peekBody(r))
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		var batch []rpcRequest
+		if json.Unmarshal(trimmed, &batch) != nil {
+			return nil
+		}
+		return batch
+	}
+	var single rpcRequest
+	if json.Unmarshal(trimmed, &single) != nil {
+		return nil
+	}
+	return []rpcRequest{single}
+}
+
+// calledTools returns the names of the tools called by the messages
+func calledTools(messages []rpcRequest) []string {
+	var tools []string
+	for _, m := range messages {
+		if m.Method == "tools/call" && m.Params.Name != "" {
+			tools = append(tools, m.Params.Name)
+		}
+	}
+	return tools
+}
+
+// clientIP returns the address of the caller, optionally as reported by the
+// trusted reverse proxies in X-Forwarded-For. Each proxy appends the address it
+// got the request from, so only the last trustedHops entries can be trusted;
+// entries further left are whatever the client sent.
+func clientIP(r *http.Request, trustForwardedFor bool, trustedHops int) string {
+	if trustForwardedFor {
+		var entries []string
+		for _, value := range r.Header.Values("X-Forwarded-For") {
+			for _, entry := range strings.Split(value, ",") {
+				if entry = strings.TrimSpace(entry); entry != "" {
+					entries = append(entries, entry)
+				}
+			}
+		}
+		if len(entries) > 0 {
+			i := len(entries) - trustedHops
+			if i < 0 {
+				i = 0 // Fewer proxies than configured; all entries are theirs
+			}
+			return entries[i]
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// authClientID returns the client of an authorization server request, from
+// the query, HTTP basic client authentication or the form body of token requests
+func authClientID(r *http.Request) string {
+	if clientID := r.URL.Query().Get("client_id"); clientID != "" {
+		return clientID
+	}
+	if clientID, _, ok := r.BasicAuth(); ok {
+		return clientID
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "application/x-www-form-urlencoded" {
+		return ""
+	}
+	// The body is left in place for the authorization server
+	form, err := url.ParseQuery(string(peekBody(r)))
+	if err != nil {
+		return ""
+	}
+	return form.Get("client_id")
+}
+
+// requestIP returns the caller's address under the rate limit configuration
+func requestIP(r *http.Request, cfg *config.Config) string {
+	return clientIP(r, cfg.RateLimit.TrustForwardedFor, cfg.RateLimit.TrustedHops)
+}
+
+// allowAuthRequest applies the auth endpoint limits, writing a 429 response when exceeded
+func allowAuthRequest(w http.ResponseWriter, r *http.Request, cfg *config.Config, limiter *ratelimit.Limiter) bool {
+	if limiter == nil {
+		return true
+	}
+	allowed, retryAfter := limiter.AllowAuth(ratelimit.Request{
+		ClientID: authClientID(r),
+		IP:       requestIP(r, cfg),
+	})
+	if allowed {
+		return true
+	}
+	proxyLog.Warn("Rate limited auth request to %s from %s", r.URL.Path, requestIP(r, cfg))
+	writeRateLimited(w, retryAfter, nil)
+	return false
+}
+
+// allowMCPRequest applies the MCP limits to an authenticated request. Limited
+// messages get a JSON-RPC error so clients can relate it to their request.
+func allowMCPRequest(w http.ResponseWriter, r *http.Request, cfg *config.Config, limiter *ratelimit.Limiter, principal *authz.Principal) bool {
+	if limiter == nil {
+		return true
+	}
+
+	var messages []rpcRequest
+	isMessages := strings.HasPrefix(r.URL.Path, cfg.Paths.Messages)
+	if isMessages {
+		messages = peekRPC(r)
+	}
+
+	allowed, retryAfter := limiter.AllowMCP(ratelimit.Request{
+		Subject:  principal.Subject,
+		ClientID: principal.ClientID,
+		IP:       requestIP(r, cfg),
+		Tools:    calledTools(messages),
+	})
+	if allowed {
+		return true
+	}
+
+	proxyLog.Warn("Rate limited %s request from %s", r.URL.Path, principal.Subject)
+	if !isMessages {
+		writeRateLimited(w, retryAfter, nil)
+		return false
+	}
+	var id json.RawMessage
+	if len(messages) == 1 {
+		id = messages[0].ID
+	}
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	writeRateLimited(w, retryAfter, id)
+	return false
+}
+
+// writeRateLimited writes a 429 response, with a JSON-RPC error body when rpcID is set
+func writeRateLimited(w http.ResponseWriter, retryAfter time.Duration, rpcID json.RawMessage) {
+	seconds := ratelimit.RetryAfterSeconds(retryAfter)
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+
+	if rpcID == nil {
+		http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusTooManyRequests)
+	body := map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      rpcID,
+		"error": map[string]interface{}{
+			"code":    jsonRPCRateLimited,
+			"message": fmt.Sprintf("Rate limit exceeded, retry after %d seconds", seconds),
+			"data":    map[string]int{"retry_after": seconds},
+		},
+	}
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		proxyLog.Error("Error encoding rate limit response: %v", err)
+	}
+}
+
+// withAuthRateLimit applies the auth endpoint limits to a handler served by the proxy
+func withAuthRateLimit(cfg *config.Config, limiter *ratelimit.Limiter, next http.HandlerFunc) http.HandlerFunc {
+	if limiter == nil {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodOptions && !allowAuthRequest(w, r, cfg, limiter) {
+			return
+		}
+		next(w, r)
+	}
+}
@@ -0,0 +1,117 @@
+package proxy
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/wso2/open-mcp-auth-proxy/internal/config"
+)
+
+func TestMCPRequestRateLimited(t *testing.T) {
+	var body string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		body = string(raw)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer backend.Close()
+
+	cfg := newTestConfig(backend.URL)
+	cfg.RateLimit = config.RateLimitConfig{
+		Enabled: true,
+		MaxKeys: 100,
+		MCP:     []config.RateLimitRule{{Key: "subject,tool", RequestsPerMinute: 1, Burst: 1}},
+	}
+	router := NewRouter(cfg, newStubProvider(), withTestSessions("abc"))
+
+	send := func(message string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/messages?sessionId=abc", strings.NewReader(message))
+		req.Header.Set("Authorization", "Bearer good-token")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	call := `{"jsonrpc":"2.0","id":7,"method":"tools/call","params":{"name":"search"}}`
+
+	if w := send(call); w.Code != http.StatusAccepted {
+		t.Fatalf("Expected first call to be proxied, got %v", w.Code)
+	}
+	if body != call {
+		t.Errorf("Expected the backend to receive the full message, got %s", body)
+	}
+
+	w := send(call)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status 429, got %v", w.Code)
+	}
+	if w.Header().Get("Retry-After") != "60" {
+		t.Errorf("Expected Retry-After 60, got %q", w.Header().Get("Retry-After"))
+	}
+	var rpcError struct {
+		ID    int `json:"id"`
+		Error struct {
+			Code int `json:"code"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &rpcError); err != nil {
+		t.Fatalf("Expected a JSON-RPC error body: %v", err)
+	}
+	if rpcError.ID != 7 || rpcError.Error.Code != jsonRPCRateLimited {
+		t.Errorf("Expected error %d for id 7, got %+v", jsonRPCRateLimited, rpcError)
+	}
+
+	// Other tools and messages without tool calls have their own buckets
+	if w := send(`{"jsonrpc":"2.0","id":8,"method":"tools/call","params":{"name":"fetch"}}`); w.Code != http.StatusAccepted {
+		t.Errorf("Expected a different tool to be proxied, got %v", w.Code)
+	}
+	if w := send(`{"jsonrpc":"2.0","id":9,"method":"tools/list"}`); w.Code != http.StatusAccepted {
+		t.Errorf("Expected tools/list to be proxied, got %v", w.Code)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name      string
+		forwarded []string
+		trust     bool
+		hops      int
+		expected  string
+	}{
+		{"Untrusted header", []string{"203.0.113.5, 10.0.0.2"}, false, 1, "10.0.0.1"},
+		{"Entry added by the trusted proxy", []string{"203.0.113.5, 10.0.0.2"}, true, 1, "10.0.0.2"},
+		{"Two trusted proxies", []string{"198.51.100.7, 203.0.113.5, 10.0.0.2"}, true, 2, "203.0.113.5"},
+		{"Repeated headers", []string{"198.51.100.7", "203.0.113.5"}, true, 1, "203.0.113.5"},
+		{"Fewer entries than hops", []string{"203.0.113.5"}, true, 2, "203.0.113.5"},
+		{"No header", nil, true, 1, "10.0.0.1"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/token", nil)
+			req.RemoteAddr = "10.0.0.1:1234"
+			for _, value := range tc.forwarded {
+				req.Header.Add("X-Forwarded-For", value)
+			}
+			if ip := clientIP(req, tc.trust, tc.hops); ip != tc.expected {
+				t.Errorf("Expected %s, got %s", tc.expected, ip)
+			}
+		})
+	}
+}
+
+func TestAuthClientIDFromTokenForm(t *testing.T) {
+	form := "grant_type=authorization_code&code=abc&client_id=public-client"
+	req := httptest.NewRequest("POST", "/token", strings.NewReader(form))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	if clientID := authClientID(req); clientID != "public-client" {
+		t.Errorf("Expected client_id from the form body, got %q", clientID)
+	}
+	if body, _ := io.ReadAll(req.Body); string(body) != form {
+		t.Errorf("Expected the body to be left for the authorization server, got %q", body)
+	}
+}
@@ -0,0 +1,134 @@
+package proxy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wso2/open-mcp-auth-proxy/internal/config"
+	"github.com/wso2/open-mcp-auth-proxy/internal/sse"
+)
+
+// replayBuffers keeps the recent events of SSE streams so that clients
+// reconnecting with Last-Event-ID receive the events they missed. Events get
+// proxy IDs of the form <stream ID>-<sequence>.
+type replayBuffers struct {
+	size      int
+	retention time.Duration
+	now       func() time.Time
+
+	mu      sync.Mutex
+	streams map[string]*replayBuffer
+}
+
+// replayBuffer holds the last events of one stream
+type replayBuffer struct {
+	id      string
+	subject string
+
+	// Guarded by replayBuffers.mu
+	nextSeq        uint64
+	events         []bufferedEvent
+	lastUpstreamID string // Last event ID sent by the MCP server, for resuming upstream
+	active         bool
+	endedAt        time.Time
+}
+
+type bufferedEvent struct {
+	seq  uint64
+	text string
+}
+
+// newReplayBuffers returns nil when replay is disabled
+func newReplayBuffers(cfg config.SSEConfig) *replayBuffers {
+	if cfg.ReplayBufferSize <= 0 {
+		return nil
+	}
+	return &replayBuffers{
+		size:      cfg.ReplayBufferSize,
+		retention: time.Duration(cfg.ReplayRetentionSeconds) * time.Second,
+		now:       time.Now,
+		streams:   make(map[string]*replayBuffer),
+	}
+}
+
+// open starts buffering a stream for subject. When lastEventID names an
+// ended stream of the same subject, that stream is resumed: open returns the
+// events sent after lastEventID and the upstream event ID to resume from.
+func (b *replayBuffers) open(subject, lastEventID string) (buf *replayBuffer, missed []string, upstreamID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// Forget streams that ended longer ago than the retention
+	now := b.now()
+	for id, stream := range b.streams {
+		if !stream.active && now.Sub(stream.endedAt) > b.retention {
+			delete(b.streams, id)
+		}
+	}
+
+	if streamID, seq, ok := parseEventID(lastEventID); ok {
+		if stream, found := b.streams[streamID]; found && !stream.active && stream.subject == subject {
+			stream.active = true
+			for _, event := range stream.events {
+				if event.seq > seq {
+					missed = append(missed, event.text)
+				}
+			}
+			return stream, missed, stream.lastUpstreamID
+		}
+	}
+
+	stream := &replayBuffer{id: randomID(), subject: subject, nextSeq: 1, active: true}
+	b.streams[stream.id] = stream
+	return stream, nil, ""
+}
+
+// record assigns the event its proxy ID and keeps its wire form for replay
+func (b *replayBuffers) record(buf *replayBuffer, event *sse.Event) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// Comment-only events, such as pings, aren't worth replaying
+	if !event.HasData && event.Type == "" && !event.HasID && event.Retry == 0 {
+		return sse.Format(event)
+	}
+
+	if event.HasID {
+		buf.lastUpstreamID = event.ID
+	}
+	seq := buf.nextSeq
+	buf.nextSeq++
+	event.ID = fmt.Sprintf("%s-%d", buf.id, seq)
+	event.HasID = true
+
+	text := sse.Format(event)
+	buf.events = append(buf.events, bufferedEvent{seq: seq, text: text})
+	if len(buf.events) > b.size {
+		buf.events = buf.events[len(buf.events)-b.size:]
+	}
+	return text
+}
+
+// close marks a stream as ended, keeping it for resumption until the retention passes
+func (b *replayBuffers) close(buf *replayBuffer) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	buf.active = false
+	buf.endedAt = b.now()
+}
+
+// parseEventID splits a proxy event ID into its stream ID and sequence number
+func parseEventID(id string) (string, uint64, bool) {
+	i := strings.LastIndex(id, "-")
+	if i <= 0 {
+		return "", 0, false
+	}
+	seq, err := strconv.ParseUint(id[i+1:], 10, 64)
+	if err != nil {
+		return "", 0, false
+	}
+	return id[:i], seq, true
+}
@@ -0,0 +1,100 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/wso2/open-mcp-auth-proxy/internal/config"
+	"github.com/wso2/open-mcp-auth-proxy/internal/sse"
+)
+
+func TestReplayBuffers(t *testing.T) {
+	replay := newReplayBuffers(config.SSEConfig{ReplayBufferSize: 2, ReplayRetentionSeconds: 60})
+
+	buf, missed, _ := replay.open("alice", "")
+	if missed != nil {
+		t.Fatalf("Expected nothing to replay for a new stream")
+	}
+	for _, data := range []string{"one", "two", "three"} {
+		replay.record(buf, &sse.Event{Data: data, HasData: true, ID: "up-" + data, HasID: true})
+	}
+	replay.close(buf)
+
+	tests := []struct {
+		name        string
+		subject     string
+		lastEventID string
+		missed      int
+		upstreamID  string
+	}{
+		{"Other subject starts a new stream", "bob", buf.id + "-1", 0, ""},
+		{"Unknown stream starts a new stream", "alice", "unknown-1", 0, ""},
+		{"Resume replays buffered events after the ID", "alice", buf.id + "-2", 1, "up-three"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			resumed, missed, upstreamID := replay.open(tc.subject, tc.lastEventID)
+			defer replay.close(resumed)
+			if len(missed) != tc.missed || upstreamID != tc.upstreamID {
+				t.Errorf("Expected %d missed events from %q, got %d from %q", tc.missed, tc.upstreamID, len(missed), upstreamID)
+			}
+			if tc.missed > 0 && !strings.Contains(missed[0], "id: "+buf.id+"-3\n") {
+				t.Errorf("Expected event 3 to be replayed, got %q", missed[0])
+			}
+		})
+	}
+
+	if newReplayBuffers(config.SSEConfig{}) != nil {
+		t.Errorf("Expected no replay buffers when replay is disabled")
+	}
+}
+
+func TestSSEStreamResumption(t *testing.T) {
+	var lastEventID string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lastEventID = r.Header.Get("Last-Event-ID")
+		w.Header().Set("Content-Type", "text/event-stream")
+		// CRLF line endings and no space after the colon
+		io.WriteString(w, "event:endpoint\r\ndata:/messages?sessionId=abc\r\n\r\nid:u1\r\ndata:{\"n\":1}\r\n\r\n")
+	}))
+	defer backend.Close()
+
+	cfg := newTestConfig(backend.URL)
+	cfg.SSE.ReplayBufferSize = 10
+	cfg.SSE.ReplayRetentionSeconds = 60
+	router := NewRouter(cfg, newStubProvider())
+
+	connect := func(lastEventID string) string {
+		req := httptest.NewRequest("GET", "/sse", nil)
+		req.Header.Set("Authorization", "Bearer good-token")
+		if lastEventID != "" {
+			req.Header.Set("Last-Event-ID", lastEventID)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Body.String()
+	}
+
+	first := connect("")
+	if !strings.Contains(first, "event: endpoint\ndata: https://example.com/messages?sessionId=abc\n") {
+		t.Errorf("Expected rewritten endpoint event, got %q", first)
+	}
+	events := strings.Split(strings.TrimSpace(first), "\n\n")
+	if len(events) != 2 || !strings.HasPrefix(events[0], "id: ") {
+		t.Fatalf("Expected two numbered events, got %q", first)
+	}
+	firstID := strings.TrimPrefix(strings.SplitN(events[0], "\n", 2)[0], "id: ")
+
+	// The client saw only the endpoint event, so the data event is replayed and
+	// the MCP server is asked to resume after its own last event ID
+	resumed := connect(firstID)
+	if lastEventID != "u1" {
+		t.Errorf("Expected upstream Last-Event-ID u1, got %q", lastEventID)
+	}
+	if !strings.HasPrefix(resumed, events[1]+"\n\n") {
+		t.Errorf("Expected missed event %q to be replayed first, got %q", events[1], resumed)
+	}
+}
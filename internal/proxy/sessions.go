@@ -0,0 +1,94 @@
+package proxy
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/wso2/open-mcp-auth-proxy/internal/authz"
+	"github.com/wso2/open-mcp-auth-proxy/internal/session"
+)
+
+// SessionIDHeader carries the session of the Streamable HTTP transport
+const SessionIDHeader = "Mcp-Session-Id"
+
+// sessionIDFromRequest returns the MCP session a request belongs to, from the
+// Mcp-Session-Id header or the message endpoint's query
+func sessionIDFromRequest(r *http.Request) string {
+	if id := r.Header.Get(SessionIDHeader); id != "" {
+		return id
+	}
+	return sessionIDFromQuery(r)
+}
+
+// bindSession records principal as the owner of a new session, held by the
+// replica at instance. A zero ttl keeps the session until it is deleted.
+func bindSession(store session.Store, id string, principal *authz.Principal, instance string, ttl time.Duration) {
+	if id == "" || principal == nil {
+		return
+	}
+	s := session.Session{ID: id, Subject: principal.Subject, ClientID: principal.ClientID, Owner: instance}
+	if ttl > 0 {
+		s.Expires = time.Now().Add(ttl)
+	}
+	if err := store.Put(s); err != nil {
+		proxyLog.Error("Failed to record session owner: %v", err)
+	}
+}
+
+// checkSessionOwner rejects requests on a session opened by another principal
+// and returns the session. Sessions the proxy doesn't know, because they
+// expired or were never opened through it, get 404 so the client starts a new
+// one; adopting them would hand them to whoever presents their ID.
+func checkSessionOwner(w http.ResponseWriter, r *http.Request, store session.Store, principal *authz.Principal) (session.Session, bool) {
+	id := sessionIDFromRequest(r)
+	if id == "" {
+		return session.Session{}, true
+	}
+
+	owner, ok, err := store.Get(id)
+	if err != nil {
+		// Without the owner the request can't be checked, so it can't be let through
+		proxyLog.Error("Failed to look up session owner: %v", err)
+		http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+		return session.Session{}, false
+	}
+	if !ok {
+		proxyLog.Warn("Rejected request from %s on unknown session", principal.Subject)
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return session.Session{}, false
+	}
+	if owner.Subject != principal.Subject || owner.ClientID != principal.ClientID {
+		proxyLog.Warn("Rejected request from %s on a session of %s", principal.Subject, owner.Subject)
+		http.Error(w, "Session belongs to another user", http.StatusForbidden)
+		return session.Session{}, false
+	}
+	return owner, true
+}
+
+// trackSessionResponse binds sessions created by the MCP server's response,
+// extends the known session the request was made on and forgets sessions it
+// deleted. Session IDs sent by clients are never bound.
+func trackSessionResponse(resp *http.Response, store session.Store, principal *authz.Principal, known session.Session, instance string, ttl time.Duration) {
+	if principal == nil || resp.StatusCode >= 300 {
+		return
+	}
+	req := resp.Request
+
+	if req.Method == http.MethodDelete {
+		if id := req.Header.Get(SessionIDHeader); id != "" {
+			if err := store.Delete(id); err != nil {
+				proxyLog.Error("Failed to forget session: %v", err)
+			}
+		}
+		return
+	}
+
+	// New sessions are announced in the response; existing ones are extended
+	if id := resp.Header.Get(SessionIDHeader); id != "" && id != known.ID {
+		bindSession(store, id, principal, instance, ttl)
+		return
+	}
+	if known.ID != "" && known.ID == req.Header.Get(SessionIDHeader) {
+		bindSession(store, known.ID, principal, instance, ttl)
+	}
+}
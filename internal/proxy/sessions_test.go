@@ -0,0 +1,152 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/wso2/open-mcp-auth-proxy/internal/authz"
+	"github.com/wso2/open-mcp-auth-proxy/internal/session"
+)
+
+func TestSessionBoundToSubject(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/sse" {
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Write([]byte("event: endpoint\ndata: /messages?sessionId=abc\n\n"))
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer backend.Close()
+
+	store := session.NewMemoryStore()
+	provider := newStubProvider()
+	router := NewRouter(newTestConfig(backend.URL), provider, WithSessionStore(store))
+
+	// The stream binds its session and forgets it when it ends
+	req := httptest.NewRequest("GET", "/sse", nil)
+	req.Header.Set("Authorization", "Bearer good-token")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	if _, ok, _ := store.Get("abc"); ok {
+		t.Errorf("Expected the session to be forgotten when its stream ended")
+	}
+	store.Put(session.Session{ID: "abc", Subject: "user-1", ClientID: "client-1"})
+
+	tests := []struct {
+		name      string
+		principal authz.Principal
+		header    string
+		expected  int
+	}{
+		{"Owner", authz.Principal{Subject: "user-1", ClientID: "client-1"}, "", http.StatusAccepted},
+		{"Other subject", authz.Principal{Subject: "user-2", ClientID: "client-1"}, "", http.StatusForbidden},
+		{"Other client", authz.Principal{Subject: "user-1", ClientID: "client-2"}, "", http.StatusForbidden},
+		{"Other subject via Mcp-Session-Id", authz.Principal{Subject: "user-2", ClientID: "client-1"}, "abc", http.StatusForbidden},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			principal := tc.principal
+			provider.principal = &principal
+
+			path := "/messages?sessionId=abc"
+			if tc.header != "" {
+				path = "/messages"
+			}
+			req := httptest.NewRequest("POST", path, strings.NewReader(`{}`))
+			req.Header.Set("Authorization", "Bearer good-token")
+			if tc.header != "" {
+				req.Header.Set(SessionIDHeader, tc.header)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != tc.expected {
+				t.Errorf("Expected status %d, got %d", tc.expected, w.Code)
+			}
+		})
+	}
+}
+
+func TestSessionBoundFromEndpointEvent(t *testing.T) {
+	store := session.NewMemoryStore()
+	cfg := newTestConfig("http://localhost:8000")
+	cfg.Sessions.TTLSeconds = 60
+	transport := &sseTransport{
+		Transport: sseBackend(t),
+		proxyHost: "localhost:8080",
+		config:    cfg,
+		principal: &authz.Principal{Subject: "alice", ClientID: "app"},
+		sessions:  store,
+	}
+	resp, err := transport.RoundTrip(httptest.NewRequest("GET", "http://localhost:8000/sse", nil))
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+
+	// Read the endpoint event; the session is bound before it is delivered
+	buf := make([]byte, 256)
+	resp.Body.Read(buf)
+	owner, ok, _ := store.Get("abc")
+	if !ok || owner.Subject != "alice" || owner.ClientID != "app" {
+		t.Errorf("Expected session abc to be bound to alice/app, got %+v", owner)
+	}
+	if owner.Expires.IsZero() {
+		t.Errorf("Expected session abc to expire with the session TTL")
+	}
+	resp.Body.Close()
+}
+
+func TestSessionBoundFromResponseHeader(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(SessionIDHeader) == "" {
+			w.Header().Set(SessionIDHeader, "streamable-1")
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	store := session.NewMemoryStore()
+	router := NewRouter(newTestConfig(backend.URL), newStubProvider(), WithSessionStore(store))
+
+	send := func(method, sessionID string) {
+		req := httptest.NewRequest(method, "/messages", strings.NewReader(`{}`))
+		req.Header.Set("Authorization", "Bearer good-token")
+		if sessionID != "" {
+			req.Header.Set(SessionIDHeader, sessionID)
+		}
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	send("POST", "")
+	if owner, ok, _ := store.Get("streamable-1"); !ok || owner.Subject != "user-1" {
+		t.Errorf("Expected session from the response header to be bound to user-1, got %+v", owner)
+	}
+	send("DELETE", "streamable-1")
+	if _, ok, _ := store.Get("streamable-1"); ok {
+		t.Errorf("Expected deleted session to be forgotten")
+	}
+}
+
+func TestUnknownSessionRejected(t *testing.T) {
+	backend, last := newTestBackend(t)
+	store := session.NewMemoryStore()
+	router := NewRouter(newTestConfig(backend.URL), newStubProvider(), WithSessionStore(store))
+
+	// An ID the proxy doesn't know, such as one whose owner record expired
+	req := httptest.NewRequest("POST", "/messages", strings.NewReader(`{}`))
+	req.Header.Set("Authorization", "Bearer good-token")
+	req.Header.Set(SessionIDHeader, "someone-elses")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+	if *last != nil {
+		t.Errorf("Expected the request not to reach the MCP server")
+	}
+	if _, ok, _ := store.Get("someone-elses"); ok {
+		t.Errorf("Expected the session not to be adopted")
+	}
+}
@@ -1,38 +1,32 @@
 package proxy
 
 import (
-	"bufio"
 	"context"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httputil"
+	"net/url"
 	"strings"
+	"sync"
 	"time"
-	
+
+	"github.com/wso2/open-mcp-auth-proxy/internal/audit"
+	"github.com/wso2/open-mcp-auth-proxy/internal/authz"
 	"github.com/wso2/open-mcp-auth-proxy/internal/config"
-	"github.com/wso2/open-mcp-auth-proxy/internal/logging"
+	"github.com/wso2/open-mcp-auth-proxy/internal/session"
+	"github.com/wso2/open-mcp-auth-proxy/internal/sse"
 	"github.com/wso2/open-mcp-auth-proxy/internal/util"
 )
 
-// HandleSSE sets up a go-routine to wait for context cancellation
-// and flushes the response if possible.
+// HandleSSE proxies an SSE stream, flushing events as they arrive, until
+// either side closes it
 func HandleSSE(w http.ResponseWriter, r *http.Request, rp *httputil.ReverseProxy) {
-	ctx := r.Context()
-	done := make(chan struct{})
-
-	go func() {
-		<-ctx.Done()
-		logger.Info("SSE connection closed from %s (path: %s)", r.RemoteAddr, r.URL.Path)
-		close(done)
-	}()
-
 	rp.ServeHTTP(w, r)
 	if f, ok := w.(http.Flusher); ok {
 		f.Flush()
 	}
-
-	<-done
+	sseLog.Ctx(r.Context()).Info("SSE connection closed from %s (path: %s)", r.RemoteAddr, r.URL.Path)
 }
 
 // NewShutdownContext is a little helper to gracefully shut down
@@ -46,6 +40,25 @@ type sseTransport struct {
 	proxyHost  string
 	targetHost string
 	config     *config.Config
+
+	// The stream being proxied and its tracker, nil for untracked streams
+	stream  *sseStream
+	tracker *streamTracker
+
+	// The principal of the stream and the store its session is recorded in
+	principal *authz.Principal
+	sessions  session.Store
+	sessionID string // Owned by the reader goroutine
+	instance  string // URL of this replica in a cluster, recorded as the session's owner
+
+	// Completes the audit records of the session's messages, nil unless auditing is enabled
+	audit *audit.Auditor
+
+	// Replay buffer of the stream, nil when replay is disabled. missed holds
+	// the events to send first to a resuming client.
+	replay *replayBuffers
+	buffer *replayBuffer
+	missed []string
 }
 
 func (t *sseTransport) RoundTrip(req *http.Request) (*http.Response, error) {
@@ -54,87 +67,260 @@ func (t *sseTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 	if err != nil {
 		return nil, err
 	}
-	
+
 	// Check if this is an SSE response
 	contentType := resp.Header.Get("Content-Type")
 	if !strings.Contains(contentType, "text/event-stream") {
 		return resp, nil
 	}
-	
-	logger.Info("Intercepting SSE response to modify endpoint events")
-	
+
+	sseLog.Ctx(req.Context()).Info("Intercepting SSE response to modify endpoint events")
+
 	// Determine the actual proxy host to use (considering EXTERNAL_HOST)
 	actualProxyHost := util.GetExternalHost(t.proxyHost)
-	
-	// Create a response wrapper that modifies the response body
+
+	// Upstream events are read in one goroutine and written to the client in
+	// another, so that proxy-generated events never split an upstream event
 	originalBody := resp.Body
 	pr, pw := io.Pipe()
-	
+	events := make(chan string)
+	stopped := make(chan struct{})
+
+	go t.readEvents(originalBody, events, stopped, actualProxyHost)
 	go func() {
-		defer originalBody.Close()
+		defer close(stopped)
+		defer originalBody.Close() // Unblocks the reader when the stream is ended early
 		defer func() {
 			if err := pw.Close(); err != nil {
-				logger.Debug("Error closing pipe writer: %v", err)
+				sseLog.Debug("Error closing pipe writer: %v", err)
 			}
 		}()
-		
-		scanner := bufio.NewScanner(originalBody)
-		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
-		
-		for scanner.Scan() {
-			line := scanner.Text()
-			
-			// Check if this line contains an endpoint event
-			if strings.HasPrefix(line, "event: endpoint") {
-				// Read the data line
-				if scanner.Scan() {
-					dataLine := scanner.Text()
-					if strings.HasPrefix(dataLine, "data: ") {
-						// Extract the endpoint URL
-						endpoint := strings.TrimPrefix(dataLine, "data: ")
-						
-						// Rewrite the endpoint to use proxy paths
-						modifiedEndpoint := t.rewriteEndpoint(endpoint, actualProxyHost)
-						
-						// Write the modified event lines
-						if _, err := fmt.Fprintln(pw, line); err != nil {
-							logger.Error("Error writing event line: %v", err)
-							return
-						}
-						if _, err := fmt.Fprintln(pw, "data: "+modifiedEndpoint); err != nil {
-							logger.Error("Error writing data line: %v", err)
-							return
-						}
-						continue
-					}
+		t.writeEvents(pw, events)
+	}()
+
+	// Replace the response body with our modified pipe
+	resp.Body = pr
+	return resp, nil
+}
+
+// readEvents parses the upstream stream, rewriting endpoint events and
+// numbering events for replay when enabled
+func (t *sseTransport) readEvents(body io.Reader, events chan<- string, stopped <-chan struct{}, proxyHost string) {
+	defer close(events)
+
+	// The session's record is kept alive while the stream is open
+	var refreshing sync.WaitGroup
+	ended := make(chan struct{})
+
+	defer func() {
+		close(ended)
+		refreshing.Wait()
+		// The session ends with its stream
+		if t.sessionID != "" {
+			if err := t.sessions.Delete(t.sessionID); err != nil {
+				sseLog.Error("Failed to forget session: %v", err)
+			}
+			t.audit.Abandon(t.sessionID)
+		}
+	}()
+
+	reader := sse.NewReader(body)
+	for {
+		event, err := reader.Next()
+		if err != nil {
+			if err != io.EOF {
+				select {
+				case <-stopped: // Closed by the writer
+				default:
+					sseLog.Error("Error reading SSE stream: %v", err)
 				}
 			}
-			
-			// Write the original line for non-endpoint events
-			if _, err := fmt.Fprintln(pw, line); err != nil {
-				logger.Error("Error writing line: %v", err)
-				return
+			return
+		}
+
+		bound := t.sessionID
+		t.rewriteEvent(event, proxyHost)
+		if bound == "" && t.sessionID != "" {
+			refreshing.Add(1)
+			go func(id string) {
+				defer refreshing.Done()
+				t.refreshSession(id, ended)
+			}(t.sessionID)
+		}
+		if t.sessionID != "" && event.HasData && (event.Type == "" || event.Type == "message") {
+			t.audit.Response(t.sessionID, []byte(event.Data))
+		}
+		var text string
+		if t.buffer != nil {
+			text = t.replay.record(t.buffer, event)
+		} else {
+			text = sse.Format(event)
+		}
+
+		select {
+		case events <- text:
+		case <-stopped:
+			return
+		}
+	}
+}
+
+// rewriteEvent points endpoint events at the proxy and records their session
+func (t *sseTransport) rewriteEvent(event *sse.Event, proxyHost string) {
+	if event.Type != "endpoint" || !event.HasData {
+		return
+	}
+
+	endpoint := strings.TrimSpace(event.Data)
+	sessionID := sessionIDFromEndpoint(endpoint)
+	if t.tracker != nil && t.stream != nil {
+		t.tracker.bindSession(t.stream, sessionID)
+	}
+	if t.sessions != nil && sessionID != "" {
+		// Only the principal that opened the stream may post to its session
+		bindSession(t.sessions, sessionID, t.principal, t.instance, t.sessionTTL())
+		t.sessionID = sessionID
+	}
+
+	// Rewrite the endpoint to use proxy paths
+	event.Data = t.rewriteEndpoint(endpoint, proxyHost)
+}
+
+// sessionTTL is how long a session outlives the last refresh of its record,
+// so the records of replicas that crash expire
+func (t *sseTransport) sessionTTL() time.Duration {
+	return time.Duration(t.config.Sessions.TTLSeconds) * time.Second
+}
+
+// refreshSession extends the session's record until ended is closed
+func (t *sseTransport) refreshSession(id string, ended <-chan struct{}) {
+	ttl := t.sessionTTL()
+	if ttl <= 0 {
+		return
+	}
+	ticker := time.NewTicker(ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			bindSession(t.sessions, id, t.principal, t.instance, ttl)
+		case <-ended:
+			return
+		}
+	}
+}
+
+// writeEvents copies events to the client, adding heartbeats while the
+// upstream is silent, until the upstream ends or the stream reaches its idle
+// timeout or deadline
+func (t *sseTransport) writeEvents(w io.Writer, events <-chan string) {
+	var idle <-chan time.Time
+	var activity <-chan struct{}
+	var idleTimer *time.Timer
+	if t.stream != nil {
+		activity = t.stream.activity
+		if t.stream.idleTimeout > 0 {
+			idleTimer = time.NewTimer(t.stream.idleTimeout)
+			defer idleTimer.Stop()
+			idle = idleTimer.C
+		}
+	}
+	resetIdle := func() {
+		if idleTimer != nil {
+			if !idleTimer.Stop() {
+				<-idleTimer.C
 			}
+			idleTimer.Reset(t.stream.idleTimeout)
 		}
-		
-		if err := scanner.Err(); err != nil {
-			logger.Error("Error reading SSE stream: %v", err)
+	}
+
+	var expired <-chan time.Time
+	if t.stream != nil && !t.stream.deadline.IsZero() {
+		deadlineTimer := time.NewTimer(time.Until(t.stream.deadline))
+		defer deadlineTimer.Stop()
+		expired = deadlineTimer.C
+	}
+
+	// Heartbeats are written by this goroutine between events, so they never split one
+	var heartbeat <-chan time.Time
+	var heartbeatTimer *time.Timer
+	heartbeatInterval := time.Duration(t.config.SSE.HeartbeatIntervalSeconds) * time.Second
+	if heartbeatInterval > 0 {
+		heartbeatTimer = time.NewTimer(heartbeatInterval)
+		defer heartbeatTimer.Stop()
+		heartbeat = heartbeatTimer.C
+	}
+
+	for _, event := range t.missed {
+		if _, err := io.WriteString(w, event); err != nil {
+			sseLog.Debug("Error replaying SSE event: %v", err)
+			return
 		}
-	}()
-	
-	// Replace the response body with our modified pipe
-	resp.Body = pr
-	return resp, nil
+	}
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if _, err := io.WriteString(w, event); err != nil {
+				sseLog.Debug("Error writing SSE event: %v", err)
+				return
+			}
+			resetIdle()
+			if heartbeatTimer != nil {
+				if !heartbeatTimer.Stop() {
+					<-heartbeatTimer.C
+				}
+				heartbeatTimer.Reset(heartbeatInterval)
+			}
+		case <-heartbeat:
+			if _, err := io.WriteString(w, ": ping\n\n"); err != nil {
+				sseLog.Debug("Error writing SSE heartbeat: %v", err)
+				return
+			}
+			heartbeatTimer.Reset(heartbeatInterval)
+		case <-activity:
+			resetIdle()
+		case <-idle:
+			writeCloseEvent(w, closeIdleTimeout)
+			return
+		case <-expired:
+			writeCloseEvent(w, t.stream.closeReason)
+			return
+		}
+	}
+}
+
+// writeCloseEvent tells the client why the proxy is ending the stream
+func writeCloseEvent(w io.Writer, reason string) {
+	sseLog.Info("Closing SSE stream: %s", reason)
+	if _, err := fmt.Fprintf(w, "event: close\ndata: {\"reason\":%q}\n\n", reason); err != nil {
+		sseLog.Debug("Error writing close event: %v", err)
+	}
+}
+
+// sessionIDFromEndpoint returns the session ID in an endpoint event URL
+func sessionIDFromEndpoint(endpoint string) string {
+	parsed, err := url.Parse(endpoint)
+	if err != nil {
+		return ""
+	}
+	if id := parsed.Query().Get("sessionId"); id != "" {
+		return id
+	}
+	return parsed.Query().Get("session_id")
 }
 
 // rewriteEndpoint rewrites endpoint URLs to use generic proxy paths
 func (t *sseTransport) rewriteEndpoint(endpoint, proxyHost string) string {
 	// Add nil check for config
 	if t.config == nil {
-		logger.Error("Config is nil in sseTransport")
+		sseLog.Error("Config is nil in sseTransport")
 		return endpoint
 	}
-	
+
 	// If the endpoint is already a full URL, parse and modify it
 	if strings.HasPrefix(endpoint, "http://") || strings.HasPrefix(endpoint, "https://") {
 		// For full URLs, replace the host part
@@ -143,22 +329,22 @@ func (t *sseTransport) rewriteEndpoint(endpoint, proxyHost string) string {
 		}
 		return endpoint
 	}
-	
+
 	// For relative URLs from MCP server, rewrite to generic proxy paths
 	// Extract just the query parameters if any
 	var queryParams string
 	if idx := strings.Index(endpoint, "?"); idx != -1 {
 		queryParams = endpoint[idx:]
 	}
-	
+
 	// Map remote MCP server paths to configured proxy paths
 	// SSE "endpoint" events contain the message endpoint URL
 	proxyPath := t.config.Paths.Messages
-	
+
 	// Determine the protocol based on the proxy host
 	protocol := "http" // Default to HTTP for localhost
 	cleanProxyHost := proxyHost
-	
+
 	// Check if proxyHost already includes protocol
 	if strings.HasPrefix(proxyHost, "http://") {
 		protocol = "http"
@@ -174,12 +360,12 @@ func (t *sseTransport) rewriteEndpoint(endpoint, proxyHost string) string {
 			protocol = "https" // Use HTTPS for external hosts
 		}
 	}
-	
+
 	// Remove trailing slash from host to avoid double slashes
 	cleanProxyHost = strings.TrimSuffix(cleanProxyHost, "/")
-	
+
 	// Construct the full proxy URL with configured path
 	result := fmt.Sprintf("%s://%s%s%s", protocol, cleanProxyHost, proxyPath, queryParams)
-	logger.Debug("Endpoint rewrite: %s -> %s", endpoint, result)
+	sseLog.Debug("Endpoint rewrite: %s -> %s", endpoint, result)
 	return result
 }
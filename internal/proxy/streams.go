@@ -0,0 +1,174 @@
+package proxy
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/wso2/open-mcp-auth-proxy/internal/authz"
+	"github.com/wso2/open-mcp-auth-proxy/internal/config"
+)
+
+// Errors returned when a stream would exceed a limit
+var (
+	errTooManyStreams        = errors.New("too many SSE streams")
+	errTooManySubjectStreams = errors.New("too many SSE streams for this user")
+	errTooManyClientStreams  = errors.New("too many SSE streams for this client")
+)
+
+// Reasons sent in the close event before the proxy ends a stream
+const (
+	closeIdleTimeout  = "idle_timeout"
+	closeMaxLifetime  = "max_lifetime"
+	closeTokenExpired = "token_expired"
+)
+
+// sseStream is an open SSE stream of an authenticated principal
+type sseStream struct {
+	subject   string
+	clientID  string
+	sessionID string // Set once the endpoint event is seen
+
+	// activity receives a signal for every message the client posts on the session
+	activity chan struct{}
+
+	idleTimeout time.Duration // 0 disables the idle timeout
+	deadline    time.Time     // Zero when the stream has no lifetime limit
+	closeReason string        // Sent when the deadline is reached
+}
+
+// streamTracker counts open SSE streams and relates message posts to their stream
+type streamTracker struct {
+	cfg config.SSEConfig
+	now func() time.Time
+
+	mu         sync.Mutex
+	total      int
+	perSubject map[string]int
+	perClient  map[string]int
+	sessions   map[string]*sseStream
+}
+
+func newStreamTracker(cfg config.SSEConfig) *streamTracker {
+	return &streamTracker{
+		cfg:        cfg,
+		now:        time.Now,
+		perSubject: make(map[string]int),
+		perClient:  make(map[string]int),
+		sessions:   make(map[string]*sseStream),
+	}
+}
+
+// acquire registers a new stream for principal unless a limit is reached.
+// Callers release the stream when it ends.
+func (t *streamTracker) acquire(principal *authz.Principal) (*sseStream, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.cfg.MaxStreams > 0 && t.total >= t.cfg.MaxStreams {
+		return nil, errTooManyStreams
+	}
+	if t.cfg.MaxStreamsPerSubject > 0 && principal.Subject != "" && t.perSubject[principal.Subject] >= t.cfg.MaxStreamsPerSubject {
+		return nil, errTooManySubjectStreams
+	}
+	if t.cfg.MaxStreamsPerClient > 0 && principal.ClientID != "" && t.perClient[principal.ClientID] >= t.cfg.MaxStreamsPerClient {
+		return nil, errTooManyClientStreams
+	}
+
+	stream := &sseStream{
+		subject:     principal.Subject,
+		clientID:    principal.ClientID,
+		activity:    make(chan struct{}, 1),
+		idleTimeout: time.Duration(t.cfg.IdleTimeoutSeconds) * time.Second,
+	}
+	if t.cfg.MaxLifetimeSeconds > 0 {
+		stream.deadline = t.now().Add(time.Duration(t.cfg.MaxLifetimeSeconds) * time.Second)
+		stream.closeReason = closeMaxLifetime
+	}
+	if !t.cfg.KeepAfterTokenExpiry && !principal.ExpiresAt.IsZero() &&
+		(stream.deadline.IsZero() || principal.ExpiresAt.Before(stream.deadline)) {
+		stream.deadline = principal.ExpiresAt
+		stream.closeReason = closeTokenExpired
+	}
+
+	t.total++
+	t.perSubject[principal.Subject]++
+	t.perClient[principal.ClientID]++
+	return stream, nil
+}
+
+// release forgets a stream that ended
+func (t *streamTracker) release(stream *sseStream) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.total--
+	decrement(t.perSubject, stream.subject)
+	decrement(t.perClient, stream.clientID)
+	if stream.sessionID != "" && t.sessions[stream.sessionID] == stream {
+		delete(t.sessions, stream.sessionID)
+	}
+}
+
+// active returns the number of open streams
+func (t *streamTracker) active() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.total
+}
+
+func decrement(counts map[string]int, key string) {
+	if counts[key] <= 1 {
+		delete(counts, key)
+		return
+	}
+	counts[key]--
+}
+
+// bindSession relates the session announced in the stream's endpoint event to the stream
+func (t *streamTracker) bindSession(stream *sseStream, sessionID string) {
+	if sessionID == "" {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	stream.sessionID = sessionID
+	t.sessions[sessionID] = stream
+}
+
+// touch records client activity on a session, keeping its stream from idling out
+func (t *streamTracker) touch(sessionID string) {
+	if sessionID == "" {
+		return
+	}
+	t.mu.Lock()
+	stream := t.sessions[sessionID]
+	t.mu.Unlock()
+	if stream == nil {
+		return
+	}
+	select {
+	case stream.activity <- struct{}{}:
+	default: // A signal is already pending
+	}
+}
+
+// sessionIDFromQuery returns the MCP session ID of a message request
+func sessionIDFromQuery(r *http.Request) string {
+	query := r.URL.Query()
+	if id := query.Get("sessionId"); id != "" {
+		return id
+	}
+	return query.Get("session_id")
+}
+
+// writeStreamLimited rejects a stream that would exceed a limit
+func writeStreamLimited(w http.ResponseWriter, err error) {
+	status := http.StatusTooManyRequests
+	if errors.Is(err, errTooManyStreams) {
+		status = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Retry-After", "30")
+	http.Error(w, err.Error(), status)
+}
@@ -0,0 +1,184 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wso2/open-mcp-auth-proxy/internal/authz"
+	"github.com/wso2/open-mcp-auth-proxy/internal/config"
+)
+
+func TestStreamTrackerLimits(t *testing.T) {
+	tracker := newStreamTracker(config.SSEConfig{MaxStreams: 3, MaxStreamsPerSubject: 2, MaxStreamsPerClient: 2})
+	alice := &authz.Principal{Subject: "alice", ClientID: "app"}
+	bob := &authz.Principal{Subject: "bob", ClientID: "app"}
+	carol := &authz.Principal{Subject: "carol", ClientID: "cli"}
+
+	first, err := tracker.acquire(alice)
+	if err != nil {
+		t.Fatalf("Expected first stream to be accepted: %v", err)
+	}
+	if _, err := tracker.acquire(alice); err != nil {
+		t.Fatalf("Expected second stream to be accepted: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		principal *authz.Principal
+		expected  error
+	}{
+		{"Per-subject limit", alice, errTooManySubjectStreams},
+		{"Per-client limit", bob, errTooManyClientStreams},
+		{"Other client", carol, nil},
+		{"Global limit", &authz.Principal{Subject: "dave", ClientID: "other"}, errTooManyStreams},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := tracker.acquire(tc.principal); err != tc.expected {
+				t.Errorf("Expected %v, got %v", tc.expected, err)
+			}
+		})
+	}
+
+	tracker.release(first)
+	if _, err := tracker.acquire(bob); err != nil {
+		t.Errorf("Expected a released slot to be reusable: %v", err)
+	}
+}
+
+func TestStreamTrackerTokenExpiry(t *testing.T) {
+	expiresAt := time.Now().Add(time.Minute)
+	principal := &authz.Principal{Subject: "alice", ExpiresAt: expiresAt}
+
+	stream, _ := newStreamTracker(config.SSEConfig{MaxLifetimeSeconds: 3600}).acquire(principal)
+	if !stream.deadline.Equal(expiresAt) || stream.closeReason != closeTokenExpired {
+		t.Errorf("Expected deadline at token expiry, got %v (%s)", stream.deadline, stream.closeReason)
+	}
+
+	stream, _ = newStreamTracker(config.SSEConfig{KeepAfterTokenExpiry: true}).acquire(principal)
+	if !stream.deadline.IsZero() {
+		t.Errorf("Expected no deadline, got %v", stream.deadline)
+	}
+}
+
+// sseBackend returns a transport serving an SSE stream that sends an endpoint
+// event and then stays open until the test ends
+func sseBackend(t *testing.T) http.RoundTripper {
+	body, writer := io.Pipe()
+	t.Cleanup(func() { writer.Close() })
+	go io.WriteString(writer, "event: endpoint\ndata: /messages?sessionId=abc\n\n")
+
+	return roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"text/event-stream"}},
+			Body:       body,
+			Request:    req,
+		}, nil
+	})
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func TestSSEStreamClosedWhenIdle(t *testing.T) {
+	tracker := newStreamTracker(config.SSEConfig{})
+	stream, _ := tracker.acquire(&authz.Principal{Subject: "alice"})
+	stream.idleTimeout = 200 * time.Millisecond
+
+	transport := &sseTransport{
+		Transport:  sseBackend(t),
+		proxyHost:  "localhost:8080",
+		targetHost: "localhost:8000",
+		config:     newTestConfig("http://localhost:8000"),
+		stream:     stream,
+		tracker:    tracker,
+	}
+	resp, err := transport.RoundTrip(httptest.NewRequest("GET", "http://localhost:8000/sse", nil))
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+
+	// Messages on the session keep the stream open past the idle timeout
+	start := time.Now()
+	go func() {
+		for i := 0; i < 3; i++ {
+			time.Sleep(100 * time.Millisecond)
+			tracker.touch("abc")
+		}
+	}()
+
+	body, _ := io.ReadAll(resp.Body)
+	if elapsed := time.Since(start); elapsed < 300*time.Millisecond {
+		t.Errorf("Expected activity to delay the idle timeout, closed after %v", elapsed)
+	}
+	if !strings.Contains(string(body), "data: http://localhost:8080/messages?sessionId=abc\n") {
+		t.Errorf("Expected rewritten endpoint event, got %q", body)
+	}
+	if !strings.HasSuffix(string(body), "event: close\ndata: {\"reason\":\"idle_timeout\"}\n\n") {
+		t.Errorf("Expected idle close event, got %q", body)
+	}
+}
+
+func TestSSEStreamClosedAtDeadline(t *testing.T) {
+	stream, _ := newStreamTracker(config.SSEConfig{}).acquire(&authz.Principal{
+		Subject:   "alice",
+		ExpiresAt: time.Now().Add(100 * time.Millisecond),
+	})
+
+	transport := &sseTransport{
+		Transport: sseBackend(t),
+		proxyHost: "localhost:8080",
+		config:    newTestConfig("http://localhost:8000"),
+		stream:    stream,
+	}
+	resp, err := transport.RoundTrip(httptest.NewRequest("GET", "http://localhost:8000/sse", nil))
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if !strings.HasSuffix(string(body), "data: {\"reason\":\"token_expired\"}\n\n") {
+		t.Errorf("Expected token expiry close event, got %q", body)
+	}
+}
+
+func TestSSEHeartbeat(t *testing.T) {
+	cfg := newTestConfig("http://localhost:8000")
+	cfg.SSE.HeartbeatIntervalSeconds = 1
+
+	transport := &sseTransport{Transport: sseBackend(t), proxyHost: "localhost:8080", config: cfg}
+	resp, err := transport.RoundTrip(httptest.NewRequest("GET", "http://localhost:8000/sse", nil))
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	received := make(chan string, 1)
+	go func() {
+		var body strings.Builder
+		buf := make([]byte, 1024)
+		for !strings.Contains(body.String(), ": ping\n\n") {
+			n, err := resp.Body.Read(buf)
+			if err != nil {
+				break
+			}
+			body.Write(buf[:n])
+		}
+		received <- body.String()
+	}()
+
+	select {
+	case body := <-received:
+		// The heartbeat follows the complete endpoint event
+		if !strings.HasSuffix(body, "sessionId=abc\n\n: ping\n\n") {
+			t.Errorf("Expected a heartbeat after the endpoint event, got %q", body)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("Expected a heartbeat while the upstream is silent")
+	}
+}
@@ -0,0 +1,208 @@
+package proxy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wso2/open-mcp-auth-proxy/internal/authz"
+	"github.com/wso2/open-mcp-auth-proxy/internal/config"
+	"github.com/wso2/open-mcp-auth-proxy/internal/util"
+)
+
+const (
+	tokenExchangeGrantType = "urn:ietf:params:oauth:grant-type:token-exchange"
+	accessTokenType        = "urn:ietf:params:oauth:token-type:access_token"
+
+	// exchangeRefreshSkew is how long before expiry an exchanged token is replaced
+	exchangeRefreshSkew = 30 * time.Second
+)
+
+// exchangedToken is a cached token issued for the MCP server
+type exchangedToken struct {
+	token     string
+	expiresAt time.Time
+}
+
+// exchangeCall is an exchange in progress, shared by the requests waiting for it
+type exchangeCall struct {
+	done  chan struct{}
+	token string
+	err   error
+}
+
+// tokenExchanger swaps the user's token for one scoped to the MCP server
+// (RFC 8693) and caches the result per subject token and audience
+type tokenExchanger struct {
+	cfg      config.TokenExchangeConfig
+	endpoint string
+	now      func() time.Time
+
+	mu       sync.Mutex
+	cache    map[string]exchangedToken
+	inFlight map[string]*exchangeCall
+}
+
+// newTokenExchanger returns nil when token exchange is disabled
+func newTokenExchanger(cfg *config.Config) *tokenExchanger {
+	if !cfg.TokenExchange.Enabled {
+		return nil
+	}
+	endpoint := cfg.TokenExchange.TokenEndpoint
+	if endpoint == "" {
+		endpoint = strings.TrimSuffix(cfg.AuthServerBaseURL, "/") + "/token"
+	}
+	return &tokenExchanger{
+		cfg:      cfg.TokenExchange,
+		endpoint: endpoint,
+		now:      time.Now,
+		cache:    make(map[string]exchangedToken),
+		inFlight: make(map[string]*exchangeCall),
+	}
+}
+
+// cacheKey identifies the exchanged token of a principal. It is keyed by the
+// subject token itself, so a token obtained through one client, with its
+// scopes and binding, is never handed to requests made through another.
+func (e *tokenExchanger) cacheKey(principal *authz.Principal) string {
+	audience := e.cfg.Audience
+	if audience == "" {
+		audience = e.cfg.Resource
+	}
+	sum := sha256.Sum256([]byte(principal.Token))
+	return principal.Subject + "\x00" + audience + "\x00" + hex.EncodeToString(sum[:])
+}
+
+// Exchange returns a token for the MCP server, exchanging the principal's
+// token when there is no cached one. Concurrent requests with the same
+// subject token share one exchange.
+func (e *tokenExchanger) Exchange(ctx context.Context, principal *authz.Principal) (string, error) {
+	key := e.cacheKey(principal)
+	now := e.now()
+
+	e.mu.Lock()
+	cached, ok := e.cache[key]
+	if ok && now.Add(exchangeRefreshSkew).Before(cached.expiresAt) {
+		e.mu.Unlock()
+		return cached.token, nil
+	}
+	if call, ok := e.inFlight[key]; ok {
+		e.mu.Unlock()
+		select {
+		case <-call.done:
+			return call.token, call.err
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+	call := &exchangeCall{done: make(chan struct{})}
+	e.inFlight[key] = call
+	e.mu.Unlock()
+
+	call.token, call.err = e.exchange(ctx, principal, key, now)
+	e.mu.Lock()
+	delete(e.inFlight, key)
+	e.mu.Unlock()
+	close(call.done)
+	return call.token, call.err
+}
+
+// exchange requests a token for the MCP server and caches it under key
+func (e *tokenExchanger) exchange(ctx context.Context, principal *authz.Principal, key string, now time.Time) (string, error) {
+	token, lifetime, err := e.requestToken(ctx, principal.Token)
+	if err != nil {
+		return "", err
+	}
+
+	if principal.Subject == "" || lifetime <= 0 {
+		// Without a subject or expires_in there is no safe reuse
+		return token, nil
+	}
+
+	expiresAt := now.Add(lifetime)
+	if !principal.ExpiresAt.IsZero() && principal.ExpiresAt.Before(expiresAt) {
+		// Never outlive the token it was exchanged for
+		expiresAt = principal.ExpiresAt
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for k, entry := range e.cache {
+		if !now.Before(entry.expiresAt) {
+			delete(e.cache, k)
+		}
+	}
+	e.cache[key] = exchangedToken{token: token, expiresAt: expiresAt}
+	return token, nil
+}
+
+// requestToken performs the token exchange grant
+func (e *tokenExchanger) requestToken(ctx context.Context, subjectToken string) (string, time.Duration, error) {
+	form := url.Values{}
+	form.Set("grant_type", tokenExchangeGrantType)
+	form.Set("subject_token", subjectToken)
+	form.Set("subject_token_type", accessTokenType)
+	if e.cfg.Audience != "" {
+		form.Set("audience", e.cfg.Audience)
+	}
+	if e.cfg.Resource != "" {
+		form.Set("resource", e.cfg.Resource)
+	}
+	if e.cfg.Scope != "" {
+		form.Set("scope", e.cfg.Scope)
+	}
+	if e.cfg.RequestedTokenType != "" {
+		form.Set("requested_token_type", e.cfg.RequestedTokenType)
+	}
+	if e.cfg.AuthMethod == "client_secret_post" {
+		form.Set("client_id", e.cfg.ClientID)
+		form.Set("client_secret", e.cfg.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", 0, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if e.cfg.AuthMethod != "client_secret_post" && e.cfg.ClientID != "" {
+		req.SetBasicAuth(url.QueryEscape(e.cfg.ClientID), url.QueryEscape(e.cfg.ClientSecret))
+	}
+
+	resp, err := util.OutboundClient().Do(req)
+	if err != nil {
+		return "", 0, fmt.Errorf("token exchange request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", 0, fmt.Errorf("token exchange failed (%d): %s", resp.StatusCode, string(body))
+	}
+
+	var tokenResp struct {
+		AccessToken     string `json:"access_token"`
+		IssuedTokenType string `json:"issued_token_type"`
+		TokenType       string `json:"token_type"`
+		ExpiresIn       int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+		return "", 0, fmt.Errorf("failed to parse token exchange response: %w", err)
+	}
+	if tokenResp.AccessToken == "" {
+		return "", 0, fmt.Errorf("token exchange response has no access_token")
+	}
+	if tokenResp.TokenType != "" && !strings.EqualFold(tokenResp.TokenType, "Bearer") {
+		return "", 0, fmt.Errorf("unsupported exchanged token type %q", tokenResp.TokenType)
+	}
+
+	proxyLog.Debug("Exchanged token for %s (expires in %ds)", e.endpoint, tokenResp.ExpiresIn)
+	return tokenResp.AccessToken, time.Duration(tokenResp.ExpiresIn) * time.Second, nil
+}
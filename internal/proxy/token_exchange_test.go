@@ -0,0 +1,165 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/wso2/open-mcp-auth-proxy/internal/authz"
+	"github.com/wso2/open-mcp-auth-proxy/internal/config"
+)
+
+// startFakeSTS issues "exchanged-<n>" tokens for subject tokens it accepts
+func startFakeSTS(t *testing.T, calls *int32) *httptest.Server {
+	sts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		clientID, secret, _ := r.BasicAuth()
+		if clientID != "proxy" || secret != "proxy-secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.PostForm.Get("grant_type") != tokenExchangeGrantType ||
+			r.PostForm.Get("subject_token_type") != accessTokenType ||
+			r.PostForm.Get("audience") != "mcp-server" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_request"}`))
+			return
+		}
+		if !strings.HasPrefix(r.PostForm.Get("subject_token"), "good-token") {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+		n := atomic.AddInt32(calls, 1)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"exchanged-%d","issued_token_type":"%s","token_type":"Bearer","expires_in":300}`, n, accessTokenType)
+	}))
+	t.Cleanup(sts.Close)
+	return sts
+}
+
+func newTestExchangeConfig(endpoint string) *config.Config {
+	cfg := &config.Config{TokenExchange: config.TokenExchangeConfig{
+		Enabled:       true,
+		TokenEndpoint: endpoint,
+		ClientID:      "proxy",
+		ClientSecret:  "proxy-secret",
+		Audience:      "mcp-server",
+	}}
+	cfg.Validate()
+	return cfg
+}
+
+func TestTokenExchangeCache(t *testing.T) {
+	var calls int32
+	sts := startFakeSTS(t, &calls)
+	exchanger := newTokenExchanger(newTestExchangeConfig(sts.URL))
+
+	now := time.Now()
+	exchanger.now = func() time.Time { return now }
+
+	alice := &authz.Principal{Subject: "alice", Token: "good-token", ExpiresAt: now.Add(time.Hour)}
+	bob := &authz.Principal{Subject: "bob", Token: "good-token", ExpiresAt: now.Add(time.Minute)}
+	aliceViaOtherClient := &authz.Principal{Subject: "alice", ClientID: "other", Token: "good-token-other", ExpiresAt: now.Add(time.Hour)}
+
+	tests := []struct {
+		name      string
+		principal *authz.Principal
+		advance   time.Duration
+		expected  string
+	}{
+		{"First exchange", alice, 0, "exchanged-1"},
+		{"Cached for same subject", alice, time.Minute, "exchanged-1"},
+		{"Separate subject", bob, 0, "exchanged-2"},
+		{"Limited by subject token expiry", bob, 50 * time.Second, "exchanged-3"},
+		{"Refreshed before expiry", alice, 4 * time.Minute, "exchanged-4"},
+		{"Separate subject token of the same subject", aliceViaOtherClient, 0, "exchanged-5"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			now = now.Add(tc.advance)
+			token, err := exchanger.Exchange(context.Background(), tc.principal)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if token != tc.expected {
+				t.Errorf("Expected token %s, got %s", tc.expected, token)
+			}
+		})
+	}
+}
+
+func TestTokenExchangeSharedByConcurrentRequests(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	sts := startFakeSTS(t, &calls)
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		sts.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer slow.Close()
+	exchanger := newTokenExchanger(newTestExchangeConfig(slow.URL))
+
+	alice := &authz.Principal{Subject: "alice", Token: "good-token", ExpiresAt: time.Now().Add(time.Hour)}
+	var wg sync.WaitGroup
+	tokens := make([]string, 5)
+	for i := range tokens {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			tokens[i], _ = exchanger.Exchange(context.Background(), alice)
+		}(i)
+	}
+	time.Sleep(50 * time.Millisecond) // Let every request reach the exchanger
+	close(release)
+	wg.Wait()
+
+	if atomic.LoadInt32(&calls) != 1 {
+		t.Errorf("Expected one exchange, got %d", calls)
+	}
+	for _, token := range tokens {
+		if token != "exchanged-1" {
+			t.Errorf("Expected every request to get exchanged-1, got %v", tokens)
+			break
+		}
+	}
+}
+
+func TestTokenExchangeRejected(t *testing.T) {
+	var calls int32
+	sts := startFakeSTS(t, &calls)
+	exchanger := newTokenExchanger(newTestExchangeConfig(sts.URL))
+
+	_, err := exchanger.Exchange(context.Background(), &authz.Principal{Subject: "alice", Token: "revoked"})
+	if err == nil || !strings.Contains(err.Error(), "invalid_grant") {
+		t.Errorf("Expected invalid_grant error, got %v", err)
+	}
+}
+
+func TestTokenExchangeUpstreamToken(t *testing.T) {
+	var calls int32
+	sts := startFakeSTS(t, &calls)
+	backend, last := newTestBackend(t)
+
+	cfg := newTestConfig(backend.URL)
+	cfg.TokenExchange = newTestExchangeConfig(sts.URL).TokenExchange
+	router := NewRouter(cfg, newStubProvider(), withTestSessions("abc"))
+
+	req := httptest.NewRequest("POST", "/messages?sessionId=abc", strings.NewReader(`{}`))
+	req.Header.Set("Authorization", "Bearer good-token")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	if *last == nil {
+		t.Fatalf("Expected request to reach the backend")
+	}
+	if got := (*last).Header.Get("Authorization"); got != "Bearer exchanged-1" {
+		t.Errorf("Expected exchanged token upstream, got %q", got)
+	}
+}
@@ -0,0 +1,106 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/wso2/open-mcp-auth-proxy/internal/config"
+	logger "github.com/wso2/open-mcp-auth-proxy/internal/logging"
+	"github.com/wso2/open-mcp-auth-proxy/internal/tracing"
+)
+
+// withTracing records a server span for every request but health probes,
+// continuing the trace of the caller's traceparent header, and tags log lines
+// with the trace ID
+func withTracing(cfg *config.Config, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Probes would drown the traces that matter
+		if isHealthPath(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx, span := tracing.Start(tracing.Extract(r.Context(), r.Header), r.Method+" "+r.URL.Path, tracing.KindServer)
+		if span == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		defer span.End()
+		span.SetAttributes(
+			"http.request.method", r.Method,
+			"url.path", r.URL.Path,
+			"mcp_proxy.route_class", routeClass(r.URL.Path, cfg),
+			"client.address", r.RemoteAddr,
+		)
+		ctx = logger.WithFields(ctx, "trace_id", span.SpanContext().TraceID.String())
+
+		recorder := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+
+		span.SetAttributes("http.response.status_code", recorder.Status())
+		if recorder.Status() >= 500 {
+			span.SetError(fmt.Errorf("responded %d", recorder.Status()))
+		}
+	})
+}
+
+// rpcSpans time the JSON-RPC messages of a request until the MCP server
+// answers. On the SSE transport, the server answers with 202 Accepted and the
+// results follow on the stream.
+type rpcSpans []*tracing.Span
+
+// startRPCSpans starts a span for each JSON-RPC message posted in r, such as
+// "tools/call search". With a single message, the returned request carries
+// its span so the call to the MCP server is recorded as its child.
+func startRPCSpans(r *http.Request) (*http.Request, rpcSpans) {
+	if tracing.Current() == nil {
+		return r, nil
+	}
+	messages := peekRPC(r)
+	var spans rpcSpans
+	ctx := r.Context()
+	for _, m := range messages {
+		if m.Method == "" {
+			continue // Responses to server requests
+		}
+		name := m.Method
+		if m.Method == "tools/call" && m.Params.Name != "" {
+			name += " " + m.Params.Name
+		}
+		spanCtx, span := tracing.Start(r.Context(), name, tracing.KindInternal)
+		span.SetAttributes("mcp.method.name", m.Method, "rpc.system", "jsonrpc")
+		if m.Method == "tools/call" && m.Params.Name != "" {
+			span.SetAttributes("gen_ai.tool.name", m.Params.Name)
+		}
+		if len(m.ID) > 0 {
+			id := string(m.ID)
+			if unquoted, err := strconv.Unquote(id); err == nil {
+				id = unquoted
+			}
+			span.SetAttributes("jsonrpc.request.id", id)
+		}
+		if session := sessionIDFromRequest(r); session != "" {
+			span.SetAttributes("mcp.session.id", session)
+		}
+		spans = append(spans, span)
+		ctx = spanCtx
+	}
+	if len(spans) == 1 {
+		r = r.WithContext(ctx)
+	}
+	return r, spans
+}
+
+// fail marks the spans as failed
+func (s rpcSpans) fail(err error) {
+	for _, span := range s {
+		span.SetError(err)
+	}
+}
+
+// end finishes the spans
+func (s rpcSpans) end() {
+	for _, span := range s {
+		span.End()
+	}
+}
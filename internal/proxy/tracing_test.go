@@ -0,0 +1,116 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/wso2/open-mcp-auth-proxy/internal/config"
+	"github.com/wso2/open-mcp-auth-proxy/internal/tracing"
+)
+
+// spanRecorder collects exported spans
+type spanRecorder struct {
+	mu    sync.Mutex
+	spans []tracing.SpanData
+}
+
+func (r *spanRecorder) Export(spans []tracing.SpanData) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = append(r.spans, spans...)
+	return nil
+}
+
+func TestTraceContextPropagated(t *testing.T) {
+	backend, last := newTestBackend(t)
+	router := NewRouter(newTestConfig(backend.URL), newStubProvider(), withTestSessions("abc"))
+
+	recorder := &spanRecorder{}
+	tracer := tracing.New(config.TracingConfig{Enabled: true, BatchSize: 100, QueueSize: 100}, recorder)
+	tracing.SetTracer(tracer)
+	defer tracing.SetTracer(nil)
+
+	const incoming = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	req := httptest.NewRequest("POST", "/messages?sessionId=abc",
+		strings.NewReader(`{"jsonrpc":"2.0","id":7,"method":"tools/call","params":{"name":"search"}}`))
+	req.Header.Set("Authorization", "Bearer good-token")
+	req.Header.Set(tracing.TraceparentHeader, incoming)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	tracer.Shutdown()
+
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status Accepted, got %v", w.Code)
+	}
+
+	spans := make(map[string]tracing.SpanData)
+	for _, span := range recorder.spans {
+		spans[span.Name] = span
+	}
+	server, validation, call, upstream := spans["POST /messages"], spans["authz.validate_token"], spans["tools/call search"], spans["HTTP POST"]
+	for name, span := range map[string]tracing.SpanData{"server": server, "validation": validation, "tool call": call, "upstream": upstream} {
+		if span.SpanContext.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+			t.Fatalf("Expected a %s span in the caller's trace, got %+v", name, recorder.spans)
+		}
+	}
+	if server.Parent.String() != "00f067aa0ba902b7" || server.Kind != tracing.KindServer {
+		t.Errorf("Expected the server span to continue the caller's span, got parent %s", server.Parent)
+	}
+	if validation.Parent != server.SpanContext.SpanID || call.Parent != server.SpanContext.SpanID {
+		t.Errorf("Expected token validation and the tool call to be children of the server span")
+	}
+	if upstream.Parent != call.SpanContext.SpanID || upstream.Kind != tracing.KindClient {
+		t.Errorf("Expected the upstream call to be a client span under the tool call")
+	}
+
+	// The MCP server continues the trace from the upstream span
+	expected := "00-4bf92f3577b34da6a3ce929d0e0e4736-" + upstream.SpanContext.SpanID.String() + "-01"
+	if got := (*last).Header.Get(tracing.TraceparentHeader); got != expected {
+		t.Errorf("Expected traceparent %s upstream, got %s", expected, got)
+	}
+}
+
+func TestTraceparentPassedThroughWhenDisabled(t *testing.T) {
+	tracing.SetTracer(nil)
+	backend, last := newTestBackend(t)
+	router := NewRouter(newTestConfig(backend.URL), newStubProvider(), withTestSessions("abc"))
+
+	const incoming = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	req := httptest.NewRequest("POST", "/messages?sessionId=abc", strings.NewReader(`{}`))
+	req.Header.Set("Authorization", "Bearer good-token")
+	req.Header.Set(tracing.TraceparentHeader, incoming)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if got := (*last).Header.Get(tracing.TraceparentHeader); got != incoming {
+		t.Errorf("Expected the caller's traceparent upstream, got %q", got)
+	}
+}
+
+func TestRepeatedHeadersKept(t *testing.T) {
+	backend, last := newTestBackend(t)
+	router := NewRouter(newTestConfig(backend.URL), newStubProvider(), withTestSessions("abc"))
+
+	req := httptest.NewRequest("POST", "/messages?sessionId=abc", strings.NewReader(`{}`))
+	req.Header.Set("Authorization", "Bearer good-token")
+	req.Header.Add("Accept", "application/json")
+	req.Header.Add("Accept", "text/event-stream")
+	req.Header.Add(tracing.TracestateHeader, "a=1")
+	req.Header.Add(tracing.TracestateHeader, "b=2")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if *last == nil {
+		t.Fatalf("Expected the request to reach the backend")
+	}
+	if got := (*last).Header.Values("Accept"); !reflect.DeepEqual(got, []string{"application/json", "text/event-stream"}) {
+		t.Errorf("Expected both Accept values upstream, got %v", got)
+	}
+	if got := (*last).Header.Values(tracing.TracestateHeader); !reflect.DeepEqual(got, []string{"a=1", "b=2"}) {
+		t.Errorf("Expected both tracestate values upstream, got %v", got)
+	}
+}
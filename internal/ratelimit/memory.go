@@ -0,0 +1,90 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// bucket is the state of one token bucket
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	fullAt  time.Time // When the bucket is back to its burst and can be forgotten
+}
+
+// MemoryStore keeps token buckets in process memory
+type MemoryStore struct {
+	mu      sync.Mutex
+	maxKeys int
+	buckets map[string]*bucket
+}
+
+// NewMemoryStore creates a store holding at most maxKeys buckets
+func NewMemoryStore(maxKeys int) *MemoryStore {
+	return &MemoryStore{maxKeys: maxKeys, buckets: make(map[string]*bucket)}
+}
+
+// Take implements Store
+func (s *MemoryStore) Take(key string, limit Limit, now time.Time) (bool, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.buckets[key]
+	if !ok {
+		if len(s.buckets) >= s.maxKeys {
+			s.evict(now)
+		}
+		b = &bucket{tokens: float64(limit.Burst), updated: now}
+		s.buckets[key] = b
+	}
+
+	refill(b, limit, now)
+	if b.tokens >= 1 {
+		b.tokens--
+		b.fullAt = now.Add(time.Duration((float64(limit.Burst) - b.tokens) / limit.Rate * float64(time.Second)))
+		return true, 0, nil
+	}
+	wait := time.Duration((1 - b.tokens) / limit.Rate * float64(time.Second))
+	return false, wait, nil
+}
+
+// Refund implements Store
+func (s *MemoryStore) Refund(key string, limit Limit, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.buckets[key]
+	if !ok {
+		return nil // Forgotten buckets are full
+	}
+	refill(b, limit, now)
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+1)
+	b.fullAt = now.Add(time.Duration((float64(limit.Burst) - b.tokens) / limit.Rate * float64(time.Second)))
+	return nil
+}
+
+// refill adds the tokens earned since the bucket was last updated
+func refill(b *bucket, limit Limit, now time.Time) {
+	if elapsed := now.Sub(b.updated).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(float64(limit.Burst), b.tokens+elapsed*limit.Rate)
+		b.updated = now
+	}
+}
+
+// evict makes room for a new bucket. Full buckets are dropped first, since
+// recreating them changes nothing; when none are, an arbitrary bucket is.
+// Callers hold s.mu.
+func (s *MemoryStore) evict(now time.Time) {
+	for key, b := range s.buckets {
+		if !now.Before(b.fullAt) {
+			delete(s.buckets, key)
+		}
+	}
+	for key := range s.buckets {
+		if len(s.buckets) < s.maxKeys {
+			break
+		}
+		delete(s.buckets, key)
+	}
+}
@@ -0,0 +1,174 @@
+// Package ratelimit implements token bucket rate limits keyed by request attributes
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/wso2/open-mcp-auth-proxy/internal/config"
+	logger "github.com/wso2/open-mcp-auth-proxy/internal/logging"
+)
+
+// rateLimitLog logs rate limiting of proxied requests
+var rateLimitLog = logger.For(logger.Proxy)
+
+// Limit is a token bucket refilled at Rate tokens per second, holding at most Burst tokens
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Store keeps token buckets. Implementations backed by a shared store let
+// several proxy instances enforce the same limits.
+type Store interface {
+	// Take removes a token from the bucket of key. When the bucket is empty it
+	// returns false and the time until a token becomes available.
+	Take(key string, limit Limit, now time.Time) (bool, time.Duration, error)
+
+	// Refund returns a token taken from the bucket of key, for requests
+	// rejected by another bucket
+	Refund(key string, limit Limit, now time.Time) error
+}
+
+// Request holds the attributes a request is limited by. Empty attributes
+// exclude the request from rules keyed by them.
+type Request struct {
+	Subject  string
+	ClientID string
+	IP       string
+	Tools    []string // Tools called by the request, if any
+}
+
+// rule is a configured limit on requests sharing the values of keys
+type rule struct {
+	name  string
+	keys  []string
+	limit Limit
+}
+
+// Limiter applies the auth and MCP rules of a configuration
+type Limiter struct {
+	store Store
+	auth  []rule
+	mcp   []rule
+	now   func() time.Time
+}
+
+// New creates a limiter backed by store, or an in-memory store when store is
+// nil. It returns nil when rate limiting is disabled.
+func New(cfg config.RateLimitConfig, store Store) *Limiter {
+	if !cfg.Enabled {
+		return nil
+	}
+	if store == nil {
+		store = NewMemoryStore(cfg.MaxKeys)
+	}
+	return &Limiter{
+		store: store,
+		auth:  buildRules("auth", cfg.Auth),
+		mcp:   buildRules("mcp", cfg.MCP),
+		now:   time.Now,
+	}
+}
+
+func buildRules(section string, configured []config.RateLimitRule) []rule {
+	rules := make([]rule, 0, len(configured))
+	for i, rc := range configured {
+		var keys []string
+		for _, key := range strings.Split(rc.Key, ",") {
+			keys = append(keys, strings.TrimSpace(key))
+		}
+		rules = append(rules, rule{
+			name:  fmt.Sprintf("%s%d", section, i),
+			keys:  keys,
+			limit: Limit{Rate: rc.RequestsPerMinute / 60, Burst: rc.Burst},
+		})
+	}
+	return rules
+}
+
+// AllowAuth applies the auth endpoint rules. When the request is limited it
+// returns false and how long the caller should wait.
+func (l *Limiter) AllowAuth(req Request) (bool, time.Duration) {
+	return l.allow(l.auth, req)
+}
+
+// AllowMCP applies the MCP request rules
+func (l *Limiter) AllowMCP(req Request) (bool, time.Duration) {
+	return l.allow(l.mcp, req)
+}
+
+// taken is a token drawn from a bucket for a request still being checked
+type taken struct {
+	key   string
+	limit Limit
+}
+
+// allow draws a token from every bucket of the request. When one is empty,
+// the tokens drawn so far are refunded, so rejected requests cost nothing.
+func (l *Limiter) allow(rules []rule, req Request) (bool, time.Duration) {
+	now := l.now()
+	var drawn []taken
+
+	for _, r := range rules {
+		for _, key := range bucketKeys(r, req) {
+			ok, wait, err := l.store.Take(key, r.limit, now)
+			if err != nil {
+				// An unavailable store must not take the proxy down with it
+				rateLimitLog.Warn("Rate limit store error, allowing request: %v", err)
+				continue
+			}
+			if !ok {
+				for _, t := range drawn {
+					if err := l.store.Refund(t.key, t.limit, now); err != nil {
+						rateLimitLog.Warn("Rate limit store error, token not refunded: %v", err)
+					}
+				}
+				return false, wait
+			}
+			drawn = append(drawn, taken{key: key, limit: r.limit})
+		}
+	}
+	return true, 0
+}
+
+// bucketKeys returns the buckets a request draws from for a rule: none when
+// one of its attributes is missing, and one per tool for tool rules
+func bucketKeys(r rule, req Request) []string {
+	keys := []string{r.name}
+	for _, attribute := range r.keys {
+		var values []string
+		switch attribute {
+		case "subject":
+			values = []string{req.Subject}
+		case "client_id":
+			values = []string{req.ClientID}
+		case "ip":
+			values = []string{req.IP}
+		case "tool":
+			values = req.Tools
+		}
+
+		var next []string
+		for _, key := range keys {
+			for _, value := range values {
+				if value != "" {
+					next = append(next, key+"\x00"+attribute+"="+value)
+				}
+			}
+		}
+		keys = next
+	}
+	return keys
+}
+
+// RetryAfterSeconds rounds a wait up to whole seconds for the Retry-After header
+func RetryAfterSeconds(wait time.Duration) int {
+	seconds := int(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	return seconds
+}
@@ -0,0 +1,115 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/wso2/open-mcp-auth-proxy/internal/config"
+)
+
+func TestMemoryStoreTake(t *testing.T) {
+	store := NewMemoryStore(10)
+	limit := Limit{Rate: 1, Burst: 2}
+	now := time.Unix(1000, 0)
+
+	for i := 0; i < 2; i++ {
+		if ok, _, _ := store.Take("k", limit, now); !ok {
+			t.Fatalf("Expected request %d within the burst to be allowed", i+1)
+		}
+	}
+	ok, wait, _ := store.Take("k", limit, now)
+	if ok {
+		t.Fatalf("Expected request beyond the burst to be limited")
+	}
+	if wait != time.Second {
+		t.Errorf("Expected wait of 1s, got %v", wait)
+	}
+
+	if ok, _, _ := store.Take("k", limit, now.Add(time.Second)); !ok {
+		t.Errorf("Expected a refilled token after 1s")
+	}
+	if ok, _, _ := store.Take("other", limit, now); !ok {
+		t.Errorf("Expected separate keys to have separate buckets")
+	}
+}
+
+func TestMemoryStoreEviction(t *testing.T) {
+	store := NewMemoryStore(2)
+	limit := Limit{Rate: 1, Burst: 1}
+	now := time.Unix(1000, 0)
+
+	store.Take("a", limit, now)
+	store.Take("b", limit, now)
+	store.Take("c", limit, now.Add(2*time.Second)) // a and b are full again
+	if len(store.buckets) > 2 {
+		t.Errorf("Expected at most 2 buckets, got %d", len(store.buckets))
+	}
+	if _, ok := store.buckets["c"]; !ok {
+		t.Errorf("Expected the new bucket to be kept")
+	}
+}
+
+func TestLimiterKeys(t *testing.T) {
+	limiter := New(config.RateLimitConfig{
+		Enabled: true,
+		MaxKeys: 100,
+		Auth:    []config.RateLimitRule{{Key: "ip", RequestsPerMinute: 60, Burst: 1}},
+		MCP:     []config.RateLimitRule{{Key: "subject", RequestsPerMinute: 60, Burst: 1}},
+	}, nil)
+	now := time.Unix(1000, 0)
+	limiter.now = func() time.Time { return now }
+
+	tests := []struct {
+		name    string
+		mcp     bool
+		req     Request
+		allowed bool
+	}{
+		{"First auth request", false, Request{IP: "10.0.0.1"}, true},
+		{"Second auth request from same IP", false, Request{IP: "10.0.0.1"}, false},
+		{"Auth request from other IP", false, Request{IP: "10.0.0.2"}, true},
+		{"Auth limits don't apply to MCP", true, Request{Subject: "alice", IP: "10.0.0.1"}, true},
+		{"Second MCP request of subject", true, Request{Subject: "alice", IP: "10.0.0.3"}, false},
+		{"Request without subject is not limited by subject", true, Request{IP: "10.0.0.1"}, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			allow := limiter.AllowAuth
+			if tc.mcp {
+				allow = limiter.AllowMCP
+			}
+			if allowed, _ := allow(tc.req); allowed != tc.allowed {
+				t.Errorf("Expected allowed=%v, got %v", tc.allowed, allowed)
+			}
+		})
+	}
+
+	if New(config.RateLimitConfig{}, nil) != nil {
+		t.Errorf("Expected no limiter when rate limiting is disabled")
+	}
+}
+
+func TestRejectedRequestsRefundTokens(t *testing.T) {
+	limiter := New(config.RateLimitConfig{
+		Enabled: true,
+		MaxKeys: 100,
+		Auth: []config.RateLimitRule{
+			{Key: "client_id", RequestsPerMinute: 60, Burst: 2},
+			{Key: "ip", RequestsPerMinute: 60, Burst: 1},
+		},
+	}, nil)
+	now := time.Unix(1000, 0)
+	limiter.now = func() time.Time { return now }
+
+	if allowed, _ := limiter.AllowAuth(Request{ClientID: "client-1", IP: "10.0.0.1"}); !allowed {
+		t.Fatalf("Expected the first request to be allowed")
+	}
+	// Limited by IP; the client's token is given back
+	if allowed, _ := limiter.AllowAuth(Request{ClientID: "client-1", IP: "10.0.0.1"}); allowed {
+		t.Fatalf("Expected the second request from the IP to be limited")
+	}
+	if allowed, _ := limiter.AllowAuth(Request{ClientID: "client-1", IP: "10.0.0.2"}); !allowed {
+		t.Errorf("Expected the rejected request not to use up the client's bucket")
+	}
+}
@@ -0,0 +1,120 @@
+package session
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileStore keeps sessions in a JSON file that replicas on the same host, or
+// on a shared volume with working file locks, can share
+type FileStore struct {
+	path string
+	now  func() time.Time
+	mu   sync.Mutex // Serializes writers within the process; the file lock covers other processes
+}
+
+// NewFileStore creates a store backed by the file at path
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path, now: time.Now}
+}
+
+// Put implements Store
+func (s *FileStore) Put(session Session) error {
+	return s.update(func(sessions map[string]Session) {
+		sessions[session.ID] = session
+	})
+}
+
+// Get implements Store
+func (s *FileStore) Get(id string) (Session, bool, error) {
+	// Writers replace the file atomically, so reads need no lock
+	sessions, err := s.read()
+	if err != nil {
+		return Session{}, false, err
+	}
+	session, ok := sessions[id]
+	if !ok || session.Expired(s.now()) {
+		return Session{}, false, nil
+	}
+	return session, true, nil
+}
+
+// Delete implements Store
+func (s *FileStore) Delete(id string) error {
+	return s.update(func(sessions map[string]Session) {
+		delete(sessions, id)
+	})
+}
+
+// update applies change to the stored sessions under an exclusive lock,
+// dropping expired sessions on the way
+func (s *FileStore) update(change func(map[string]Session)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	unlock, err := lockFile(s.path + ".lock")
+	if err != nil {
+		return fmt.Errorf("failed to lock session file: %w", err)
+	}
+	defer unlock()
+
+	sessions, err := s.read()
+	if err != nil {
+		return err
+	}
+	now := s.now()
+	for id, session := range sessions {
+		if session.Expired(now) {
+			delete(sessions, id)
+		}
+	}
+	change(sessions)
+	return s.write(sessions)
+}
+
+func (s *FileStore) read() (map[string]Session, error) {
+	sessions := make(map[string]Session)
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return sessions, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session file: %w", err)
+	}
+	if len(data) == 0 {
+		return sessions, nil
+	}
+	if err := json.Unmarshal(data, &sessions); err != nil {
+		return nil, fmt.Errorf("invalid session file: %w", err)
+	}
+	return sessions, nil
+}
+
+// write replaces the file atomically so readers never see a partial file
+func (s *FileStore) write(sessions map[string]Session) error {
+	data, err := json.Marshal(sessions)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to write session file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write session file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write session file: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
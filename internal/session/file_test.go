@@ -0,0 +1,62 @@
+package session
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.json")
+	store := NewFileStore(path)
+	now := time.Unix(1000, 0)
+	store.now = func() time.Time { return now }
+
+	if err := store.Put(Session{ID: "s1", Subject: "alice", Owner: "http://proxy-1:8080"}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	store.Put(Session{ID: "s2", Subject: "bob", Expires: now.Add(time.Minute)})
+
+	// Another replica sees the same file
+	other := NewFileStore(path)
+	other.now = store.now
+	s, ok, err := other.Get("s1")
+	if err != nil || !ok {
+		t.Fatalf("Expected session from the other store, got ok=%v err=%v", ok, err)
+	}
+	if s.Subject != "alice" || s.Owner != "http://proxy-1:8080" {
+		t.Errorf("Expected alice owned by proxy-1, got %+v", s)
+	}
+
+	other.now = func() time.Time { return now.Add(2 * time.Minute) }
+	if _, ok, _ := other.Get("s2"); ok {
+		t.Errorf("Expected expired session to be gone")
+	}
+
+	other.Delete("s1")
+	if _, ok, _ := store.Get("s1"); ok {
+		t.Errorf("Expected deleted session to be gone")
+	}
+}
+
+func TestFileStoreConcurrentWriters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.json")
+	stores := []*FileStore{NewFileStore(path), NewFileStore(path)}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			stores[i%2].Put(Session{ID: string(rune('a' + i)), Subject: "alice"})
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < 20; i++ {
+		if _, ok, _ := stores[0].Get(string(rune('a' + i))); !ok {
+			t.Errorf("Expected session %c to survive concurrent writes", 'a'+i)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+//go:build windows
+
+package session
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+// lockFile takes an exclusive lock by creating path, waiting while another
+// process holds it. Locks older than a minute are assumed to be stale.
+func lockFile(path string) (func(), error) {
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+		if info, statErr := os.Stat(path); statErr == nil && time.Since(info.ModTime()) > time.Minute {
+			os.Remove(path)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, errors.New("timed out waiting for the session file lock")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
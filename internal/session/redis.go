@@ -0,0 +1,234 @@
+package session
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/wso2/open-mcp-auth-proxy/internal/config"
+)
+
+// RedisStore keeps sessions in a server speaking the Redis protocol (RESP),
+// leaving expiry to the server. Commands run concurrently over a pool of
+// connections; connections that fail are dropped and replaced.
+type RedisStore struct {
+	cfg     config.RedisConfig
+	timeout time.Duration
+	now     func() time.Time
+
+	slots chan struct{} // One per connection in use, bounding the pool
+
+	mu     sync.Mutex
+	idle   []*redisConn
+	closed bool
+}
+
+// redisConn is a pooled connection to the server
+type redisConn struct {
+	conn net.Conn
+	rd   *bufio.Reader
+}
+
+// NewRedisStore creates a store for the server in cfg. It connects lazily.
+func NewRedisStore(cfg config.RedisConfig) *RedisStore {
+	size := cfg.PoolSize
+	if size <= 0 {
+		size = 1
+	}
+	return &RedisStore{
+		cfg:     cfg,
+		timeout: time.Duration(cfg.TimeoutSeconds) * time.Second,
+		now:     time.Now,
+		slots:   make(chan struct{}, size),
+	}
+}
+
+// errRedisNil is the reply to a GET of a missing key
+var errRedisNil = errors.New("redis: nil")
+
+// Put implements Store
+func (s *RedisStore) Put(session Session) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	args := []string{"SET", s.cfg.KeyPrefix + session.ID, string(data)}
+	if !session.Expires.IsZero() {
+		ttl := session.Expires.Sub(s.now()).Milliseconds()
+		if ttl <= 0 {
+			return s.Delete(session.ID)
+		}
+		args = append(args, "PX", strconv.FormatInt(ttl, 10))
+	}
+	_, err = s.do(args...)
+	return err
+}
+
+// Get implements Store
+func (s *RedisStore) Get(id string) (Session, bool, error) {
+	reply, err := s.do("GET", s.cfg.KeyPrefix+id)
+	if errors.Is(err, errRedisNil) {
+		return Session{}, false, nil
+	}
+	if err != nil {
+		return Session{}, false, err
+	}
+	var session Session
+	if err := json.Unmarshal([]byte(reply), &session); err != nil {
+		return Session{}, false, fmt.Errorf("invalid session %s in redis: %w", id, err)
+	}
+	if session.Expired(s.now()) {
+		return Session{}, false, nil
+	}
+	return session, true, nil
+}
+
+// Delete implements Store
+func (s *RedisStore) Delete(id string) error {
+	_, err := s.do("DEL", s.cfg.KeyPrefix+id)
+	return err
+}
+
+// Close closes the idle connections; connections in use are closed when
+// their command completes
+func (s *RedisStore) Close() error {
+	s.mu.Lock()
+	idle := s.idle
+	s.idle = nil
+	s.closed = true
+	s.mu.Unlock()
+
+	var firstErr error
+	for _, c := range idle {
+		if err := c.conn.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// do sends a command and returns its simple, integer or bulk string reply
+func (s *RedisStore) do(args ...string) (string, error) {
+	s.slots <- struct{}{}
+	defer func() { <-s.slots }()
+
+	c, err := s.get()
+	if err != nil {
+		return "", err
+	}
+	reply, err := c.roundTrip(args, s.timeout)
+	var replyErr redisError
+	if err != nil && !errors.Is(err, errRedisNil) && !errors.As(err, &replyErr) {
+		// The connection may be out of step with the server, start over next time
+		c.conn.Close()
+		return reply, err
+	}
+	s.put(c)
+	return reply, err
+}
+
+// get returns an idle connection or opens a new one
+func (s *RedisStore) get() (*redisConn, error) {
+	s.mu.Lock()
+	if n := len(s.idle); n > 0 {
+		c := s.idle[n-1]
+		s.idle = s.idle[:n-1]
+		s.mu.Unlock()
+		return c, nil
+	}
+	s.mu.Unlock()
+	return s.connect()
+}
+
+// put returns a healthy connection to the pool
+func (s *RedisStore) put(c *redisConn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		c.conn.Close()
+		return
+	}
+	s.idle = append(s.idle, c)
+}
+
+func (s *RedisStore) connect() (*redisConn, error) {
+	conn, err := net.DialTimeout("tcp", s.cfg.Address, s.timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to redis at %s: %w", s.cfg.Address, err)
+	}
+	c := &redisConn{conn: conn, rd: bufio.NewReader(conn)}
+
+	if s.cfg.Password != "" {
+		if _, err := c.roundTrip([]string{"AUTH", s.cfg.Password}, s.timeout); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("redis authentication failed: %w", err)
+		}
+	}
+	if s.cfg.DB != 0 {
+		if _, err := c.roundTrip([]string{"SELECT", strconv.Itoa(s.cfg.DB)}, s.timeout); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to select redis database %d: %w", s.cfg.DB, err)
+		}
+	}
+	return c, nil
+}
+
+func (c *redisConn) roundTrip(args []string, timeout time.Duration) (string, error) {
+	if timeout > 0 {
+		c.conn.SetDeadline(time.Now().Add(timeout))
+	}
+
+	// Commands are sent as arrays of bulk strings
+	buf := []byte("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, arg := range args {
+		buf = append(buf, "$"+strconv.Itoa(len(arg))+"\r\n"+arg+"\r\n"...)
+	}
+	if _, err := c.conn.Write(buf); err != nil {
+		return "", err
+	}
+	return readReply(c.rd)
+}
+
+// redisError is an error reply from the server
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+// readReply reads one reply. Arrays aren't used by the store's commands.
+func readReply(rd *bufio.Reader) (string, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return "", fmt.Errorf("redis: malformed reply %q", line)
+	}
+	kind, value := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+', ':':
+		return value, nil
+	case '-':
+		return "", redisError(value)
+	case '$':
+		size, err := strconv.Atoi(value)
+		if err != nil {
+			return "", fmt.Errorf("redis: malformed bulk length %q", value)
+		}
+		if size < 0 {
+			return "", errRedisNil
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(rd, data); err != nil {
+			return "", err
+		}
+		return string(data[:size]), nil
+	}
+	return "", fmt.Errorf("redis: unexpected reply type %q", kind)
+}
@@ -0,0 +1,222 @@
+package session
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/wso2/open-mcp-auth-proxy/internal/config"
+)
+
+// fakeRedis serves the subset of the Redis protocol the store uses
+type fakeRedis struct {
+	password string
+
+	mu       sync.Mutex
+	data     map[string]string
+	ttls     map[string]string
+	commands []string
+	conns    int // Connections accepted
+}
+
+func startFakeRedis(t *testing.T, password string) (*fakeRedis, string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	f := &fakeRedis{password: password, data: make(map[string]string), ttls: make(map[string]string)}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			f.mu.Lock()
+			f.conns++
+			f.mu.Unlock()
+			go f.serve(conn)
+		}
+	}()
+	return f, ln.Addr().String()
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	rd := bufio.NewReader(conn)
+	authed := f.password == ""
+	for {
+		args, err := readCommand(rd)
+		if err != nil {
+			return
+		}
+		f.mu.Lock()
+		f.commands = append(f.commands, strings.ToUpper(args[0]))
+		var reply string
+		switch strings.ToUpper(args[0]) {
+		case "AUTH":
+			if args[1] == f.password {
+				authed = true
+				reply = "+OK\r\n"
+			} else {
+				reply = "-WRONGPASS invalid password\r\n"
+			}
+		case "SELECT":
+			reply = "+OK\r\n"
+		default:
+			if !authed {
+				reply = "-NOAUTH Authentication required\r\n"
+				break
+			}
+			reply = f.exec(args)
+		}
+		f.mu.Unlock()
+		if _, err := io.WriteString(conn, reply); err != nil {
+			return
+		}
+	}
+}
+
+func (f *fakeRedis) exec(args []string) string {
+	switch strings.ToUpper(args[0]) {
+	case "SET":
+		f.data[args[1]] = args[2]
+		if len(args) == 5 {
+			f.ttls[args[1]] = args[4]
+		}
+		return "+OK\r\n"
+	case "GET":
+		value, ok := f.data[args[1]]
+		if !ok {
+			return "$-1\r\n"
+		}
+		return "$" + strconv.Itoa(len(value)) + "\r\n" + value + "\r\n"
+	case "DEL":
+		_, ok := f.data[args[1]]
+		delete(f.data, args[1])
+		if ok {
+			return ":1\r\n"
+		}
+		return ":0\r\n"
+	}
+	return "-ERR unknown command\r\n"
+}
+
+func readCommand(rd *bufio.Reader) ([]string, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+	args := make([]string, n)
+	for i := range args {
+		line, err := rd.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(rd, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func TestRedisStore(t *testing.T) {
+	server, addr := startFakeRedis(t, "secret")
+	store := NewRedisStore(config.RedisConfig{
+		Address:        addr,
+		Password:       "secret",
+		DB:             2,
+		KeyPrefix:      "test:",
+		TimeoutSeconds: 5,
+	})
+	defer store.Close()
+	now := time.Unix(1000, 0)
+	store.now = func() time.Time { return now }
+
+	if err := store.Put(Session{ID: "s1", Subject: "alice", Owner: "http://proxy-1:8080", Expires: now.Add(time.Minute)}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	server.mu.Lock()
+	ttl := server.ttls["test:s1"]
+	commands := strings.Join(server.commands, " ")
+	server.mu.Unlock()
+	if ttl != "60000" {
+		t.Errorf("Expected a 60000ms expiry, got %q", ttl)
+	}
+	if commands != "AUTH SELECT SET" {
+		t.Errorf("Expected AUTH SELECT SET, got %s", commands)
+	}
+
+	s, ok, err := store.Get("s1")
+	if err != nil || !ok {
+		t.Fatalf("Expected session, got ok=%v err=%v", ok, err)
+	}
+	if s.Subject != "alice" || s.Owner != "http://proxy-1:8080" {
+		t.Errorf("Expected alice owned by proxy-1, got %+v", s)
+	}
+
+	if _, ok, err := store.Get("unknown"); ok || err != nil {
+		t.Errorf("Expected unknown session to be missing, got ok=%v err=%v", ok, err)
+	}
+
+	store.Delete("s1")
+	if _, ok, _ := store.Get("s1"); ok {
+		t.Errorf("Expected deleted session to be gone")
+	}
+}
+
+func TestRedisStoreErrors(t *testing.T) {
+	_, addr := startFakeRedis(t, "secret")
+
+	store := NewRedisStore(config.RedisConfig{Address: addr, Password: "wrong", KeyPrefix: "test:", TimeoutSeconds: 5})
+	if _, _, err := store.Get("s1"); err == nil {
+		t.Errorf("Expected an error with a wrong password")
+	}
+
+	down := NewRedisStore(config.RedisConfig{Address: "127.0.0.1:1", KeyPrefix: "test:", TimeoutSeconds: 1})
+	if err := down.Put(Session{ID: "s1"}); err == nil {
+		t.Errorf("Expected an error when the server is down")
+	}
+}
+
+func TestRedisStorePool(t *testing.T) {
+	server, addr := startFakeRedis(t, "")
+	store := NewRedisStore(config.RedisConfig{Address: addr, KeyPrefix: "test:", TimeoutSeconds: 5, PoolSize: 2})
+	defer store.Close()
+	store.Put(Session{ID: "s1", Subject: "alice"})
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, ok, err := store.Get("s1"); err != nil || !ok {
+				errs <- fmt.Errorf("ok=%v err=%v", ok, err)
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("Concurrent Get failed: %v", err)
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if server.conns < 1 || server.conns > 2 {
+		t.Errorf("Expected at most 2 pooled connections, got %d", server.conns)
+	}
+}
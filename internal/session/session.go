@@ -0,0 +1,99 @@
+// Package session records which principal owns each MCP session
+package session
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/wso2/open-mcp-auth-proxy/internal/config"
+)
+
+// Session is an MCP session opened through the proxy
+type Session struct {
+	ID       string    `json:"id"`
+	Subject  string    `json:"subject"`
+	ClientID string    `json:"client_id,omitempty"`
+	Owner    string    `json:"owner,omitempty"`   // URL of the replica holding the session's stream
+	Expires  time.Time `json:"expires,omitempty"` // Zero for sessions that never expire
+}
+
+// Expired reports whether the session has expired at now
+func (s Session) Expired(now time.Time) bool {
+	return !s.Expires.IsZero() && now.After(s.Expires)
+}
+
+// Store keeps sessions until they are deleted or expire
+type Store interface {
+	Put(s Session) error
+	// Get returns the session with id, or false when it's unknown or expired
+	Get(id string) (Session, bool, error)
+	Delete(id string) error
+}
+
+// Open returns the session store configured for the cluster, or an
+// in-memory store when the proxy runs as a single instance
+func Open(cfg config.ClusterConfig) (Store, error) {
+	if !cfg.Enabled {
+		return NewMemoryStore(), nil
+	}
+	switch cfg.SessionStore {
+	case "file":
+		return NewFileStore(cfg.SessionFile), nil
+	case "redis":
+		return NewRedisStore(cfg.Redis), nil
+	}
+	return nil, fmt.Errorf("unknown session store %q", cfg.SessionStore)
+}
+
+// MemoryStore keeps sessions in process memory
+type MemoryStore struct {
+	mu        sync.Mutex
+	sessions  map[string]Session
+	now       func() time.Time
+	lastSweep time.Time
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{sessions: make(map[string]Session), now: time.Now}
+}
+
+// Put implements Store
+func (s *MemoryStore) Put(session Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Sweep expired sessions as new ones come in, at most once a minute
+	now := s.now()
+	if now.Sub(s.lastSweep) > time.Minute {
+		s.lastSweep = now
+		for id, existing := range s.sessions {
+			if existing.Expired(now) {
+				delete(s.sessions, id)
+			}
+		}
+	}
+	s.sessions[session.ID] = session
+	return nil
+}
+
+// Get implements Store
+func (s *MemoryStore) Get(id string) (Session, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[id]
+	if !ok || session.Expired(s.now()) {
+		return Session{}, false, nil
+	}
+	return session, true, nil
+}
+
+// Delete implements Store
+func (s *MemoryStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, id)
+	return nil
+}
@@ -0,0 +1,40 @@
+package session
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore()
+	now := time.Unix(1000, 0)
+	store.now = func() time.Time { return now }
+
+	store.Put(Session{ID: "stream", Subject: "alice"})
+	store.Put(Session{ID: "expiring", Subject: "bob", Expires: now.Add(time.Minute)})
+
+	tests := []struct {
+		name   string
+		id     string
+		after  time.Duration
+		exists bool
+	}{
+		{"Session without expiry", "stream", time.Hour, true},
+		{"Session before expiry", "expiring", 30 * time.Second, true},
+		{"Session after expiry", "expiring", 2 * time.Minute, false},
+		{"Unknown session", "unknown", 0, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			store.now = func() time.Time { return now.Add(tc.after) }
+			if _, ok, _ := store.Get(tc.id); ok != tc.exists {
+				t.Errorf("Expected exists=%v, got %v", tc.exists, ok)
+			}
+		})
+	}
+
+	store.Delete("stream")
+	if _, ok, _ := store.Get("stream"); ok {
+		t.Errorf("Expected deleted session to be gone")
+	}
+}
@@ -0,0 +1,171 @@
+// Package sse reads and writes Server-Sent Events streams as specified by
+// the WHATWG HTML standard (section 9.2, "Server-sent events")
+package sse
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Event is one event of a stream. Comment-only events, such as keep-alive
+// pings, have no fields besides Comments.
+type Event struct {
+	ID       string
+	HasID    bool // An empty id field resets the client's last event ID
+	Type     string
+	Data     string // Lines of multi-line data are joined with "\n"
+	HasData  bool
+	Retry    int // Reconnection time in milliseconds, 0 when not set
+	Comments []string
+}
+
+// Reader parses events from a stream
+type Reader struct {
+	r       *bufio.Reader
+	started bool // Past the first line, which may start with a BOM
+	afterCR bool // The last line ended in CR
+}
+
+// NewReader returns a reader of the events in r
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r)}
+}
+
+// Next returns the next event. It returns io.EOF at the end of the stream;
+// an event cut off by the end of the stream is discarded, as clients would.
+func (r *Reader) Next() (*Event, error) {
+	event := &Event{}
+	var data []string
+	empty := true
+
+	for {
+		line, err := r.readLine()
+		if err != nil {
+			return nil, err
+		}
+
+		if line == "" {
+			if empty {
+				continue // Blank lines between events
+			}
+			if event.HasData {
+				event.Data = strings.Join(data, "\n")
+			}
+			return event, nil
+		}
+		empty = false
+
+		if strings.HasPrefix(line, ":") {
+			event.Comments = append(event.Comments, strings.TrimPrefix(strings.TrimPrefix(line, ":"), " "))
+			continue
+		}
+
+		field, value, found := strings.Cut(line, ":")
+		if found {
+			value = strings.TrimPrefix(value, " ")
+		}
+		switch field {
+		case "event":
+			event.Type = value
+		case "data":
+			data = append(data, value)
+			event.HasData = true
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				event.ID = value
+				event.HasID = true
+			}
+		case "retry":
+			if isDigits(value) {
+				event.Retry, _ = strconv.Atoi(value)
+			}
+		}
+		// Other fields are ignored
+	}
+}
+
+// readLine reads a line ending in CRLF, LF or CR, without a length limit
+func (r *Reader) readLine() (string, error) {
+	var line strings.Builder
+	for {
+		b, err := r.r.ReadByte()
+		if err != nil {
+			return "", err
+		}
+
+		// The LF of a CRLF pair ending the previous line
+		skipLF := r.afterCR
+		r.afterCR = false
+		if skipLF && b == '\n' {
+			continue
+		}
+
+		switch b {
+		case '\n':
+			return r.stripBOM(line.String()), nil
+		case '\r':
+			// Return right away instead of waiting for a possible LF
+			r.afterCR = true
+			return r.stripBOM(line.String()), nil
+		default:
+			line.WriteByte(b)
+		}
+	}
+}
+
+// stripBOM removes the byte order mark the stream may start with
+func (r *Reader) stripBOM(line string) string {
+	if r.started {
+		return line
+	}
+	r.started = true
+	return strings.TrimPrefix(line, "\uFEFF")
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// Write serializes an event, ending it with a blank line
+func Write(w io.Writer, e *Event) error {
+	_, err := io.WriteString(w, Format(e))
+	return err
+}
+
+// Format returns the wire form of an event, ending with a blank line
+func Format(e *Event) string {
+	var b strings.Builder
+	for _, comment := range e.Comments {
+		b.WriteString(": " + stripNewlines(comment) + "\n")
+	}
+	if e.HasID {
+		b.WriteString("id: " + stripNewlines(e.ID) + "\n")
+	}
+	if e.Type != "" {
+		b.WriteString("event: " + stripNewlines(e.Type) + "\n")
+	}
+	if e.Retry > 0 {
+		b.WriteString("retry: " + strconv.Itoa(e.Retry) + "\n")
+	}
+	if e.HasData {
+		data := strings.ReplaceAll(e.Data, "\r\n", "\n")
+		data = strings.ReplaceAll(data, "\r", "\n")
+		for _, line := range strings.Split(data, "\n") {
+			b.WriteString("data: " + line + "\n")
+		}
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// stripNewlines keeps single-line fields from breaking the stream
+func stripNewlines(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
@@ -0,0 +1,118 @@
+package sse
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReaderNext(t *testing.T) {
+	longData := strings.Repeat("x", 2<<20) // Longer than bufio.Scanner's limit
+
+	tests := []struct {
+		name     string
+		stream   string
+		expected []Event
+	}{
+		{
+			name:     "LF line endings",
+			stream:   "event: endpoint\ndata: /messages?sessionId=abc\n\n",
+			expected: []Event{{Type: "endpoint", Data: "/messages?sessionId=abc", HasData: true}},
+		},
+		{
+			name:     "CRLF and CR line endings",
+			stream:   "event: a\r\ndata: 1\r\n\r\nevent: b\rdata: 2\r\r",
+			expected: []Event{{Type: "a", Data: "1", HasData: true}, {Type: "b", Data: "2", HasData: true}},
+		},
+		{
+			name:     "No space after colon",
+			stream:   "event:endpoint\ndata:/messages\n\n",
+			expected: []Event{{Type: "endpoint", Data: "/messages", HasData: true}},
+		},
+		{
+			name:     "Only one leading space is removed",
+			stream:   "data:  indented\n\n",
+			expected: []Event{{Data: " indented", HasData: true}},
+		},
+		{
+			name:     "Multi-line data",
+			stream:   "data: {\ndata:   \"a\": 1\ndata: }\n\n",
+			expected: []Event{{Data: "{\n  \"a\": 1\n}", HasData: true}},
+		},
+		{
+			name:     "ID, retry and comments",
+			stream:   ": ping\nid: 42\nretry: 3000\ndata: x\n\n",
+			expected: []Event{{ID: "42", HasID: true, Retry: 3000, Data: "x", HasData: true, Comments: []string{"ping"}}},
+		},
+		{
+			name:     "Invalid retry and IDs with NUL are ignored",
+			stream:   "retry: 3s\nid: a\x00b\ndata: x\n\n",
+			expected: []Event{{Data: "x", HasData: true}},
+		},
+		{
+			name:     "Byte order mark and extra blank lines",
+			stream:   "\uFEFFdata: x\n\n\n\ndata: y\n\n",
+			expected: []Event{{Data: "x", HasData: true}, {Data: "y", HasData: true}},
+		},
+		{
+			name:     "Unterminated event is discarded",
+			stream:   "data: x\n\ndata: partial\n",
+			expected: []Event{{Data: "x", HasData: true}},
+		},
+		{
+			name:     "Long line",
+			stream:   "data: " + longData + "\n\n",
+			expected: []Event{{Data: longData, HasData: true}},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			reader := NewReader(strings.NewReader(tc.stream))
+			var events []Event
+			for {
+				event, err := reader.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("Next failed: %v", err)
+				}
+				events = append(events, *event)
+			}
+			if !reflect.DeepEqual(events, tc.expected) {
+				t.Errorf("Expected %+v, got %+v", tc.expected, events)
+			}
+		})
+	}
+}
+
+func TestFormat(t *testing.T) {
+	event := &Event{
+		ID:       "s-1",
+		HasID:    true,
+		Type:     "message",
+		Data:     "line one\nline two",
+		HasData:  true,
+		Comments: []string{"note"},
+	}
+	expected := ": note\nid: s-1\nevent: message\ndata: line one\ndata: line two\n\n"
+	if got := Format(event); got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+
+	// Formatted events parse back to the same event
+	parsed, err := NewReader(strings.NewReader(expected)).Next()
+	if err != nil {
+		t.Fatalf("Next failed: %v", err)
+	}
+	if !reflect.DeepEqual(parsed, event) {
+		t.Errorf("Expected %+v, got %+v", event, parsed)
+	}
+
+	// Newlines can't inject fields through single-line values
+	if got := Format(&Event{Type: "a\nid: x"}); got != "event: aid: x\n\n" {
+		t.Errorf("Expected newlines to be stripped, got %q", got)
+	}
+}
@@ -14,12 +14,16 @@ import (
 	logger "github.com/wso2/open-mcp-auth-proxy/internal/logging"
 )
 
+// subprocessLog logs the lifecycle of MCP server processes
+var subprocessLog = logger.For(logger.Subprocess)
+
 // Manager handles starting and graceful shutdown of subprocesses
 type Manager struct {
 	process       *os.Process
 	processGroup  int
 	mutex         sync.Mutex
 	cmd           *exec.Cmd
+	startedAt     time.Time
 	shutdownDelay time.Duration
 }
 
@@ -40,7 +44,7 @@ func EnsureDependenciesAvailable(command string) error {
 		}
 
 		// Try to install npx using npm
-		logger.Info("npx not found, attempting to install...")
+		subprocessLog.Info("npx not found, attempting to install...")
 		var cmd *exec.Cmd
 		if runtime.GOOS == "windows" {
 			cmd = exec.Command("npm.cmd", "install", "-g", "npx")
@@ -54,7 +58,7 @@ func EnsureDependenciesAvailable(command string) error {
 			return fmt.Errorf("failed to install npx: %w", err)
 		}
 
-		logger.Info("npx installed successfully")
+		subprocessLog.Info("npx installed successfully")
 	}
 
 	// Check if uv is needed based on the command
@@ -92,7 +96,7 @@ func (m *Manager) Start(cfg *config.Config) error {
 		return nil // No command to execute
 	}
 
-	logger.Info("Starting subprocess with command: %s", execCommand)
+	subprocessLog.Info("Starting subprocess with command: %s", execCommand)
 
 	var cmd *exec.Cmd
 	if runtime.GOOS == "windows" {
@@ -126,26 +130,27 @@ func (m *Manager) Start(cfg *config.Config) error {
 
 	m.process = cmd.Process
 	m.cmd = cmd
-	logger.Info("Subprocess started with PID: %d", m.process.Pid)
+	m.startedAt = time.Now()
+	subprocessLog.Info("Subprocess started with PID: %d", m.process.Pid)
 
 	// Get and store the process group ID (Unix) or PID (Windows)
 	pgid, err := getProcessGroup(m.process.Pid)
 	if err == nil {
 		m.processGroup = pgid
 		if runtime.GOOS != "windows" {
-			logger.Debug("Process group ID: %d", m.processGroup)
+			subprocessLog.Debug("Process group ID: %d", m.processGroup)
 		}
 	} else {
-		logger.Warn("Failed to get process group ID: %v", err)
+		subprocessLog.Warn("Failed to get process group ID: %v", err)
 		m.processGroup = m.process.Pid
 	}
 
 	// Handle process termination in background
 	go func() {
 		if err := cmd.Wait(); err != nil {
-			logger.Error("Subprocess exited with error: %v", err)
+			subprocessLog.Error("Subprocess exited with error: %v", err)
 		} else {
-			logger.Info("Subprocess exited successfully")
+			subprocessLog.Info("Subprocess exited successfully")
 		}
 
 		// Clear the process reference when it exits
@@ -165,6 +170,26 @@ func (m *Manager) IsRunning() bool {
 	return m.process != nil
 }
 
+// PID returns the process ID of the subprocess, 0 when it isn't running
+func (m *Manager) PID() int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.process == nil {
+		return 0
+	}
+	return m.process.Pid
+}
+
+// Uptime returns how long the subprocess has been running, 0 when it isn't
+func (m *Manager) Uptime() time.Duration {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.process == nil {
+		return 0
+	}
+	return time.Since(m.startedAt)
+}
+
 // Shutdown gracefully terminates the subprocess
 func (m *Manager) Shutdown() {
 	m.mutex.Lock()
@@ -176,7 +201,7 @@ func (m *Manager) Shutdown() {
 		return // No process to terminate
 	}
 
-	logger.Info("Terminating subprocess...")
+	subprocessLog.Info("Terminating subprocess...")
 	terminateComplete := make(chan struct{})
 
 	go func() {
@@ -191,7 +216,7 @@ func (m *Manager) Shutdown() {
 			if m.process != nil {
 				err := m.process.Kill()
 				if err != nil {
-					logger.Warn("Failed to terminate process: %v", err)
+					subprocessLog.Warn("Failed to terminate process: %v", err)
 				}
 			}
 			m.mutex.Unlock()
@@ -213,14 +238,14 @@ func (m *Manager) Shutdown() {
 			if processGroupToTerminate != 0 {
 				err := killProcessGroup(processGroupToTerminate, syscall.SIGTERM)
 				if err != nil {
-					logger.Warn("Failed to send SIGTERM to process group: %v", err)
+					subprocessLog.Warn("Failed to send SIGTERM to process group: %v", err)
 
 					// Fallback to terminating just the process
 					m.mutex.Lock()
 					if m.process != nil {
 						err = m.process.Signal(syscall.SIGTERM)
 						if err != nil {
-							logger.Warn("Failed to send SIGTERM to process: %v", err)
+							subprocessLog.Warn("Failed to send SIGTERM to process: %v", err)
 						}
 					}
 					m.mutex.Unlock()
@@ -231,7 +256,7 @@ func (m *Manager) Shutdown() {
 				if m.process != nil {
 					err := m.process.Signal(syscall.SIGTERM)
 					if err != nil {
-						logger.Warn("Failed to send SIGTERM to process: %v", err)
+						subprocessLog.Warn("Failed to send SIGTERM to process: %v", err)
 					}
 				}
 				m.mutex.Unlock()
@@ -252,19 +277,19 @@ func (m *Manager) Shutdown() {
 		}
 
 		if terminatedGracefully {
-			logger.Info("Subprocess terminated gracefully")
+			subprocessLog.Info("Subprocess terminated gracefully")
 			return
 		}
 
 		// If the process didn't exit gracefully, force kill
-		logger.Warn("Subprocess didn't exit gracefully, forcing termination...")
+		subprocessLog.Warn("Subprocess didn't exit gracefully, forcing termination...")
 
 		if runtime.GOOS == "windows" {
 			// On Windows, Kill() is already forceful
 			m.mutex.Lock()
 			if m.process != nil {
 				if err := m.process.Kill(); err != nil {
-					logger.Error("Failed to kill process: %v", err)
+					subprocessLog.Error("Failed to kill process: %v", err)
 				}
 			}
 			m.mutex.Unlock()
@@ -273,13 +298,13 @@ func (m *Manager) Shutdown() {
 			// Try to kill the process group first
 			if processGroupToTerminate != 0 {
 				if err := killProcessGroup(processGroupToTerminate, syscall.SIGKILL); err != nil {
-					logger.Warn("Failed to send SIGKILL to process group: %v", err)
+					subprocessLog.Warn("Failed to send SIGKILL to process group: %v", err)
 
 					// Fallback to killing just the process
 					m.mutex.Lock()
 					if m.process != nil {
 						if err := m.process.Kill(); err != nil {
-							logger.Error("Failed to kill process: %v", err)
+							subprocessLog.Error("Failed to kill process: %v", err)
 						}
 					}
 					m.mutex.Unlock()
@@ -289,7 +314,7 @@ func (m *Manager) Shutdown() {
 				m.mutex.Lock()
 				if m.process != nil {
 					if err := m.process.Kill(); err != nil {
-						logger.Error("Failed to kill process: %v", err)
+						subprocessLog.Error("Failed to kill process: %v", err)
 					}
 				}
 				m.mutex.Unlock()
@@ -301,9 +326,9 @@ func (m *Manager) Shutdown() {
 
 		m.mutex.Lock()
 		if m.process == nil {
-			logger.Info("Subprocess terminated by force")
+			subprocessLog.Info("Subprocess terminated by force")
 		} else {
-			logger.Warn("Failed to terminate subprocess")
+			subprocessLog.Warn("Failed to terminate subprocess")
 		}
 		m.mutex.Unlock()
 	}()
@@ -313,6 +338,6 @@ func (m *Manager) Shutdown() {
 	case <-terminateComplete:
 		// Termination completed
 	case <-time.After(m.shutdownDelay):
-		logger.Warn("Subprocess termination timed out")
+		subprocessLog.Warn("Subprocess termination timed out")
 	}
 }
@@ -0,0 +1,323 @@
+package subprocess
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wso2/open-mcp-auth-proxy/internal/config"
+	"github.com/wso2/open-mcp-auth-proxy/internal/metrics"
+)
+
+// Reasons for restarting a user server, as counted in the restart metric
+const (
+	RestartExited             = "exited"
+	RestartCredentialsChanged = "credentials_changed"
+)
+
+// ErrPoolFull is returned when every per-user server slot is busy
+var ErrPoolFull = errors.New("too many user servers running")
+
+// serverProcess is a running user server
+type serverProcess interface {
+	IsRunning() bool
+	Shutdown()
+}
+
+// userServer is the server process of one user
+type userServer struct {
+	process  serverProcess
+	port     int
+	envHash  string
+	lastUsed time.Time
+	active   int // Open requests and streams; the server isn't reaped or evicted while it has any
+	ready    chan struct{}
+	err      error
+}
+
+// Pool runs one stdio server process per user, each behind its own
+// supergateway port and with the user's credentials in its environment
+type Pool struct {
+	cfg          *config.Config
+	basePort     int
+	maxServers   int
+	idleTimeout  time.Duration
+	startTimeout time.Duration
+	now          func() time.Time
+	startServer  func(cfg *config.Config) (serverProcess, error)
+
+	mu      sync.Mutex
+	servers map[string]*userServer
+	stop    chan struct{}
+}
+
+// NewPool creates a pool for the stdio command in cfg, configured by cfg.Vault
+func NewPool(cfg *config.Config) *Pool {
+	p := &Pool{
+		cfg:          cfg,
+		basePort:     cfg.Vault.BasePort,
+		maxServers:   cfg.Vault.MaxProcesses,
+		idleTimeout:  time.Duration(cfg.Vault.IdleTimeoutSeconds) * time.Second,
+		startTimeout: 60 * time.Second, // npx may download the server on first start
+		now:          time.Now,
+		startServer:  startManager,
+		servers:      make(map[string]*userServer),
+		stop:         make(chan struct{}),
+	}
+	go p.reapIdle()
+	return p
+}
+
+// startManager starts a server with a regular subprocess manager
+func startManager(cfg *config.Config) (serverProcess, error) {
+	m := NewManager()
+	if err := m.Start(cfg); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Get returns the base URL of the subject's server, starting it with env if
+// needed. A running server is restarted when env changed.
+func (p *Pool) Get(subject string, env []string) (string, error) {
+	serverURL, _, err := p.get(subject, env, false)
+	return serverURL, err
+}
+
+// Acquire is Get for a request or stream to the server: the server isn't
+// reaped or evicted until release is called when it ends
+func (p *Pool) Acquire(subject string, env []string) (serverURL string, release func(), err error) {
+	return p.get(subject, env, true)
+}
+
+func (p *Pool) get(subject string, env []string, hold bool) (string, func(), error) {
+	hash := envHash(env)
+
+	p.mu.Lock()
+	server, ok := p.servers[subject]
+	if ok && (server.envHash != hash || (isClosed(server.ready) && (server.err != nil || !server.process.IsRunning()))) {
+		// Credentials changed or the server died
+		reason := RestartExited
+		if server.envHash != hash {
+			reason = RestartCredentialsChanged
+		}
+		metrics.SubprocessRestarts.Inc(reason)
+		delete(p.servers, subject)
+		go stopServer(subject, server)
+		ok = false
+	}
+	if !ok {
+		var err error
+		server, err = p.launch(subject, env, hash)
+		if err != nil {
+			p.mu.Unlock()
+			return "", nil, err
+		}
+	}
+	server.lastUsed = p.now()
+	release := func() {}
+	if hold {
+		server.active++
+		var once sync.Once
+		release = func() { once.Do(func() { p.release(server) }) }
+	}
+	p.mu.Unlock()
+
+	<-server.ready
+	if server.err != nil {
+		release()
+		return "", nil, server.err
+	}
+	return fmt.Sprintf("http://localhost:%d", server.port), release, nil
+}
+
+// release ends a request or stream to server, which counts as its last use
+func (p *Pool) release(server *userServer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	server.active--
+	server.lastUsed = p.now()
+}
+
+// launch starts a server for subject. Callers hold p.mu.
+func (p *Pool) launch(subject string, env []string, hash string) (*userServer, error) {
+	if len(p.servers) >= p.maxServers {
+		p.evictLeastRecentlyUsed()
+	}
+	if len(p.servers) >= p.maxServers {
+		return nil, ErrPoolFull
+	}
+
+	port, err := p.allocatePort()
+	if err != nil {
+		return nil, err
+	}
+
+	// Same command, but on the user's port and with the user's environment
+	serverCfg := *p.cfg
+	serverCfg.Port = port
+	serverCfg.BaseURL = fmt.Sprintf("http://localhost:%d", port)
+	serverCfg.Stdio.Env = append(append([]string{}, p.cfg.Stdio.Env...), env...)
+
+	server := &userServer{port: port, envHash: hash, ready: make(chan struct{})}
+	p.servers[subject] = server
+
+	subprocessLog.Info("Starting server for %s on port %d", subject, port)
+	go func() {
+		defer close(server.ready)
+		process, err := p.startServer(&serverCfg)
+		if err != nil {
+			server.err = fmt.Errorf("failed to start user server: %w", err)
+			return
+		}
+		server.process = process
+		if err := waitForPort(port, p.startTimeout); err != nil {
+			process.Shutdown()
+			server.err = err
+		}
+	}()
+	return server, nil
+}
+
+// allocatePort returns the lowest free port from basePort. Callers hold p.mu.
+func (p *Pool) allocatePort() (int, error) {
+	used := make(map[int]bool, len(p.servers))
+	for _, server := range p.servers {
+		used[server.port] = true
+	}
+	for port := p.basePort; port < p.basePort+p.maxServers*2; port++ {
+		if used[port] {
+			continue
+		}
+		ln, err := net.Listen("tcp", fmt.Sprintf("localhost:%d", port))
+		if err != nil {
+			continue // Taken by another program
+		}
+		ln.Close()
+		return port, nil
+	}
+	return 0, fmt.Errorf("no free port from %d for user servers", p.basePort)
+}
+
+// evictLeastRecentlyUsed stops the idle server that was used
+// longest ago. Callers hold p.mu.
+func (p *Pool) evictLeastRecentlyUsed() {
+	var oldest string
+	for subject, server := range p.servers {
+		if !isClosed(server.ready) || server.active > 0 {
+			continue // Still starting or in use
+		}
+		if oldest == "" || server.lastUsed.Before(p.servers[oldest].lastUsed) {
+			oldest = subject
+		}
+	}
+	if oldest != "" {
+		server := p.servers[oldest]
+		delete(p.servers, oldest)
+		go stopServer(oldest, server)
+	}
+}
+
+// reapIdle periodically stops servers without open requests or streams that
+// weren't used within the idle timeout
+func (p *Pool) reapIdle() {
+	interval := p.idleTimeout / 4
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+		}
+		p.stopIdle()
+	}
+}
+
+// stopIdle stops the servers that are idle for longer than the idle timeout
+func (p *Pool) stopIdle() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.now()
+	for subject, server := range p.servers {
+		if isClosed(server.ready) && server.active == 0 && now.Sub(server.lastUsed) > p.idleTimeout {
+			delete(p.servers, subject)
+			go stopServer(subject, server)
+		}
+	}
+}
+
+// Shutdown stops every user server
+func (p *Pool) Shutdown() {
+	p.mu.Lock()
+	select {
+	case <-p.stop:
+	default:
+		close(p.stop)
+	}
+	servers := p.servers
+	p.servers = make(map[string]*userServer)
+	p.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for subject, server := range servers {
+		wg.Add(1)
+		go func(subject string, server *userServer) {
+			defer wg.Done()
+			stopServer(subject, server)
+		}(subject, server)
+	}
+	wg.Wait()
+}
+
+// stopServer waits for a server to finish starting and terminates it
+func stopServer(subject string, server *userServer) {
+	<-server.ready
+	if server.process != nil && server.process.IsRunning() {
+		subprocessLog.Info("Stopping server for %s on port %d", subject, server.port)
+		server.process.Shutdown()
+	}
+}
+
+// waitForPort waits until something accepts connections on the local port
+func waitForPort(port int, timeout time.Duration) error {
+	address := fmt.Sprintf("localhost:%d", port)
+	deadline := time.Now().Add(timeout)
+	for {
+		conn, err := net.DialTimeout("tcp", address, time.Second)
+		if err == nil {
+			conn.Close()
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("user server on port %d did not start within %v", port, timeout)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// envHash fingerprints an environment so credential changes restart the server
+func envHash(env []string) string {
+	sorted := append([]string{}, env...)
+	sort.Strings(sorted)
+	sum := sha256.Sum256([]byte(strings.Join(sorted, "\x00")))
+	return fmt.Sprintf("%x", sum)
+}
+
+func isClosed(ch chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
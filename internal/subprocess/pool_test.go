@@ -0,0 +1,172 @@
+package subprocess
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/wso2/open-mcp-auth-proxy/internal/config"
+	"github.com/wso2/open-mcp-auth-proxy/internal/metrics"
+)
+
+// fakeServer listens on the user server's port instead of running a command
+type fakeServer struct {
+	listener net.Listener
+	env      []string
+	mu       sync.Mutex
+	stopped  bool
+}
+
+func (s *fakeServer) IsRunning() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return !s.stopped
+}
+
+func (s *fakeServer) Shutdown() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stopped = true
+	s.listener.Close()
+}
+
+func newTestPool(t *testing.T, maxServers int) (*Pool, *[]*fakeServer) {
+	// Reserve a free port to start from
+	ln, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	basePort := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+
+	cfg := &config.Config{Stdio: config.StdioConfig{Enabled: true, UserCommand: "server", Env: []string{"SHARED=1"}}}
+	cfg.Vault = config.VaultConfig{BasePort: basePort, MaxProcesses: maxServers, IdleTimeoutSeconds: 3600}
+
+	var mu sync.Mutex
+	started := &[]*fakeServer{}
+	pool := NewPool(cfg)
+	pool.startServer = func(serverCfg *config.Config) (serverProcess, error) {
+		ln, err := net.Listen("tcp", fmt.Sprintf("localhost:%d", serverCfg.Port))
+		if err != nil {
+			return nil, err
+		}
+		server := &fakeServer{listener: ln, env: serverCfg.Stdio.Env}
+		mu.Lock()
+		*started = append(*started, server)
+		mu.Unlock()
+		return server, nil
+	}
+	t.Cleanup(pool.Shutdown)
+	return pool, started
+}
+
+func TestPoolServerPerUser(t *testing.T) {
+	pool, started := newTestPool(t, 2)
+
+	aliceURL, err := pool.Get("alice", []string{"GITHUB_TOKEN=a"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if again, _ := pool.Get("alice", []string{"GITHUB_TOKEN=a"}); again != aliceURL {
+		t.Errorf("Expected alice's server to be reused, got %s and %s", aliceURL, again)
+	}
+	bobURL, err := pool.Get("bob", []string{"GITHUB_TOKEN=b"})
+	if err != nil || bobURL == aliceURL {
+		t.Errorf("Expected a separate server for bob, got %s (%v)", bobURL, err)
+	}
+
+	if len(*started) != 2 {
+		t.Fatalf("Expected 2 servers, got %d", len(*started))
+	}
+	if env := (*started)[0].env; len(env) != 2 || env[0] != "SHARED=1" || env[1] != "GITHUB_TOKEN=a" {
+		t.Errorf("Expected shared and user environment, got %v", env)
+	}
+}
+
+func TestPoolRestartsOnCredentialChange(t *testing.T) {
+	pool, started := newTestPool(t, 2)
+	restarts := metrics.SubprocessRestarts.Value(RestartCredentialsChanged)
+
+	pool.Get("alice", []string{"GITHUB_TOKEN=old"})
+	if _, err := pool.Get("alice", []string{"GITHUB_TOKEN=new"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(*started) != 2 {
+		t.Fatalf("Expected the server to be restarted, got %d starts", len(*started))
+	}
+	if got := metrics.SubprocessRestarts.Value(RestartCredentialsChanged) - restarts; got != 1 {
+		t.Errorf("Expected one restart to be counted, got %v", got)
+	}
+	deadline := time.Now().Add(time.Second)
+	for (*started)[0].IsRunning() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if (*started)[0].IsRunning() {
+		t.Errorf("Expected the old server to be stopped")
+	}
+}
+
+func TestPoolEvictsLeastRecentlyUsed(t *testing.T) {
+	pool, _ := newTestPool(t, 1)
+	now := time.Now()
+	pool.now = func() time.Time { return now }
+
+	if _, err := pool.Get("alice", nil); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	now = now.Add(time.Minute)
+	if _, err := pool.Get("bob", nil); err != nil {
+		t.Fatalf("Expected alice's server to be evicted, got %v", err)
+	}
+
+	pool.mu.Lock()
+	_, aliceRunning := pool.servers["alice"]
+	pool.mu.Unlock()
+	if aliceRunning {
+		t.Errorf("Expected alice's server to be removed from the pool")
+	}
+}
+
+func TestPoolKeepsServersInUse(t *testing.T) {
+	pool, _ := newTestPool(t, 1)
+	now := time.Now()
+	pool.now = func() time.Time { return now }
+
+	_, release, err := pool.Acquire("alice", nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	running := func(subject string) bool {
+		pool.mu.Lock()
+		defer pool.mu.Unlock()
+		_, ok := pool.servers[subject]
+		return ok
+	}
+
+	// An open stream outlives the idle timeout and isn't evicted
+	now = now.Add(2 * time.Hour)
+	pool.stopIdle()
+	if !running("alice") {
+		t.Fatalf("Expected alice's server to be kept while her stream is open")
+	}
+	if _, err := pool.Get("bob", nil); err != ErrPoolFull {
+		t.Errorf("Expected %v while alice's stream is open, got %v", ErrPoolFull, err)
+	}
+
+	// Once the stream closes, the server idles from then on
+	release()
+	release()
+	now = now.Add(30 * time.Minute)
+	pool.stopIdle()
+	if !running("alice") {
+		t.Errorf("Expected alice's server to idle from the end of her stream")
+	}
+	now = now.Add(2 * time.Hour)
+	pool.stopIdle()
+	if running("alice") {
+		t.Errorf("Expected alice's idle server to be stopped")
+	}
+}
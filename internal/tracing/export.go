@@ -0,0 +1,176 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/wso2/open-mcp-auth-proxy/internal/config"
+)
+
+// scopeName names the instrumentation in exported spans
+const scopeName = "github.com/wso2/open-mcp-auth-proxy"
+
+// OTLPExporter posts spans to an OTLP/HTTP collector, JSON encoded
+type OTLPExporter struct {
+	cfg    config.TracingConfig
+	client *http.Client
+}
+
+// NewOTLPExporter posts to cfg.Endpoint with client
+func NewOTLPExporter(cfg config.TracingConfig, client *http.Client) *OTLPExporter {
+	return &OTLPExporter{cfg: cfg, client: client}
+}
+
+// Export implements Exporter
+func (e *OTLPExporter) Export(spans []SpanData) error {
+	body, err := json.Marshal(encode(e.cfg.ServiceName, spans))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, e.cfg.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range e.cfg.Headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("collector returned %s", resp.Status)
+	}
+	return nil
+}
+
+// WriterExporter writes each batch as an OTLP JSON line, such as to stdout
+type WriterExporter struct {
+	serviceName string
+	mu          sync.Mutex
+	w           io.Writer
+}
+
+// NewWriterExporter writes to w
+func NewWriterExporter(cfg config.TracingConfig, w io.Writer) *WriterExporter {
+	return &WriterExporter{serviceName: cfg.ServiceName, w: w}
+}
+
+// Export implements Exporter
+func (e *WriterExporter) Export(spans []SpanData) error {
+	line, err := json.Marshal(encode(e.serviceName, spans))
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_, err = e.w.Write(append(line, '\n'))
+	return err
+}
+
+// The OTLP/JSON encoding of ExportTraceServiceRequest. IDs are hex strings
+// and 64-bit integers are decimal strings.
+type (
+	exportRequest struct {
+		ResourceSpans []resourceSpans `json:"resourceSpans"`
+	}
+	resourceSpans struct {
+		Resource   resource     `json:"resource"`
+		ScopeSpans []scopeSpans `json:"scopeSpans"`
+	}
+	resource struct {
+		Attributes []keyValue `json:"attributes"`
+	}
+	scopeSpans struct {
+		Scope scope      `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	scope struct {
+		Name string `json:"name"`
+	}
+	otlpSpan struct {
+		TraceID           string     `json:"traceId"`
+		SpanID            string     `json:"spanId"`
+		ParentSpanID      string     `json:"parentSpanId,omitempty"`
+		TraceState        string     `json:"traceState,omitempty"`
+		Name              string     `json:"name"`
+		Kind              Kind       `json:"kind"`
+		StartTimeUnixNano string     `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string     `json:"endTimeUnixNano"`
+		Attributes        []keyValue `json:"attributes,omitempty"`
+		Status            status     `json:"status"`
+	}
+	status struct {
+		Code    int    `json:"code,omitempty"`
+		Message string `json:"message,omitempty"`
+	}
+	keyValue struct {
+		Key   string   `json:"key"`
+		Value anyValue `json:"value"`
+	}
+	anyValue struct {
+		StringValue *string  `json:"stringValue,omitempty"`
+		BoolValue   *bool    `json:"boolValue,omitempty"`
+		IntValue    *string  `json:"intValue,omitempty"`
+		DoubleValue *float64 `json:"doubleValue,omitempty"`
+	}
+)
+
+func encode(serviceName string, spans []SpanData) exportRequest {
+	encoded := make([]otlpSpan, len(spans))
+	for i, s := range spans {
+		encoded[i] = otlpSpan{
+			TraceID:           s.SpanContext.TraceID.String(),
+			SpanID:            s.SpanContext.SpanID.String(),
+			TraceState:        s.SpanContext.TraceState,
+			Name:              s.Name,
+			Kind:              s.Kind,
+			StartTimeUnixNano: strconv.FormatInt(s.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.End.UnixNano(), 10),
+			Attributes:        encodeAttributes(s.Attributes),
+			Status:            status{Code: s.StatusCode, Message: s.StatusMessage},
+		}
+		if s.Parent.IsValid() {
+			encoded[i].ParentSpanID = s.Parent.String()
+		}
+	}
+	return exportRequest{ResourceSpans: []resourceSpans{{
+		Resource:   resource{Attributes: encodeAttributes([]Attribute{{Key: "service.name", Value: serviceName}})},
+		ScopeSpans: []scopeSpans{{Scope: scope{Name: scopeName}, Spans: encoded}},
+	}}}
+}
+
+func encodeAttributes(attrs []Attribute) []keyValue {
+	encoded := make([]keyValue, 0, len(attrs))
+	for _, a := range attrs {
+		var v anyValue
+		switch value := a.Value.(type) {
+		case string:
+			v.StringValue = &value
+		case bool:
+			v.BoolValue = &value
+		case int:
+			s := strconv.Itoa(value)
+			v.IntValue = &s
+		case int64:
+			s := strconv.FormatInt(value, 10)
+			v.IntValue = &s
+		case float64:
+			v.DoubleValue = &value
+		default:
+			s := fmt.Sprint(value)
+			v.StringValue = &s
+		}
+		encoded = append(encoded, keyValue{Key: a.Key, Value: v})
+	}
+	return encoded
+}
@@ -0,0 +1,112 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/wso2/open-mcp-auth-proxy/internal/config"
+)
+
+// collectorStub is an in-process OTLP/HTTP collector
+type collectorStub struct {
+	mu       sync.Mutex
+	requests []exportRequest
+	headers  []http.Header
+}
+
+func newCollectorStub(t *testing.T) (*collectorStub, *httptest.Server) {
+	c := &collectorStub{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req exportRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		c.mu.Lock()
+		c.requests = append(c.requests, req)
+		c.headers = append(c.headers, r.Header.Clone())
+		c.mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+	return c, server
+}
+
+func TestOTLPExporter(t *testing.T) {
+	collector, server := newCollectorStub(t)
+	ratio := 1.0
+	cfg := config.TracingConfig{
+		Enabled:     true,
+		Exporter:    "otlp",
+		Endpoint:    server.URL + "/v1/traces",
+		Headers:     map[string]string{"Authorization": "Bearer collector-token"},
+		ServiceName: "mcp-proxy-test",
+		SampleRatio: &ratio,
+		BatchSize:   10,
+		QueueSize:   10,
+	}
+	tracer, err := Open(cfg, server.Client())
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	SetTracer(tracer)
+	defer SetTracer(nil)
+
+	ctx, parent := Start(context.Background(), "POST /messages", KindServer)
+	_, child := Start(ctx, "tools/call search", KindInternal)
+	child.SetAttributes("gen_ai.tool.name", "search", "http.response.status_code", 502, "retried", false)
+	child.SetError(errors.New("upstream unavailable"))
+	child.End()
+	parent.End()
+	tracer.Shutdown()
+
+	if len(collector.requests) != 1 {
+		t.Fatalf("Expected one export, got %d", len(collector.requests))
+	}
+	if got := collector.headers[0].Get("Authorization"); got != "Bearer collector-token" {
+		t.Errorf("Expected the configured headers, got %q", got)
+	}
+	rs := collector.requests[0].ResourceSpans[0]
+	if v := rs.Resource.Attributes[0]; v.Key != "service.name" || *v.Value.StringValue != "mcp-proxy-test" {
+		t.Errorf("Unexpected resource attributes %+v", rs.Resource.Attributes)
+	}
+	spans := rs.ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans, got %d", len(spans))
+	}
+	exported := spans[0]
+	if exported.Name != "tools/call search" || exported.Kind != KindInternal || exported.ParentSpanID != spans[1].SpanID || exported.TraceID != spans[1].TraceID {
+		t.Errorf("Unexpected span %+v", exported)
+	}
+	if len(exported.TraceID) != 32 || len(exported.SpanID) != 16 || exported.StartTimeUnixNano == "" {
+		t.Errorf("Expected hex IDs and times, got %+v", exported)
+	}
+	if exported.Status.Code != StatusError || exported.Status.Message != "upstream unavailable" {
+		t.Errorf("Unexpected status %+v", exported.Status)
+	}
+	if *exported.Attributes[1].Value.IntValue != "502" || *exported.Attributes[2].Value.BoolValue {
+		t.Errorf("Unexpected attributes %+v", exported.Attributes)
+	}
+	if spans[1].ParentSpanID != "" {
+		t.Errorf("Expected the root span to have no parent")
+	}
+}
+
+func TestWriterExporter(t *testing.T) {
+	var buf bytes.Buffer
+	exporter := NewWriterExporter(config.TracingConfig{ServiceName: "mcp-proxy-test"}, &buf)
+	err := exporter.Export([]SpanData{{Name: "GET /sse", Kind: KindServer, SpanContext: SpanContext{TraceID: newTraceID(), SpanID: newSpanID()}}})
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if !strings.HasSuffix(buf.String(), "\n") || !strings.Contains(buf.String(), `"name":"GET /sse"`) {
+		t.Errorf("Expected an OTLP JSON line, got %q", buf.String())
+	}
+}
@@ -0,0 +1,172 @@
+package tracing
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/wso2/open-mcp-auth-proxy/internal/config"
+	logger "github.com/wso2/open-mcp-auth-proxy/internal/logging"
+)
+
+// Exporter sends finished spans to a collector
+type Exporter interface {
+	Export(spans []SpanData) error
+}
+
+// Tracer batches finished spans and hands them to an exporter
+type Tracer struct {
+	cfg      config.TracingConfig
+	exporter Exporter
+	ratio    float64
+	now      func() time.Time
+
+	mu      sync.Mutex
+	queue   chan SpanData
+	closed  bool
+	dropped int // Since the last warning
+	done    chan struct{}
+}
+
+// New starts a tracer exporting with exporter, or returns nil when tracing is disabled
+func New(cfg config.TracingConfig, exporter Exporter) *Tracer {
+	if !cfg.Enabled {
+		return nil
+	}
+	ratio := 1.0
+	if cfg.SampleRatio != nil {
+		ratio = *cfg.SampleRatio
+	}
+	t := &Tracer{
+		cfg:      cfg,
+		exporter: exporter,
+		ratio:    ratio,
+		now:      time.Now,
+		queue:    make(chan SpanData, cfg.QueueSize),
+		done:     make(chan struct{}),
+	}
+	go t.run()
+	return t
+}
+
+// Open returns a tracer with the configured exporter, or nil when tracing is
+// disabled. client posts spans to the OTLP endpoint.
+func Open(cfg config.TracingConfig, client *http.Client) (*Tracer, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	var exporter Exporter
+	switch cfg.Exporter {
+	case "otlp":
+		exporter = NewOTLPExporter(cfg, client)
+	case "stdout":
+		exporter = NewWriterExporter(cfg, os.Stdout)
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q", cfg.Exporter)
+	}
+	return New(cfg, exporter), nil
+}
+
+var (
+	currentMu sync.RWMutex
+	current   *Tracer
+)
+
+// SetTracer makes t the tracer of Start; nil disables tracing
+func SetTracer(t *Tracer) {
+	currentMu.Lock()
+	defer currentMu.Unlock()
+	current = t
+}
+
+// Current returns the tracer of Start, nil when tracing is disabled
+func Current() *Tracer {
+	currentMu.RLock()
+	defer currentMu.RUnlock()
+	return current
+}
+
+// sample decides whether a new trace is recorded. Traces continuing a remote
+// parent follow the parent's decision instead.
+func (t *Tracer) sample(id TraceID) bool {
+	return traceIDBound(id) < t.ratio
+}
+
+func (t *Tracer) enqueue(span SpanData) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return
+	}
+	select {
+	case t.queue <- span:
+	default:
+		t.dropped++
+	}
+}
+
+// Shutdown exports the queued spans and stops the tracer
+func (t *Tracer) Shutdown() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	if !t.closed {
+		t.closed = true
+		close(t.queue)
+	}
+	t.mu.Unlock()
+	<-t.done
+}
+
+func (t *Tracer) run() {
+	defer close(t.done)
+
+	interval := time.Duration(t.cfg.FlushIntervalSeconds) * time.Second
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var batch []SpanData
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := t.exporter.Export(batch); err != nil {
+			logger.Error("Failed to export %d spans: %v", len(batch), err)
+		}
+		batch = nil
+	}
+
+	for {
+		select {
+		case span, ok := <-t.queue:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, span)
+			if len(batch) >= t.cfg.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+			t.reportDropped()
+		}
+	}
+}
+
+func (t *Tracer) reportDropped() {
+	t.mu.Lock()
+	dropped := t.dropped
+	t.dropped = 0
+	t.mu.Unlock()
+	if dropped > 0 {
+		logger.Warn("Dropped %d spans while the export queue was full", dropped)
+	}
+}
@@ -0,0 +1,290 @@
+// Package tracing records OpenTelemetry spans of the requests going through
+// the proxy and propagates W3C trace context to the servers it calls
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Headers of the W3C Trace Context
+const (
+	TraceparentHeader = "traceparent"
+	TracestateHeader  = "tracestate"
+)
+
+// Kind is the role of a span in a trace, numbered as in OTLP
+type Kind int
+
+const (
+	KindInternal Kind = 1
+	KindServer   Kind = 2
+	KindClient   Kind = 3
+)
+
+// Status codes of a span, numbered as in OTLP
+const (
+	StatusUnset = 0
+	StatusOK    = 1
+	StatusError = 2
+)
+
+// TraceID identifies a trace
+type TraceID [16]byte
+
+// SpanID identifies a span within a trace
+type SpanID [8]byte
+
+func (t TraceID) String() string { return hex.EncodeToString(t[:]) }
+func (s SpanID) String() string  { return hex.EncodeToString(s[:]) }
+
+// IsValid reports whether the ID isn't all zeros
+func (t TraceID) IsValid() bool { return t != TraceID{} }
+
+// IsValid reports whether the ID isn't all zeros
+func (s SpanID) IsValid() bool { return s != SpanID{} }
+
+// SpanContext is the part of a span propagated across process boundaries
+type SpanContext struct {
+	TraceID    TraceID
+	SpanID     SpanID
+	Sampled    bool
+	TraceState string // Passed on unchanged
+}
+
+// IsValid reports whether both IDs are set
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// Traceparent formats the span context as a traceparent header value
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + flags
+}
+
+// ParseTraceparent parses a traceparent header value. Versions after 00 are
+// read as far as version 00 defines them.
+func ParseTraceparent(value string) (SpanContext, error) {
+	value = strings.TrimSpace(value)
+	parts := strings.Split(value, "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return SpanContext{}, fmt.Errorf("malformed traceparent %q", value)
+	}
+	if parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return SpanContext{}, fmt.Errorf("invalid traceparent version in %q", value)
+	}
+	var sc SpanContext
+	var flags [1]byte
+	if !decodeHex(sc.TraceID[:], parts[1]) || !decodeHex(sc.SpanID[:], parts[2]) || !decodeHex(flags[:], parts[3]) || !decodeHex(nil, parts[0]) {
+		return SpanContext{}, fmt.Errorf("malformed traceparent %q", value)
+	}
+	if !sc.IsValid() {
+		return SpanContext{}, fmt.Errorf("traceparent %q has an all-zero ID", value)
+	}
+	sc.Sampled = flags[0]&1 == 1
+	return sc, nil
+}
+
+// decodeHex decodes lowercase hex into dst; a nil dst only validates
+func decodeHex(dst []byte, s string) bool {
+	if strings.ToLower(s) != s {
+		return false
+	}
+	decoded, err := hex.DecodeString(s)
+	if err != nil {
+		return false
+	}
+	copy(dst, decoded)
+	return true
+}
+
+type spanKey struct{}
+type remoteKey struct{}
+
+// Extract returns a context carrying the span context of the traceparent and
+// tracestate headers, if they are valid, as the parent of the next span
+func Extract(ctx context.Context, header http.Header) context.Context {
+	sc, err := ParseTraceparent(header.Get(TraceparentHeader))
+	if err != nil {
+		return ctx
+	}
+	// Repeated tracestate headers are one list
+	sc.TraceState = strings.Join(header.Values(TracestateHeader), ",")
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+// Inject sets the traceparent and tracestate headers to the span context of
+// the current span in ctx. Headers are left alone when there is none.
+func Inject(ctx context.Context, header http.Header) {
+	sc := SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return
+	}
+	header.Set(TraceparentHeader, sc.Traceparent())
+	if sc.TraceState != "" {
+		header.Set(TracestateHeader, sc.TraceState)
+	} else {
+		header.Del(TracestateHeader)
+	}
+}
+
+// SpanContextFromContext returns the span context of the current span in
+// ctx, or of the remote parent when no span was started
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	if span, ok := ctx.Value(spanKey{}).(*Span); ok {
+		return span.sc
+	}
+	sc, _ := ctx.Value(remoteKey{}).(SpanContext)
+	return sc
+}
+
+// Span is an operation being timed. All methods are safe on a nil span,
+// which is what Start returns when tracing is disabled.
+type Span struct {
+	tracer *Tracer
+	sc     SpanContext
+	parent SpanID
+	name   string
+	kind   Kind
+	start  time.Time
+
+	mu            sync.Mutex
+	attrs         []Attribute
+	statusCode    int
+	statusMessage string
+	ended         bool
+}
+
+// Attribute is a key-value pair describing a span
+type Attribute struct {
+	Key   string
+	Value interface{} // string, bool, int, int64 or float64
+}
+
+// Start begins a span named name, a child of the current span in ctx, and
+// returns a context carrying it. It returns ctx and a nil span when tracing is disabled.
+func Start(ctx context.Context, name string, kind Kind) (context.Context, *Span) {
+	t := Current()
+	if t == nil {
+		return ctx, nil
+	}
+	parent := SpanContextFromContext(ctx)
+
+	span := &Span{tracer: t, name: name, kind: kind, start: t.now()}
+	if parent.IsValid() {
+		span.sc = SpanContext{TraceID: parent.TraceID, Sampled: parent.Sampled, TraceState: parent.TraceState}
+		span.parent = parent.SpanID
+	} else {
+		span.sc.TraceID = newTraceID()
+		span.sc.Sampled = t.sample(span.sc.TraceID)
+	}
+	span.sc.SpanID = newSpanID()
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// SpanContext returns the IDs of the span
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.sc
+}
+
+// SetAttributes adds key-value pairs, given in turn, to the span
+func (s *Span) SetAttributes(args ...interface{}) {
+	if s == nil || !s.sc.Sampled {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i+1 < len(args); i += 2 {
+		key, _ := args[i].(string)
+		s.attrs = append(s.attrs, Attribute{Key: key, Value: args[i+1]})
+	}
+}
+
+// SetError marks the span as failed with err
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.statusCode = StatusError
+	s.statusMessage = err.Error()
+}
+
+// End finishes the span and queues it for export if it's sampled
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	data := SpanData{
+		Name:          s.name,
+		Kind:          s.kind,
+		SpanContext:   s.sc,
+		Parent:        s.parent,
+		Start:         s.start,
+		End:           s.tracer.now(),
+		Attributes:    s.attrs,
+		StatusCode:    s.statusCode,
+		StatusMessage: s.statusMessage,
+	}
+	s.mu.Unlock()
+
+	if s.sc.Sampled {
+		s.tracer.enqueue(data)
+	}
+}
+
+// SpanData is a finished span
+type SpanData struct {
+	Name          string
+	Kind          Kind
+	SpanContext   SpanContext
+	Parent        SpanID // Zero for root spans
+	Start         time.Time
+	End           time.Time
+	Attributes    []Attribute
+	StatusCode    int
+	StatusMessage string
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	for !id.IsValid() {
+		rand.Read(id[:])
+	}
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	for !id.IsValid() {
+		rand.Read(id[:])
+	}
+	return id
+}
+
+// traceIDBound maps a trace ID to [0, 1) for ratio sampling, so that every
+// replica samples the same traces
+func traceIDBound(id TraceID) float64 {
+	return float64(binary.BigEndian.Uint64(id[8:])>>11) / (1 << 53)
+}
@@ -0,0 +1,148 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/wso2/open-mcp-auth-proxy/internal/config"
+)
+
+// recordingExporter keeps exported spans in memory
+type recordingExporter struct {
+	spans []SpanData
+}
+
+func (e *recordingExporter) Export(spans []SpanData) error {
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+// useTracer installs a tracer for the test and returns a function flushing
+// it and returning the exported spans
+func useTracer(t *testing.T, ratio float64) func() []SpanData {
+	exporter := &recordingExporter{}
+	tracer := New(config.TracingConfig{Enabled: true, SampleRatio: &ratio, BatchSize: 100, QueueSize: 100}, exporter)
+	SetTracer(tracer)
+	t.Cleanup(func() { SetTracer(nil) })
+	return func() []SpanData {
+		tracer.Shutdown()
+		return exporter.spans
+	}
+}
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		valid   bool
+		sampled bool
+	}{
+		{"Sampled", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true, true},
+		{"Not sampled", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", true, false},
+		{"Future version with more fields", "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", true, true},
+		{"Version 00 with more fields", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", false, false},
+		{"Invalid version", "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false, false},
+		{"Zero trace ID", "00-00000000000000000000000000000000-00f067aa0ba902b7-01", false, false},
+		{"Zero span ID", "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false, false},
+		{"Uppercase", "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", false, false},
+		{"Short trace ID", "00-4bf92f3577b34da6-00f067aa0ba902b7-01", false, false},
+		{"Empty", "", false, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			sc, err := ParseTraceparent(tc.value)
+			if tc.valid != (err == nil) {
+				t.Fatalf("Expected valid=%v, got error %v", tc.valid, err)
+			}
+			if !tc.valid {
+				return
+			}
+			if sc.Sampled != tc.sampled {
+				t.Errorf("Expected sampled=%v, got %v", tc.sampled, sc.Sampled)
+			}
+			if got := sc.TraceID.String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+				t.Errorf("Unexpected trace ID %s", got)
+			}
+		})
+	}
+}
+
+func TestSpansContinueRemoteTrace(t *testing.T) {
+	flush := useTracer(t, 1)
+
+	header := http.Header{}
+	header.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	header.Add(TracestateHeader, "vendor=abc")
+	header.Add(TracestateHeader, "other=1")
+	ctx := Extract(context.Background(), header)
+
+	ctx, server := Start(ctx, "GET /sse", KindServer)
+	_, child := Start(ctx, "authz.validate_token", KindInternal)
+	child.SetAttributes("authz.failure_reason", "expired", "ignored-without-value")
+	child.End()
+	child.End() // Ending twice exports once
+
+	out := http.Header{}
+	Inject(ctx, out)
+	server.End()
+
+	spans := flush()
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans, got %d", len(spans))
+	}
+	validation, request := spans[0], spans[1]
+	if request.SpanContext.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || request.Parent.String() != "00f067aa0ba902b7" {
+		t.Errorf("Expected the server span to continue the remote trace, got %+v", request.SpanContext)
+	}
+	if validation.Parent != request.SpanContext.SpanID || validation.SpanContext.TraceID != request.SpanContext.TraceID {
+		t.Errorf("Expected the validation span to be a child of the server span")
+	}
+	if len(validation.Attributes) != 1 || validation.Attributes[0].Value != "expired" {
+		t.Errorf("Unexpected attributes %v", validation.Attributes)
+	}
+	expected := "00-4bf92f3577b34da6a3ce929d0e0e4736-" + request.SpanContext.SpanID.String() + "-01"
+	if got := out.Get(TraceparentHeader); got != expected {
+		t.Errorf("Expected traceparent %s, got %s", expected, got)
+	}
+	if got := out.Get(TracestateHeader); got != "vendor=abc,other=1" {
+		t.Errorf("Expected tracestate to be passed on, got %q", got)
+	}
+}
+
+func TestSampling(t *testing.T) {
+	flush := useTracer(t, 0)
+
+	// New traces aren't sampled at ratio 0, but still propagate their IDs
+	ctx, root := Start(context.Background(), "GET /sse", KindServer)
+	out := http.Header{}
+	Inject(ctx, out)
+	root.End()
+	if sc, err := ParseTraceparent(out.Get(TraceparentHeader)); err != nil || sc.Sampled {
+		t.Errorf("Expected an unsampled traceparent, got %q", out.Get(TraceparentHeader))
+	}
+
+	// Callers that sampled their trace decide for the proxy
+	header := http.Header{}
+	header.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	_, continued := Start(Extract(context.Background(), header), "POST /messages", KindServer)
+	continued.End()
+
+	spans := flush()
+	if len(spans) != 1 || spans[0].Name != "POST /messages" {
+		t.Errorf("Expected only the sampled trace to be exported, got %+v", spans)
+	}
+}
+
+func TestDisabledTracing(t *testing.T) {
+	SetTracer(nil)
+	ctx, span := Start(context.Background(), "GET /sse", KindServer)
+	if span != nil || ctx != context.Background() {
+		t.Errorf("Expected no span while tracing is disabled")
+	}
+	// Nil spans are safe to use
+	span.SetAttributes("key", "value")
+	span.SetError(context.Canceled)
+	span.End()
+}
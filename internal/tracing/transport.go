@@ -0,0 +1,51 @@
+package tracing
+
+import (
+	"fmt"
+	"net/http"
+
+	logger "github.com/wso2/open-mcp-auth-proxy/internal/logging"
+)
+
+// transport records a client span around each request and tells the server
+// about it in the traceparent header
+type transport struct {
+	base http.RoundTripper
+}
+
+// Transport wraps base so requests carry the trace context of their span.
+// Requests pass through untouched while tracing is disabled.
+func Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{base: base}
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, span := Start(req.Context(), "HTTP "+req.Method, KindClient)
+	if span == nil {
+		return t.base.RoundTrip(req)
+	}
+	defer span.End()
+	span.SetAttributes(
+		"http.request.method", req.Method,
+		"server.address", req.URL.Hostname(),
+		"url.full", logger.Redact(req.URL.String()),
+	)
+
+	// A RoundTripper mustn't modify the caller's request
+	outreq := req.Clone(ctx)
+	Inject(ctx, outreq.Header)
+
+	resp, err := t.base.RoundTrip(outreq)
+	if err != nil {
+		span.SetError(err)
+		return nil, err
+	}
+	span.SetAttributes("http.response.status_code", resp.StatusCode)
+	if resp.StatusCode >= 500 {
+		span.SetError(fmt.Errorf("server returned %s", resp.Status))
+	}
+	return resp, nil
+}
@@ -165,9 +165,36 @@ func (eh *ErrorHandler) LogStartupError(err error, component string) {
 		logger.Error("   • Verify the JWKS URL is correct")
 		logger.Error("   • Ensure the identity provider is accessible")
 		logger.Error("   • Check firewall and proxy settings")
+	case "outbound":
+		logger.Error("💡 Outbound HTTP client help:")
+		logger.Error("   • Check that outbound.ca_file points to a readable PEM bundle")
+		logger.Error("   • Verify outbound.proxy_url is a valid URL")
+	case "vault":
+		logger.Error("💡 Credential vault help:")
+		logger.Error("   • Set vault.key_file or MCP_VAULT_KEY to a 32-byte key (raw, hex or base64)")
+		logger.Error("   • Use the same key the vault file was created with")
+		logger.Error("   • Check that the directory of vault.file is writable")
+	case "tls":
+		logger.Error("💡 TLS listener help:")
+		logger.Error("   • Check that tls.cert_file and tls.key_file are a matching PEM key pair")
+		logger.Error("   • Verify tls.client_ca_file contains PEM certificates")
+		logger.Error("   • Use cipher suite names as listed by Go's crypto/tls")
+	case "cluster":
+		logger.Error("💡 Cluster help:")
+		logger.Error("   • Set cluster.session_store to file or redis")
+		logger.Error("   • For redis, check that cluster.redis.address is reachable")
+	case "audit":
+		logger.Error("💡 Audit log help:")
+		logger.Error("   • Check that the directory of audit.file is writable")
+		logger.Error("   • Verify the audit.webhook.transport certificate files are readable")
+	case "tracing":
+		logger.Error("💡 Tracing help:")
+		logger.Error("   • Verify the tracing.transport certificate files are readable")
+		logger.Error("   • Use exporter: stdout to check spans without a collector")
 	case "server":
 		logger.Error("💡 Server startup help:")
 		logger.Error("   • Check if the port is already in use")
+		logger.Error("   • For unix: listen addresses, check that the socket directory exists and is writable")
 		logger.Error("   • Verify sufficient permissions")
 		logger.Error("   • Check network interface configuration")
 	}
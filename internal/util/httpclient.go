@@ -0,0 +1,202 @@
+package util
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/wso2/open-mcp-auth-proxy/internal/config"
+	logger "github.com/wso2/open-mcp-auth-proxy/internal/logging"
+	"github.com/wso2/open-mcp-auth-proxy/internal/tracing"
+)
+
+const defaultOutboundTimeout = 15 * time.Second
+
+var (
+	outboundClient    *http.Client
+	upstreamTransport http.RoundTripper
+	outboundMutex     sync.RWMutex
+
+	defaultClient     *http.Client
+	defaultClientOnce sync.Once
+)
+
+// NewHTTPClient builds a hardened HTTP client from the transport configuration.
+// fallbackTimeout is used when the configuration doesn't set its own timeout.
+func NewHTTPClient(tc config.TransportConfig, fallbackTimeout time.Duration) (*http.Client, error) {
+	transport, err := NewTransport(tc)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := fallbackTimeout
+	if tc.TimeoutSeconds > 0 {
+		timeout = time.Duration(tc.TimeoutSeconds) * time.Second
+	}
+	if timeout <= 0 {
+		timeout = defaultOutboundTimeout
+	}
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+	}, nil
+}
+
+// NewTransport builds an http.Transport that verifies TLS certificates against
+// the system roots plus the configured CA bundle.
+func NewTransport(tc config.TransportConfig) (*http.Transport, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12, ServerName: tc.ServerName}
+
+	if tc.CAFile != "" {
+		pool, err := loadCertPool(tc.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if tc.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(tc.CertFile, tc.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if tc.InsecureSkipVerify {
+		logger.Warn("TLS certificate verification is DISABLED for outbound calls; never use insecure_skip_verify in production")
+		tlsConfig.InsecureSkipVerify = true
+	}
+
+	proxy := http.ProxyFromEnvironment
+	if tc.ProxyURL != "" {
+		proxyURL, err := url.Parse(tc.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy_url: %w", err)
+		}
+		proxy = http.ProxyURL(proxyURL)
+	}
+
+	transport := &http.Transport{
+		Proxy: proxy,
+		DialContext: (&net.Dialer{
+			Timeout:   secondsOr(tc.DialTimeoutSeconds, 30*time.Second),
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSClientConfig:       tlsConfig,
+		ForceAttemptHTTP2:     !tc.DisableHTTP2,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   tc.MaxIdleConnsPerHost,
+		IdleConnTimeout:       secondsOr(tc.IdleConnTimeoutSeconds, 90*time.Second),
+		ResponseHeaderTimeout: time.Duration(tc.ResponseHeaderTimeoutSeconds) * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+	if tc.MaxIdleConns > 0 {
+		transport.MaxIdleConns = tc.MaxIdleConns
+	}
+	if tc.DisableHTTP2 {
+		// A non-nil empty map keeps the transport from upgrading to HTTP/2
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+	return transport, nil
+}
+
+// secondsOr converts seconds to a duration, using fallback when unset
+func secondsOr(seconds int, fallback time.Duration) time.Duration {
+	if seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return fallback
+}
+
+// loadCertPool returns the system cert pool extended with the PEM certificates in caFile
+func loadCertPool(caFile string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in CA bundle %s", caFile)
+	}
+	return pool, nil
+}
+
+// InitOutboundClient builds the shared client used for identity provider calls
+func InitOutboundClient(cfg *config.Config) error {
+	client, err := NewHTTPClient(cfg.Outbound, time.Duration(cfg.TimeoutSeconds)*time.Second)
+	if err != nil {
+		return err
+	}
+	// Identity provider calls show up as client spans when tracing is enabled
+	client.Transport = tracing.Transport(client.Transport)
+
+	outboundMutex.Lock()
+	defer outboundMutex.Unlock()
+	outboundClient = client
+	return nil
+}
+
+// OutboundClient returns the shared client for identity provider calls,
+// falling back to a verified default client when none was initialized.
+func OutboundClient() *http.Client {
+	outboundMutex.RLock()
+	client := outboundClient
+	outboundMutex.RUnlock()
+	if client != nil {
+		return client
+	}
+
+	defaultClientOnce.Do(func() {
+		// The zero configuration has no files to load, so this cannot fail
+		defaultClient, _ = NewHTTPClient(config.TransportConfig{}, defaultOutboundTimeout)
+		defaultClient.Transport = tracing.Transport(defaultClient.Transport)
+	})
+	return defaultClient
+}
+
+// InitUpstreamTransport builds the shared transport used to reach the MCP server
+func InitUpstreamTransport(cfg *config.Config) error {
+	transport, err := NewTransport(cfg.Upstream)
+	if err != nil {
+		return err
+	}
+
+	// Every connection to a unix: base URL goes to the socket, whatever the URL host
+	if path := config.SocketPath(cfg.BaseURL); path != "" {
+		dialer := &net.Dialer{Timeout: secondsOr(cfg.Upstream.DialTimeoutSeconds, 30*time.Second)}
+		transport.Proxy = nil
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "unix", path)
+		}
+	}
+
+	outboundMutex.Lock()
+	defer outboundMutex.Unlock()
+	upstreamTransport = transport
+	return nil
+}
+
+// UpstreamTransport returns the shared transport for MCP server connections,
+// falling back to http.DefaultTransport when none was initialized.
+func UpstreamTransport() http.RoundTripper {
+	outboundMutex.RLock()
+	defer outboundMutex.RUnlock()
+	if upstreamTransport != nil {
+		return upstreamTransport
+	}
+	return http.DefaultTransport
+}
@@ -0,0 +1,155 @@
+package util
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/wso2/open-mcp-auth-proxy/internal/config"
+)
+
+func TestNewHTTPClientVerifiesTLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	// Without the server's CA the request must fail verification
+	client, err := NewHTTPClient(config.TransportConfig{}, time.Second)
+	if err != nil {
+		t.Fatalf("NewHTTPClient failed: %v", err)
+	}
+	if _, err := client.Get(server.URL); err == nil {
+		t.Errorf("Expected certificate verification error for untrusted server")
+	}
+
+	// With the CA bundle configured the request succeeds
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caFile, caPEM, 0600); err != nil {
+		t.Fatalf("Failed to write CA file: %v", err)
+	}
+
+	client, err = NewHTTPClient(config.TransportConfig{CAFile: caFile}, time.Second)
+	if err != nil {
+		t.Fatalf("NewHTTPClient failed: %v", err)
+	}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Expected request to succeed with CA bundle: %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestNewHTTPClientConfigErrors(t *testing.T) {
+	if _, err := NewHTTPClient(config.TransportConfig{CAFile: "/nonexistent/ca.pem"}, time.Second); err == nil {
+		t.Errorf("Expected error for missing CA file")
+	}
+
+	emptyCA := filepath.Join(t.TempDir(), "empty.pem")
+	os.WriteFile(emptyCA, []byte("not a certificate"), 0600)
+	if _, err := NewHTTPClient(config.TransportConfig{CAFile: emptyCA}, time.Second); err == nil {
+		t.Errorf("Expected error for CA file without certificates")
+	}
+
+	if _, err := NewHTTPClient(config.TransportConfig{ProxyURL: "://bad"}, time.Second); err == nil {
+		t.Errorf("Expected error for invalid proxy URL")
+	}
+
+	if _, err := NewHTTPClient(config.TransportConfig{CertFile: "/nonexistent/cert.pem", KeyFile: "/nonexistent/key.pem"}, time.Second); err == nil {
+		t.Errorf("Expected error for missing client certificate")
+	}
+}
+
+func TestNewHTTPClientTimeout(t *testing.T) {
+	client, _ := NewHTTPClient(config.TransportConfig{}, 7*time.Second)
+	if client.Timeout != 7*time.Second {
+		t.Errorf("Expected fallback timeout 7s, got %v", client.Timeout)
+	}
+
+	client, _ = NewHTTPClient(config.TransportConfig{TimeoutSeconds: 3}, 7*time.Second)
+	if client.Timeout != 3*time.Second {
+		t.Errorf("Expected configured timeout 3s, got %v", client.Timeout)
+	}
+}
+
+func TestNewHTTPClientMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestKeyPair(t, dir, "proxy")
+	clientCertPEM, _ := os.ReadFile(certFile)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AppendCertsFromPEM(clientCertPEM)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	server.StartTLS()
+	defer server.Close()
+
+	caFile := filepath.Join(dir, "ca.pem")
+	os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600)
+
+	// Without a client certificate the handshake is rejected
+	client, _ := NewHTTPClient(config.TransportConfig{CAFile: caFile}, time.Second)
+	if resp, err := client.Get(server.URL); err == nil {
+		resp.Body.Close()
+		t.Errorf("Expected handshake failure without a client certificate")
+	}
+
+	client, err := NewHTTPClient(config.TransportConfig{CAFile: caFile, CertFile: certFile, KeyFile: keyFile}, time.Second)
+	if err != nil {
+		t.Fatalf("NewHTTPClient failed: %v", err)
+	}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Expected request with client certificate to succeed: %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestNewTransportOptions(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600)
+
+	// The test certificate is issued for example.com, so the request only
+	// verifies when that name is used instead of the dialed address
+	transport, err := NewTransport(config.TransportConfig{CAFile: caFile, ServerName: "example.com"})
+	if err != nil {
+		t.Fatalf("NewTransport failed: %v", err)
+	}
+	resp, err := (&http.Client{Transport: transport}).Get(server.URL)
+	if err != nil {
+		t.Fatalf("Expected request with server_name override to succeed: %v", err)
+	}
+	resp.Body.Close()
+
+	transport, _ = NewTransport(config.TransportConfig{
+		DisableHTTP2:                 true,
+		MaxIdleConns:                 10,
+		MaxIdleConnsPerHost:          5,
+		IdleConnTimeoutSeconds:       20,
+		DialTimeoutSeconds:           3,
+		ResponseHeaderTimeoutSeconds: 4,
+	})
+	if transport.ForceAttemptHTTP2 || transport.TLSNextProto == nil {
+		t.Errorf("Expected HTTP/2 to be disabled")
+	}
+	if transport.MaxIdleConns != 10 || transport.MaxIdleConnsPerHost != 5 {
+		t.Errorf("Expected idle limits 10/5, got %d/%d", transport.MaxIdleConns, transport.MaxIdleConnsPerHost)
+	}
+	if transport.IdleConnTimeout != 20*time.Second || transport.ResponseHeaderTimeout != 4*time.Second {
+		t.Errorf("Expected timeouts 20s/4s, got %v/%v", transport.IdleConnTimeout, transport.ResponseHeaderTimeout)
+	}
+}
@@ -4,34 +4,156 @@ import (
 	"crypto/rsa"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"math/big"
 	"net/http"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/wso2/open-mcp-auth-proxy/internal/logging"
+	"github.com/wso2/open-mcp-auth-proxy/internal/metrics"
 )
 
 type JWKS struct {
 	Keys []json.RawMessage `json:"keys"`
 }
 
-var publicKeys map[string]*rsa.PublicKey
+var (
+	jwksMu      sync.RWMutex
+	jwksURL     string
+	publicKeys  map[string]*rsa.PublicKey
+	jwksFetched time.Time // Last fetch attempt
+
+	// jwksFetch serializes fetches, so tokens signed with a new key
+	// trigger a single refresh
+	jwksFetch sync.Mutex
+)
+
+// jwksMinRefreshInterval limits how often tokens signed with unknown keys
+// trigger a refresh
+var jwksMinRefreshInterval = time.Minute
+
+// FetchJWKS downloads JWKS and stores in a package-level map. Later
+// refreshes fetch the same URL.
+func FetchJWKS(url string) error {
+	jwksFetch.Lock()
+	defer jwksFetch.Unlock()
+
+	jwksMu.Lock()
+	jwksURL = url
+	jwksMu.Unlock()
+	return refreshJWKS()
+}
+
+// StartJWKSRefresh refreshes the JWKS fetched by FetchJWKS every interval,
+// keeping the current keys when a refresh fails, until stop is called
+func StartJWKSRefresh(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				jwksFetch.Lock()
+				if err := refreshJWKS(); err != nil {
+					logger.Warn("Failed to refresh JWKS, keeping the current keys: %v", err)
+				}
+				jwksFetch.Unlock()
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// refreshJWKS fetches the JWKS and replaces the loaded keys. The caller
+// holds jwksFetch.
+func refreshJWKS() error {
+	jwksMu.RLock()
+	url := jwksURL
+	jwksMu.RUnlock()
+
+	keys, err := fetchPublicKeys(url)
+
+	jwksMu.Lock()
+	jwksFetched = time.Now()
+	if err == nil {
+		publicKeys = keys
+	}
+	jwksMu.Unlock()
 
-// FetchJWKS downloads JWKS and stores in a package-level map
-func FetchJWKS(jwksURL string) error {
-	resp, err := http.Get(jwksURL)
 	if err != nil {
+		metrics.JWKSRefreshes.Inc(metrics.ResultFailure)
 		return err
 	}
+	metrics.JWKSRefreshes.Inc(metrics.ResultSuccess)
+	metrics.JWKSKeys.Set(float64(len(keys)))
+	metrics.JWKSLastRefresh.Set(float64(time.Now().Unix()))
+	logger.Info("Loaded %d public keys.", len(keys))
+	return nil
+}
+
+// JWKSLoaded reports whether public keys were loaded from the JWKS
+func JWKSLoaded() bool {
+	jwksMu.RLock()
+	defer jwksMu.RUnlock()
+	return len(publicKeys) > 0
+}
+
+// publicKey returns the key with kid, refreshing the JWKS once per
+// jwksMinRefreshInterval when the key isn't known, as after a key rotation
+func publicKey(kid string) (*rsa.PublicKey, bool) {
+	jwksMu.RLock()
+	key, ok := publicKeys[kid]
+	jwksMu.RUnlock()
+	if ok {
+		return key, true
+	}
+
+	jwksFetch.Lock()
+	defer jwksFetch.Unlock()
+
+	// Another request may have refreshed the keys meanwhile
+	jwksMu.RLock()
+	key, ok = publicKeys[kid]
+	stale := jwksURL != "" && time.Since(jwksFetched) >= jwksMinRefreshInterval
+	jwksMu.RUnlock()
+	if ok || !stale {
+		return key, ok
+	}
+
+	logger.Info("Refreshing JWKS for unknown key %q", kid)
+	if err := refreshJWKS(); err != nil {
+		logger.Warn("Failed to refresh JWKS: %v", err)
+		return nil, false
+	}
+	jwksMu.RLock()
+	defer jwksMu.RUnlock()
+	key, ok = publicKeys[kid]
+	return key, ok
+}
+
+// fetchPublicKeys downloads JWKS and parses its RSA keys by kid
+func fetchPublicKeys(jwksURL string) (map[string]*rsa.PublicKey, error) {
+	resp, err := OutboundClient().Get(jwksURL)
+	if err != nil {
+		return nil, err
+	}
 	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("JWKS endpoint returned %s", resp.Status)
+	}
 
 	var jwks JWKS
 	if err := json.NewDecoder(resp.Body).Decode(&jwks); err != nil {
-		return err
+		return nil, err
 	}
 
-	publicKeys = make(map[string]*rsa.PublicKey)
+	keys := make(map[string]*rsa.PublicKey)
 	for _, keyData := range jwks.Keys {
 		var parsedKey struct {
 			Kid string `json:"kid"`
@@ -47,11 +169,10 @@ func FetchJWKS(jwksURL string) error {
 		}
 		pubKey, err := parseRSAPublicKey(parsedKey.N, parsedKey.E)
 		if err == nil {
-			publicKeys[parsedKey.Kid] = pubKey
+			keys[parsedKey.Kid] = pubKey
 		}
 	}
-	logger.Info("Loaded %d public keys.", len(publicKeys))
-	return nil
+	return keys, nil
 }
 
 func parseRSAPublicKey(nStr, eStr string) (*rsa.PublicKey, error) {
@@ -73,25 +194,27 @@ func parseRSAPublicKey(nStr, eStr string) (*rsa.PublicKey, error) {
 	return &rsa.PublicKey{N: n, E: e}, nil
 }
 
-// ValidateJWT checks the Authorization: Bearer token using stored JWKS
-func ValidateJWT(authHeader string) error {
+// ValidateJWTClaims validates the Authorization: Bearer token using stored JWKS
+// and returns its claims
+func ValidateJWTClaims(authHeader string) (jwt.MapClaims, error) {
 	if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
-		return errors.New("missing or invalid Authorization header")
+		return nil, errors.New("missing or invalid Authorization header")
 	}
 	tokenStr := strings.TrimPrefix(authHeader, "Bearer ")
-	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
+	claims := jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
 		kid, _ := token.Header["kid"].(string)
-		pubKey, ok := publicKeys[kid]
+		pubKey, ok := publicKey(kid)
 		if !ok {
 			return nil, errors.New("unknown or missing kid in token header")
 		}
 		return pubKey, nil
 	})
 	if err != nil {
-		return errors.New("invalid token: " + err.Error())
+		return nil, fmt.Errorf("invalid token: %w", err)
 	}
 	if !token.Valid {
-		return errors.New("invalid token: token not valid")
+		return nil, errors.New("invalid token: token not valid")
 	}
-	return nil
+	return claims, nil
 }
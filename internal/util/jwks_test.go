@@ -7,10 +7,12 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
+	"github.com/wso2/open-mcp-auth-proxy/internal/metrics"
 )
 
 func TestValidateJWT(t *testing.T) {
@@ -47,7 +49,7 @@ func TestValidateJWT(t *testing.T) {
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			err := ValidateJWT(tc.authHeader)
+			_, err := ValidateJWTClaims(tc.authHeader)
 			if tc.expectError && err == nil {
 				t.Errorf("Expected error but got none")
 			}
@@ -96,6 +98,108 @@ func TestFetchJWKS(t *testing.T) {
 	}
 }
 
+func TestJWKSRefreshOnUnknownKey(t *testing.T) {
+	oldKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key: %v", err)
+	}
+	newKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key: %v", err)
+	}
+
+	// The server rotates to the new key after the first fetch
+	var fetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		kid, key := "old-key", oldKey
+		if fetches.Add(1) > 1 {
+			kid, key = "new-key", newKey
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]interface{}{{
+				"kty": "RSA",
+				"kid": kid,
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString([]byte{1, 0, 1}),
+			}},
+		})
+	}))
+	defer server.Close()
+
+	if err := FetchJWKS(server.URL); err != nil {
+		t.Fatalf("FetchJWKS failed: %v", err)
+	}
+	sign := func(kid string, key *rsa.PrivateKey) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{"sub": "user-1", "exp": time.Now().Add(time.Hour).Unix()})
+		token.Header["kid"] = kid
+		signed, err := token.SignedString(key)
+		if err != nil {
+			t.Fatalf("Failed to sign token: %v", err)
+		}
+		return "Bearer " + signed
+	}
+
+	// Within the minimum interval, unknown keys don't trigger a refresh
+	if _, err := ValidateJWTClaims(sign("new-key", newKey)); err == nil {
+		t.Errorf("Expected a token signed with an unknown key to be rejected")
+	}
+	if fetches.Load() != 1 {
+		t.Fatalf("Expected no refresh within the minimum interval, got %d fetches", fetches.Load())
+	}
+
+	defer func(interval time.Duration) { jwksMinRefreshInterval = interval }(jwksMinRefreshInterval)
+	jwksMinRefreshInterval = 0
+	if _, err := ValidateJWTClaims(sign("new-key", newKey)); err != nil {
+		t.Errorf("Expected the rotated key to be fetched, got %v", err)
+	}
+	if _, err := ValidateJWTClaims(sign("old-key", oldKey)); err == nil {
+		t.Errorf("Expected the retired key to be dropped")
+	}
+}
+
+func TestJWKSPeriodicRefreshMetrics(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key: %v", err)
+	}
+	var fetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]interface{}{{
+				"kty": "RSA",
+				"kid": "test-key-id",
+				"n":   base64.RawURLEncoding.EncodeToString(privateKey.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString([]byte{1, 0, 1}),
+			}},
+		})
+	}))
+	defer server.Close()
+
+	if err := FetchJWKS(server.URL); err != nil {
+		t.Fatalf("FetchJWKS failed: %v", err)
+	}
+	successes := metrics.JWKSRefreshes.Value(metrics.ResultSuccess)
+	metrics.JWKSLastRefresh.Set(0)
+
+	stop := StartJWKSRefresh(10 * time.Millisecond)
+	deadline := time.Now().Add(2 * time.Second)
+	for fetches.Load() < 3 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	stop()
+
+	if fetches.Load() < 3 {
+		t.Fatalf("Expected periodic refreshes, got %d fetches", fetches.Load())
+	}
+	if metrics.JWKSRefreshes.Value(metrics.ResultSuccess) <= successes {
+		t.Errorf("Expected refreshes to be counted")
+	}
+	if metrics.JWKSLastRefresh.Value() == 0 || metrics.JWKSKeys.Value() != 1 {
+		t.Errorf("Expected the last refresh time and key count to be updated")
+	}
+}
+
 // Helper function to initialize test JWKS data
 func initTestJWKS(t *testing.T) {
 	// Create a test RSA key pair
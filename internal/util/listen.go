@@ -0,0 +1,113 @@
+package util
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"strconv"
+	"time"
+
+	"github.com/wso2/open-mcp-auth-proxy/internal/config"
+)
+
+// Listen opens the proxy's listener on a TCP address or a Unix socket
+func Listen(cfg *config.Config) (net.Listener, error) {
+	path := config.SocketPath(cfg.Listen)
+	if path == "" {
+		return net.Listen("tcp", cfg.Listen)
+	}
+	return listenUnix(path, cfg.ListenSocket)
+}
+
+// listenUnix listens on a Unix socket, replacing a stale socket file left
+// by a previous run, and applies the configured mode and ownership
+func listenUnix(path string, sc config.SocketConfig) (net.Listener, error) {
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("socket %s is already in use", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("failed to remove stale socket: %w", err)
+		}
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := setSocketPermissions(path, sc); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
+
+// setSocketPermissions applies the socket's file mode and owner
+func setSocketPermissions(path string, sc config.SocketConfig) error {
+	if sc.Mode != "" {
+		mode, err := strconv.ParseUint(sc.Mode, 8, 32)
+		if err != nil {
+			return fmt.Errorf("invalid socket mode %q: %w", sc.Mode, err)
+		}
+		if err := os.Chmod(path, os.FileMode(mode)); err != nil {
+			return fmt.Errorf("failed to set socket mode: %w", err)
+		}
+	}
+
+	if sc.Owner == "" && sc.Group == "" {
+		return nil
+	}
+	uid, gid := -1, -1 // -1 leaves the value unchanged
+	if sc.Owner != "" {
+		id, err := lookupID(sc.Owner, func(name string) (string, error) {
+			u, err := user.Lookup(name)
+			if err != nil {
+				return "", err
+			}
+			return u.Uid, nil
+		})
+		if err != nil {
+			return fmt.Errorf("unknown socket owner %q: %w", sc.Owner, err)
+		}
+		uid = id
+	}
+	if sc.Group != "" {
+		id, err := lookupID(sc.Group, func(name string) (string, error) {
+			g, err := user.LookupGroup(name)
+			if err != nil {
+				return "", err
+			}
+			return g.Gid, nil
+		})
+		if err != nil {
+			return fmt.Errorf("unknown socket group %q: %w", sc.Group, err)
+		}
+		gid = id
+	}
+	if err := os.Chown(path, uid, gid); err != nil {
+		return fmt.Errorf("failed to set socket owner: %w", err)
+	}
+	return nil
+}
+
+// lookupID resolves a numeric ID or a name using lookup
+func lookupID(nameOrID string, lookup func(string) (string, error)) (int, error) {
+	if id, err := strconv.Atoi(nameOrID); err == nil {
+		return id, nil
+	}
+	id, err := lookup(nameOrID)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.Atoi(id)
+	if err != nil {
+		return 0, fmt.Errorf("not a numeric ID: %s", id)
+	}
+	return n, nil
+}
@@ -0,0 +1,87 @@
+package util
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/wso2/open-mcp-auth-proxy/internal/config"
+)
+
+func TestListenUnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "proxy.sock")
+	cfg := &config.Config{Listen: "unix:" + path, ListenSocket: config.SocketConfig{Mode: "0600"}}
+
+	ln, err := Listen(cfg)
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Socket not created: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected socket mode 0600, got %v", info.Mode().Perm())
+	}
+
+	// A socket that is still served must not be taken over
+	if _, err := Listen(cfg); err == nil {
+		t.Errorf("Expected error for a socket in use")
+	}
+	ln.Close()
+
+	// A stale socket file left behind is replaced
+	stale, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		t.Fatalf("Failed to create stale socket: %v", err)
+	}
+	stale.SetUnlinkOnClose(false)
+	stale.Close()
+	ln, err = Listen(cfg)
+	if err != nil {
+		t.Fatalf("Expected stale socket to be replaced: %v", err)
+	}
+	ln.Close()
+
+	// Regular files are never removed
+	file := filepath.Join(t.TempDir(), "not-a-socket")
+	os.WriteFile(file, []byte("data"), 0600)
+	if _, err := Listen(&config.Config{Listen: "unix:" + file}); err == nil {
+		t.Errorf("Expected error for a path that isn't a socket")
+	}
+}
+
+func TestUpstreamTransportUnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mcp.sock")
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.URL.Path)
+	})}
+	go server.Serve(ln)
+	defer server.Close()
+
+	if err := InitUpstreamTransport(&config.Config{BaseURL: "unix:" + path}); err != nil {
+		t.Fatalf("InitUpstreamTransport failed: %v", err)
+	}
+	defer func() {
+		outboundMutex.Lock()
+		upstreamTransport = nil
+		outboundMutex.Unlock()
+	}()
+
+	resp, err := (&http.Client{Transport: UpstreamTransport()}).Get("http://localhost/sse")
+	if err != nil {
+		t.Fatalf("Request over the socket failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "/sse" {
+		t.Errorf("Expected body /sse, got %s", body)
+	}
+}
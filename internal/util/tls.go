@@ -0,0 +1,166 @@
+package util
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/wso2/open-mcp-auth-proxy/internal/config"
+	logger "github.com/wso2/open-mcp-auth-proxy/internal/logging"
+)
+
+// CertReloader serves the listener certificate and reloads it when the
+// certificate or key file changes, so renewed certificates need no restart
+type CertReloader struct {
+	certFile string
+	keyFile  string
+	interval time.Duration
+
+	mu          sync.Mutex
+	cert        *tls.Certificate
+	certModTime time.Time
+	keyModTime  time.Time
+	checkedAt   time.Time
+}
+
+// NewCertReloader loads the key pair and checks for changes at most once per interval
+func NewCertReloader(certFile, keyFile string, interval time.Duration) (*CertReloader, error) {
+	r := &CertReloader{certFile: certFile, keyFile: keyFile, interval: interval}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// reload reads the key pair from disk. Callers hold r.mu or own r exclusively.
+func (r *CertReloader) reload() error {
+	certInfo, err := os.Stat(r.certFile)
+	if err != nil {
+		return fmt.Errorf("failed to read TLS certificate: %w", err)
+	}
+	keyInfo, err := os.Stat(r.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to read TLS key: %w", err)
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS key pair: %w", err)
+	}
+
+	r.cert = &cert
+	r.certModTime = certInfo.ModTime()
+	r.keyModTime = keyInfo.ModTime()
+	return nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(r.checkedAt) < r.interval {
+		return r.cert, nil
+	}
+	r.checkedAt = now
+
+	certInfo, certErr := os.Stat(r.certFile)
+	keyInfo, keyErr := os.Stat(r.keyFile)
+	if certErr != nil || keyErr != nil {
+		return r.cert, nil
+	}
+	if certInfo.ModTime().Equal(r.certModTime) && keyInfo.ModTime().Equal(r.keyModTime) {
+		return r.cert, nil
+	}
+
+	// Keep serving the previous certificate if the new pair isn't usable yet,
+	// e.g. while only one of the two files has been replaced
+	if err := r.reload(); err != nil {
+		logger.Warn("Keeping the current TLS certificate: %v", err)
+		return r.cert, nil
+	}
+	logger.Info("Reloaded TLS certificate from %s", r.certFile)
+	return r.cert, nil
+}
+
+// NewServerTLSConfig builds the listener TLS configuration
+func NewServerTLSConfig(tc config.TLSConfig) (*tls.Config, error) {
+	interval := time.Duration(tc.ReloadIntervalSeconds) * time.Second
+	reloader, err := NewCertReloader(tc.CertFile, tc.KeyFile, interval)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig := &tls.Config{
+		GetCertificate: reloader.GetCertificate,
+		MinVersion:     tls.VersionTLS12,
+	}
+	if tc.MinVersion == "1.3" {
+		tlsConfig.MinVersion = tls.VersionTLS13
+	}
+
+	if len(tc.CipherSuites) > 0 {
+		suites, err := cipherSuiteIDs(tc.CipherSuites)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.CipherSuites = suites
+	}
+
+	switch tc.ClientAuth {
+	case "request":
+		tlsConfig.ClientAuth = tls.RequestClientCert
+	case "require":
+		tlsConfig.ClientAuth = tls.RequireAnyClientCert
+	case "verify_if_given":
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	case "require_and_verify":
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	if tc.ClientCAFile != "" {
+		pem, err := os.ReadFile(tc.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA bundle: %w", err)
+		}
+		// Only the configured CAs are trusted for client certificates
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in client CA bundle %s", tc.ClientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+	}
+
+	return tlsConfig, nil
+}
+
+// cipherSuiteIDs maps cipher suite names to IDs, rejecting insecure suites
+func cipherSuiteIDs(names []string) ([]uint16, error) {
+	available := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		available[suite.Name] = suite.ID
+	}
+
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, ok := available[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown or insecure TLS cipher suite %q", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// CertificateThumbprint returns the base64url SHA-256 thumbprint of a
+// certificate, as used in the cnf.x5t#S256 claim (RFC 8705)
+func CertificateThumbprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
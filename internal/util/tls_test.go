@@ -0,0 +1,143 @@
+package util
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/wso2/open-mcp-auth-proxy/internal/config"
+)
+
+// writeTestKeyPair writes a self-signed certificate for commonName and returns its file paths
+func writeTestKeyPair(t *testing.T, dir, commonName string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{"localhost"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+	return certFile, keyFile
+}
+
+func leafCommonName(t *testing.T, cert *tls.Certificate) string {
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	return leaf.Subject.CommonName
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestKeyPair(t, dir, "first")
+
+	reloader, err := NewCertReloader(certFile, keyFile, 0)
+	if err != nil {
+		t.Fatalf("NewCertReloader failed: %v", err)
+	}
+	cert, _ := reloader.GetCertificate(nil)
+	if name := leafCommonName(t, cert); name != "first" {
+		t.Fatalf("Expected the first certificate, got %s", name)
+	}
+
+	// A renewed pair is picked up without a restart
+	writeTestKeyPair(t, dir, "second")
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(certFile, later, later)
+	os.Chtimes(keyFile, later, later)
+	cert, _ = reloader.GetCertificate(nil)
+	if name := leafCommonName(t, cert); name != "second" {
+		t.Errorf("Expected the reloaded certificate, got %s", name)
+	}
+
+	// A broken file keeps the current certificate
+	os.WriteFile(keyFile, []byte("not a key"), 0600)
+	evenLater := later.Add(time.Minute)
+	os.Chtimes(keyFile, evenLater, evenLater)
+	cert, _ = reloader.GetCertificate(nil)
+	if name := leafCommonName(t, cert); name != "second" {
+		t.Errorf("Expected the previous certificate to be kept, got %s", name)
+	}
+}
+
+func TestNewServerTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestKeyPair(t, dir, "proxy")
+
+	tests := []struct {
+		name    string
+		tc      config.TLSConfig
+		wantErr bool
+		check   func(*tls.Config) bool
+	}{
+		{
+			name:  "Defaults",
+			tc:    config.TLSConfig{CertFile: certFile, KeyFile: keyFile},
+			check: func(c *tls.Config) bool { return c.MinVersion == tls.VersionTLS12 && c.ClientAuth == tls.NoClientCert },
+		},
+		{
+			name: "TLS 1.3 with verified client certificates",
+			tc:   config.TLSConfig{CertFile: certFile, KeyFile: keyFile, MinVersion: "1.3", ClientAuth: "require_and_verify", ClientCAFile: certFile},
+			check: func(c *tls.Config) bool {
+				return c.MinVersion == tls.VersionTLS13 && c.ClientAuth == tls.RequireAndVerifyClientCert && c.ClientCAs != nil
+			},
+		},
+		{
+			name:  "Cipher suites",
+			tc:    config.TLSConfig{CertFile: certFile, KeyFile: keyFile, CipherSuites: []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"}},
+			check: func(c *tls.Config) bool { return len(c.CipherSuites) == 1 },
+		},
+		{
+			name:    "Insecure cipher suite",
+			tc:      config.TLSConfig{CertFile: certFile, KeyFile: keyFile, CipherSuites: []string{"TLS_RSA_WITH_RC4_128_SHA"}},
+			wantErr: true,
+		},
+		{
+			name:    "Missing key",
+			tc:      config.TLSConfig{CertFile: certFile, KeyFile: filepath.Join(dir, "missing.pem")},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tlsConfig, err := NewServerTLSConfig(tc.tc)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("Expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !tc.check(tlsConfig) {
+				t.Errorf("Unexpected TLS config: %+v", tlsConfig)
+			}
+		})
+	}
+}
@@ -0,0 +1,299 @@
+package vault
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wso2/open-mcp-auth-proxy/internal/config"
+	logger "github.com/wso2/open-mcp-auth-proxy/internal/logging"
+	"github.com/wso2/open-mcp-auth-proxy/internal/util"
+)
+
+// vaultLog logs credential activity with the authorization subsystem
+var vaultLog = logger.For(logger.Authz)
+
+// connectTimeout bounds how long an OAuth connect flow may take
+const connectTimeout = 10 * time.Minute
+
+// ErrUnknownCredential is returned for credential names that aren't configured
+var ErrUnknownCredential = errors.New("unknown credential")
+
+// pendingConnect is an OAuth connect flow waiting for its callback
+type pendingConnect struct {
+	subject     string
+	name        string
+	verifier    string
+	redirectURI string
+	authURL     string
+	launched    bool // the browser opened the flow and holds its binding
+	expiresAt   time.Time
+}
+
+// Broker connects user credentials to the vault and resolves them into the
+// environment of the user's server
+type Broker struct {
+	vault       *Vault
+	credentials []config.CredentialConfig
+	now         func() time.Time
+
+	mu      sync.Mutex
+	pending map[string]pendingConnect
+}
+
+// NewBroker creates a broker for the configured credentials
+func NewBroker(v *Vault, credentials []config.CredentialConfig) *Broker {
+	return &Broker{
+		vault:       v,
+		credentials: credentials,
+		now:         time.Now,
+		pending:     make(map[string]pendingConnect),
+	}
+}
+
+// Credential returns the configuration of the named credential
+func (b *Broker) Credential(name string) (config.CredentialConfig, bool) {
+	for _, cred := range b.credentials {
+		if cred.Name == name {
+			return cred, true
+		}
+	}
+	return config.CredentialConfig{}, false
+}
+
+// Vault returns the underlying vault
+func (b *Broker) Vault() *Vault {
+	return b.vault
+}
+
+// StartConnect begins an OAuth connect flow for subject and returns its
+// state. The state is bound to the subject that started it; the browser that
+// completes the flow must first open it with LaunchConnect.
+func (b *Broker) StartConnect(subject, name, redirectURI string) (string, error) {
+	cred, ok := b.Credential(name)
+	if !ok {
+		return "", ErrUnknownCredential
+	}
+	if cred.Type != "oauth" {
+		return "", fmt.Errorf("credential %s is not connected with OAuth", name)
+	}
+
+	state := randomString()
+	verifier := randomString()
+	challenge := sha256.Sum256([]byte(verifier))
+
+	authURL, err := url.Parse(cred.AuthorizeURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid authorize_url for %s: %w", name, err)
+	}
+	query := authURL.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", cred.ClientID)
+	query.Set("redirect_uri", redirectURI)
+	query.Set("state", state)
+	query.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	query.Set("code_challenge_method", "S256")
+	if len(cred.Scopes) > 0 {
+		query.Set("scope", strings.Join(cred.Scopes, " "))
+	}
+	authURL.RawQuery = query.Encode()
+
+	b.mu.Lock()
+	now := b.now()
+	for key, p := range b.pending {
+		if now.After(p.expiresAt) {
+			delete(b.pending, key)
+		}
+	}
+	b.pending[state] = pendingConnect{
+		subject:     subject,
+		name:        name,
+		verifier:    verifier,
+		redirectURI: redirectURI,
+		authURL:     authURL.String(),
+		expiresAt:   now.Add(connectTimeout),
+	}
+	b.mu.Unlock()
+	return state, nil
+}
+
+// LaunchConnect opens a connect flow in the user's browser and returns the
+// authorization server URL to redirect to. A flow can be launched once; the
+// browser must keep ConnectBinding(state) to complete it.
+func (b *Broker) LaunchConnect(name, state string) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	p, ok := b.pending[state]
+	if !ok || p.name != name || b.now().After(p.expiresAt) {
+		return "", errors.New("unknown or expired connect state")
+	}
+	if p.launched {
+		return "", errors.New("connect flow was already opened")
+	}
+	p.launched = true
+	b.pending[state] = p
+	return p.authURL, nil
+}
+
+// ConnectBinding returns the value that ties a connect flow to the browser
+// that launched it. Only a hash of the state is kept in the browser.
+func ConnectBinding(state string) string {
+	sum := sha256.Sum256([]byte(state))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// CompleteConnect exchanges the authorization code of a connect flow and
+// stores the token for the subject that started it. binding must match the
+// value given to the browser that launched the flow.
+func (b *Broker) CompleteConnect(ctx context.Context, name, state, code, binding string) (string, error) {
+	b.mu.Lock()
+	p, ok := b.pending[state]
+	if ok && p.launched && subtle.ConstantTimeCompare([]byte(binding), []byte(ConnectBinding(state))) != 1 {
+		// Leave the flow to the browser that launched it
+		b.mu.Unlock()
+		return "", errors.New("connect flow was launched in another browser")
+	}
+	delete(b.pending, state)
+	b.mu.Unlock()
+
+	if !ok || p.name != name || !p.launched || b.now().After(p.expiresAt) {
+		return "", errors.New("unknown or expired connect state")
+	}
+	cred, ok := b.Credential(name)
+	if !ok {
+		return "", ErrUnknownCredential
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.redirectURI)
+	form.Set("code_verifier", p.verifier)
+
+	stored, err := b.requestToken(ctx, cred, form)
+	if err != nil {
+		return "", err
+	}
+	if err := b.vault.Put(p.subject, name, stored); err != nil {
+		return "", err
+	}
+	return p.subject, nil
+}
+
+// Environment returns the environment variables of the subject's server and
+// the names of required credentials that aren't connected. Expired OAuth
+// tokens are refreshed when possible.
+func (b *Broker) Environment(ctx context.Context, subject string) (env []string, missing []string) {
+	for _, cred := range b.credentials {
+		stored, ok := b.vault.Get(subject, cred.Name)
+		if ok && stored.Expired(b.now()) {
+			ok = false
+			if stored.RefreshToken != "" {
+				refreshed, err := b.refresh(ctx, subject, cred, stored)
+				if err != nil {
+					vaultLog.Ctx(ctx).Warn("Failed to refresh %s credential for %s: %v", cred.Name, subject, err)
+				} else {
+					stored, ok = refreshed, true
+				}
+			}
+		}
+
+		if !ok {
+			if !cred.Optional {
+				missing = append(missing, cred.Name)
+			}
+			continue
+		}
+		env = append(env, cred.Env+"="+stored.Value)
+	}
+	return env, missing
+}
+
+// refresh renews an expired OAuth credential and stores the result
+func (b *Broker) refresh(ctx context.Context, subject string, cred config.CredentialConfig, stored Credential) (Credential, error) {
+	form := url.Values{}
+	form.Set("grant_type", "refresh_token")
+	form.Set("refresh_token", stored.RefreshToken)
+
+	refreshed, err := b.requestToken(ctx, cred, form)
+	if err != nil {
+		return Credential{}, err
+	}
+	if refreshed.RefreshToken == "" {
+		// Servers may keep the refresh token unchanged
+		refreshed.RefreshToken = stored.RefreshToken
+	}
+	if err := b.vault.Put(subject, cred.Name, refreshed); err != nil {
+		return Credential{}, err
+	}
+	return refreshed, nil
+}
+
+// requestToken calls the credential's token endpoint with client_secret_post authentication
+func (b *Broker) requestToken(ctx context.Context, cred config.CredentialConfig, form url.Values) (Credential, error) {
+	form.Set("client_id", cred.ClientID)
+	if cred.ClientSecret != "" {
+		form.Set("client_secret", cred.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cred.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Credential{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json") // GitHub defaults to form encoding
+
+	resp, err := util.OutboundClient().Do(req)
+	if err != nil {
+		return Credential{}, fmt.Errorf("token request for %s failed: %w", cred.Name, err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return Credential{}, fmt.Errorf("token request for %s failed (%d): %s", cred.Name, resp.StatusCode, string(body))
+	}
+
+	var tokenResp struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		ExpiresIn    int    `json:"expires_in"`
+		Error        string `json:"error"`
+	}
+	if err := json.Unmarshal(body, &tokenResp); err != nil {
+		return Credential{}, fmt.Errorf("failed to parse token response for %s: %w", cred.Name, err)
+	}
+	if tokenResp.AccessToken == "" {
+		return Credential{}, fmt.Errorf("token request for %s failed: %s", cred.Name, tokenResp.Error)
+	}
+
+	now := b.now()
+	stored := Credential{
+		Value:        tokenResp.AccessToken,
+		RefreshToken: tokenResp.RefreshToken,
+		UpdatedAt:    now,
+	}
+	if tokenResp.ExpiresIn > 0 {
+		stored.ExpiresAt = now.Add(time.Duration(tokenResp.ExpiresIn) * time.Second)
+	}
+	return stored, nil
+}
+
+// randomString returns a URL-safe random value for states and PKCE verifiers
+func randomString() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,229 @@
+package vault
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/wso2/open-mcp-auth-proxy/internal/util"
+)
+
+// Paths served by the vault handler
+const (
+	PathPrefix      = "/vault/"
+	credentialsPath = "/vault/credentials"
+	connectPath     = "/vault/connect/"
+	authorizePath   = "/vault/authorize/"
+	callbackPath    = "/vault/callback/"
+)
+
+// connectCookie holds the binding of a connect flow in the browser that launched it
+const connectCookie = "mcp_vault_connect"
+
+// maxCredentialSize limits pasted credential bodies
+const maxCredentialSize = 64 << 10
+
+// Authenticator returns the token subject of an authenticated request
+type Authenticator func(r *http.Request) (string, error)
+
+// credentialStatus describes a configured credential for the current user
+type credentialStatus struct {
+	Name      string     `json:"name"`
+	Type      string     `json:"type"`
+	Optional  bool       `json:"optional,omitempty"`
+	Connected bool       `json:"connected"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// NewHandler serves the vault API:
+//   - GET    /vault/credentials         lists the user's credentials
+//   - PUT    /vault/credentials/{name}  stores a pasted credential ({"value": "..."})
+//   - DELETE /vault/credentials/{name}  removes a credential
+//   - POST   /vault/connect/{name}      starts an OAuth connect flow
+//   - GET    /vault/authorize/{name}    opens a connect flow in the browser
+//   - GET    /vault/callback/{name}     completes an OAuth connect flow
+func NewHandler(b *Broker, authenticate Authenticator) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The browser pages are authenticated by the state and the cookie
+		// set when the flow was opened
+		if name, ok := strings.CutPrefix(r.URL.Path, authorizePath); ok {
+			handleAuthorize(w, r, b, name)
+			return
+		}
+		if name, ok := strings.CutPrefix(r.URL.Path, callbackPath); ok {
+			handleCallback(w, r, b, name)
+			return
+		}
+
+		subject, err := authenticate(r)
+		if err != nil || subject == "" {
+			vaultLog.Ctx(r.Context()).Warn("Unauthorized vault request to %s: %v", r.URL.Path, err)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		switch {
+		case r.URL.Path == credentialsPath:
+			if r.Method != http.MethodGet {
+				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			listCredentials(w, b, subject)
+		case strings.HasPrefix(r.URL.Path, credentialsPath+"/"):
+			handleCredential(w, r, b, subject, strings.TrimPrefix(r.URL.Path, credentialsPath+"/"))
+		case strings.HasPrefix(r.URL.Path, connectPath):
+			handleConnect(w, r, b, subject, strings.TrimPrefix(r.URL.Path, connectPath))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+func listCredentials(w http.ResponseWriter, b *Broker, subject string) {
+	statuses := make([]credentialStatus, 0, len(b.credentials))
+	for _, cred := range b.credentials {
+		status := credentialStatus{Name: cred.Name, Type: cred.Type, Optional: cred.Optional}
+		if stored, ok := b.vault.Get(subject, cred.Name); ok {
+			status.Connected = true
+			if !stored.ExpiresAt.IsZero() {
+				expiresAt := stored.ExpiresAt
+				status.ExpiresAt = &expiresAt
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"credentials": statuses})
+}
+
+func handleCredential(w http.ResponseWriter, r *http.Request, b *Broker, subject, name string) {
+	cred, ok := b.Credential(name)
+	if !ok {
+		http.Error(w, "Unknown credential", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut, http.MethodPost:
+		if cred.Type != "paste" {
+			http.Error(w, fmt.Sprintf("Credential %s is connected with OAuth", name), http.StatusBadRequest)
+			return
+		}
+		var body struct {
+			Value string `json:"value"`
+		}
+		if err := json.NewDecoder(io.LimitReader(r.Body, maxCredentialSize)).Decode(&body); err != nil || body.Value == "" {
+			http.Error(w, "Expected a JSON body with a value", http.StatusBadRequest)
+			return
+		}
+		if err := b.vault.Put(subject, name, Credential{Value: body.Value}); err != nil {
+			vaultLog.Ctx(r.Context()).Error("Failed to store %s credential for %s: %v", name, subject, err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		vaultLog.Ctx(r.Context()).Info("Stored %s credential for %s", name, subject)
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodDelete:
+		if err := b.vault.Delete(subject, name); err != nil {
+			vaultLog.Ctx(r.Context()).Error("Failed to delete %s credential for %s: %v", name, subject, err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		vaultLog.Ctx(r.Context()).Info("Deleted %s credential for %s", name, subject)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func handleConnect(w http.ResponseWriter, r *http.Request, b *Broker, subject, name string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	baseURL := util.GetExternalBaseURL(r)
+	state, err := b.StartConnect(subject, name, baseURL+callbackPath+name)
+	if errors.Is(err, ErrUnknownCredential) {
+		http.Error(w, "Unknown credential", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	launchURL := baseURL + authorizePath + name + "?state=" + url.QueryEscape(state)
+	writeJSON(w, http.StatusOK, map[string]string{"authorization_url": launchURL})
+}
+
+// handleAuthorize ties a connect flow to the browser that opens it and
+// redirects to the authorization server
+func handleAuthorize(w http.ResponseWriter, r *http.Request, b *Broker, name string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	state := r.URL.Query().Get("state")
+	authURL, err := b.LaunchConnect(name, state)
+	if err != nil {
+		vaultLog.Ctx(r.Context()).Warn("Failed to open %s connect flow: %v", name, err)
+		http.Error(w, "Unknown or expired connect flow", http.StatusBadRequest)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     connectCookie,
+		Value:    ConnectBinding(state),
+		Path:     callbackPath + name,
+		MaxAge:   int(connectTimeout / time.Second),
+		Secure:   strings.HasPrefix(util.GetExternalBaseURL(r), "https://"),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode, // sent on the top-level redirect back from the authorization server
+	})
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+func handleCallback(w http.ResponseWriter, r *http.Request, b *Broker, name string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	if errCode := query.Get("error"); errCode != "" {
+		http.Error(w, "Authorization failed: "+errCode, http.StatusBadRequest)
+		return
+	}
+
+	var binding string
+	if cookie, err := r.Cookie(connectCookie); err == nil {
+		binding = cookie.Value
+	}
+	subject, err := b.CompleteConnect(r.Context(), name, query.Get("state"), query.Get("code"), binding)
+	if err != nil {
+		vaultLog.Ctx(r.Context()).Warn("Failed to connect %s credential: %v", name, err)
+		http.Error(w, "Failed to connect credential", http.StatusBadRequest)
+		return
+	}
+
+	vaultLog.Ctx(r.Context()).Info("Connected %s credential for %s", name, subject)
+	http.SetCookie(w, &http.Cookie{Name: connectCookie, Path: callbackPath + name, MaxAge: -1, HttpOnly: true})
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, "<p>%s is connected. You can close this window.</p>", html.EscapeString(name))
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		vaultLog.Error("Error encoding vault response: %v", err)
+	}
+}
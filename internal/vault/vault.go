@@ -0,0 +1,209 @@
+package vault
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// KeyEnv is the environment variable holding the vault key when no key file is set
+const KeyEnv = "MCP_VAULT_KEY"
+
+// fileMagic prefixes the vault file and is authenticated with the contents
+var fileMagic = []byte("OMAPVAULT1")
+
+// Credential is a secret a user connected to the vault
+type Credential struct {
+	Value        string    `json:"value"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	ExpiresAt    time.Time `json:"expires_at,omitempty"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// Expired reports whether the credential has a known expiry in the past
+func (c Credential) Expired(now time.Time) bool {
+	return !c.ExpiresAt.IsZero() && !now.Before(c.ExpiresAt)
+}
+
+// Vault stores credentials keyed by token subject in an AES-GCM encrypted file
+type Vault struct {
+	path string
+	aead cipher.AEAD
+
+	mu   sync.RWMutex
+	data map[string]map[string]Credential
+}
+
+// LoadKey reads the 32-byte vault key from keyFile, or from MCP_VAULT_KEY
+// when keyFile is empty. The key may be raw bytes, hex or base64.
+func LoadKey(keyFile string) ([]byte, error) {
+	var raw []byte
+	if keyFile != "" {
+		data, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read vault key: %w", err)
+		}
+		raw = data
+	} else if env := os.Getenv(KeyEnv); env != "" {
+		raw = []byte(env)
+	} else {
+		return nil, fmt.Errorf("vault key not configured: set vault.key_file or %s", KeyEnv)
+	}
+
+	if len(raw) == 32 {
+		return raw, nil
+	}
+	text := strings.TrimSpace(string(raw))
+	if key, err := hex.DecodeString(text); err == nil && len(key) == 32 {
+		return key, nil
+	}
+	if key, err := base64.StdEncoding.DecodeString(text); err == nil && len(key) == 32 {
+		return key, nil
+	}
+	return nil, errors.New("vault key must be 32 bytes (raw, hex or base64)")
+}
+
+// Open loads the vault at path, creating an empty one if the file doesn't exist
+func Open(path string, key []byte) (*Vault, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid vault key: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	v := &Vault{path: path, aead: aead, data: make(map[string]map[string]Credential)}
+
+	contents, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return v, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vault: %w", err)
+	}
+	if err := v.decrypt(contents); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// decrypt loads the vault contents from the file format: magic | nonce | ciphertext
+func (v *Vault) decrypt(contents []byte) error {
+	nonceSize := v.aead.NonceSize()
+	if !bytes.HasPrefix(contents, fileMagic) || len(contents) < len(fileMagic)+nonceSize {
+		return errors.New("vault file is not in the expected format")
+	}
+	nonce := contents[len(fileMagic) : len(fileMagic)+nonceSize]
+	ciphertext := contents[len(fileMagic)+nonceSize:]
+
+	plaintext, err := v.aead.Open(nil, nonce, ciphertext, fileMagic)
+	if err != nil {
+		return errors.New("failed to decrypt vault: wrong key or corrupted file")
+	}
+	if err := json.Unmarshal(plaintext, &v.data); err != nil {
+		return fmt.Errorf("failed to parse vault: %w", err)
+	}
+	return nil
+}
+
+// save encrypts and atomically replaces the vault file. Callers hold v.mu.
+func (v *Vault) save() error {
+	plaintext, err := json.Marshal(v.data)
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, v.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	contents := append(append(append([]byte{}, fileMagic...), nonce...), v.aead.Seal(nil, nonce, plaintext, fileMagic)...)
+
+	tmp, err := os.CreateTemp(filepath.Dir(v.path), ".vault-*")
+	if err != nil {
+		return fmt.Errorf("failed to write vault: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(contents); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write vault: %w", err)
+	}
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), v.path)
+}
+
+// Get returns the named credential of a subject
+func (v *Vault) Get(subject, name string) (Credential, bool) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	cred, ok := v.data[subject][name]
+	return cred, ok
+}
+
+// List returns the sorted names of the credentials a subject connected
+func (v *Vault) List(subject string) []string {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	names := make([]string, 0, len(v.data[subject]))
+	for name := range v.data[subject] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Put stores a credential for a subject
+func (v *Vault) Put(subject, name string, cred Credential) error {
+	if subject == "" {
+		return errors.New("credentials require a subject")
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.data[subject] == nil {
+		v.data[subject] = make(map[string]Credential)
+	}
+	if cred.UpdatedAt.IsZero() {
+		cred.UpdatedAt = time.Now()
+	}
+	v.data[subject][name] = cred
+	return v.save()
+}
+
+// Delete removes a credential of a subject
+func (v *Vault) Delete(subject, name string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if _, ok := v.data[subject][name]; !ok {
+		return nil
+	}
+	delete(v.data[subject], name)
+	if len(v.data[subject]) == 0 {
+		delete(v.data, subject)
+	}
+	return v.save()
+}
@@ -0,0 +1,279 @@
+package vault
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wso2/open-mcp-auth-proxy/internal/config"
+)
+
+var testKey = bytes.Repeat([]byte{7}, 32)
+
+func TestVaultPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vault.enc")
+
+	v, err := Open(path, testKey)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := v.Put("alice", "github", Credential{Value: "ghp_secret"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	contents, _ := os.ReadFile(path)
+	if bytes.Contains(contents, []byte("ghp_secret")) || bytes.Contains(contents, []byte("alice")) {
+		t.Errorf("Expected the vault file to be encrypted")
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+		t.Errorf("Expected vault file mode 0600, got %v", info.Mode().Perm())
+	}
+
+	reopened, err := Open(path, testKey)
+	if err != nil {
+		t.Fatalf("Unexpected error reopening vault: %v", err)
+	}
+	if cred, ok := reopened.Get("alice", "github"); !ok || cred.Value != "ghp_secret" {
+		t.Errorf("Expected stored credential, got %v", cred)
+	}
+	if names := reopened.List("bob"); len(names) != 0 {
+		t.Errorf("Expected no credentials for another subject, got %v", names)
+	}
+
+	if _, err := Open(path, bytes.Repeat([]byte{8}, 32)); err == nil {
+		t.Errorf("Expected an error opening the vault with the wrong key")
+	}
+}
+
+func TestLoadKey(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name     string
+		contents string
+		valid    bool
+	}{
+		{"Raw", string(testKey), true},
+		{"Hex", hex.EncodeToString(testKey) + "\n", true},
+		{"Base64", "BwcHBwcHBwcHBwcHBwcHBwcHBwcHBwcHBwcHBwcHBwc=", true},
+		{"Too short", "short", false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(dir, strings.ReplaceAll(tc.name, " ", "_"))
+			os.WriteFile(path, []byte(tc.contents), 0600)
+
+			key, err := LoadKey(path)
+			if tc.valid && (err != nil || !bytes.Equal(key, testKey)) {
+				t.Errorf("Expected the test key, got %x (%v)", key, err)
+			}
+			if !tc.valid && err == nil {
+				t.Errorf("Expected an error for an invalid key")
+			}
+		})
+	}
+}
+
+// startFakeOAuthServer issues a token for the code "good-code" and checks PKCE was sent
+func startFakeOAuthServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.PostForm.Get("grant_type") == "authorization_code" && r.PostForm.Get("code") == "good-code" && r.PostForm.Get("code_verifier") != "":
+			w.Write([]byte(`{"access_token":"gho_user","refresh_token":"refresh-1","expires_in":3600}`))
+		case r.PostForm.Get("grant_type") == "refresh_token" && r.PostForm.Get("refresh_token") == "refresh-1":
+			w.Write([]byte(`{"access_token":"gho_refreshed","expires_in":3600}`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_grant"}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newTestBroker(t *testing.T, tokenURL string) *Broker {
+	v, err := Open(filepath.Join(t.TempDir(), "vault.enc"), testKey)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return NewBroker(v, []config.CredentialConfig{
+		{Name: "github", Env: "GITHUB_TOKEN", Type: "oauth", AuthorizeURL: "https://github.example/authorize", TokenURL: tokenURL, ClientID: "gh-client"},
+		{Name: "jira", Env: "JIRA_TOKEN", Type: "paste"},
+		{Name: "slack", Env: "SLACK_TOKEN", Type: "paste", Optional: true},
+	})
+}
+
+func TestBrokerOAuthConnect(t *testing.T) {
+	oauth := startFakeOAuthServer(t)
+	broker := newTestBroker(t, oauth.URL)
+
+	state, err := broker.StartConnect("alice", "github", "https://proxy.example/vault/callback/github")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	authURL, err := broker.LaunchConnect("github", state)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	parsed, _ := url.Parse(authURL)
+	if parsed.Query().Get("code_challenge_method") != "S256" || parsed.Query().Get("state") != state {
+		t.Fatalf("Expected a PKCE authorization URL with state, got %s", authURL)
+	}
+	if _, err := broker.LaunchConnect("github", state); err == nil {
+		t.Errorf("Expected a flow to be launched only once")
+	}
+
+	if _, err := broker.CompleteConnect(context.Background(), "jira", state, "good-code", ConnectBinding(state)); err == nil {
+		t.Errorf("Expected the state to be bound to the credential")
+	}
+
+	// The failed attempt consumed the state
+	state, _ = broker.StartConnect("alice", "github", "https://proxy.example/vault/callback/github")
+	if _, err := broker.CompleteConnect(context.Background(), "github", state, "good-code", ConnectBinding(state)); err == nil {
+		t.Errorf("Expected a flow that was never launched to be rejected")
+	}
+
+	state, _ = broker.StartConnect("alice", "github", "https://proxy.example/vault/callback/github")
+	broker.LaunchConnect("github", state)
+	subject, err := broker.CompleteConnect(context.Background(), "github", state, "good-code", ConnectBinding(state))
+	if err != nil || subject != "alice" {
+		t.Fatalf("Expected connect to complete for alice, got %q (%v)", subject, err)
+	}
+
+	env, missing := broker.Environment(context.Background(), "alice")
+	if !reflect.DeepEqual(env, []string{"GITHUB_TOKEN=gho_user"}) || !reflect.DeepEqual(missing, []string{"jira"}) {
+		t.Errorf("Unexpected environment %v, missing %v", env, missing)
+	}
+
+	// Expired tokens are refreshed
+	broker.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	env, _ = broker.Environment(context.Background(), "alice")
+	if !reflect.DeepEqual(env, []string{"GITHUB_TOKEN=gho_refreshed"}) {
+		t.Errorf("Expected refreshed token, got %v", env)
+	}
+	if cred, _ := broker.Vault().Get("alice", "github"); cred.RefreshToken != "refresh-1" {
+		t.Errorf("Expected refresh token to be kept, got %q", cred.RefreshToken)
+	}
+}
+
+func TestHandlerPasteCredential(t *testing.T) {
+	broker := newTestBroker(t, "http://unused.invalid")
+	handler := NewHandler(broker, func(r *http.Request) (string, error) {
+		return strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "), nil
+	})
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		expected int
+	}{
+		{"Paste", "PUT", "/vault/credentials/jira", `{"value":"jira-secret"}`, http.StatusNoContent},
+		{"Empty value", "PUT", "/vault/credentials/jira", `{}`, http.StatusBadRequest},
+		{"OAuth credential", "PUT", "/vault/credentials/github", `{"value":"x"}`, http.StatusBadRequest},
+		{"Unknown credential", "PUT", "/vault/credentials/unknown", `{"value":"x"}`, http.StatusNotFound},
+		{"List", "GET", "/vault/credentials", "", http.StatusOK},
+		{"Callback with unknown state", "GET", "/vault/callback/github?state=forged&code=good-code", "", http.StatusBadRequest},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			req.Header.Set("Authorization", "Bearer alice")
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if w.Code != tc.expected {
+				t.Errorf("Expected status %d, got %d: %s", tc.expected, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	if cred, ok := broker.Vault().Get("alice", "jira"); !ok || cred.Value != "jira-secret" {
+		t.Errorf("Expected pasted credential to be stored for alice")
+	}
+}
+
+func TestHandlerOAuthConnectBoundToBrowser(t *testing.T) {
+	oauth := startFakeOAuthServer(t)
+	broker := newTestBroker(t, oauth.URL)
+	handler := NewHandler(broker, func(r *http.Request) (string, error) {
+		return strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "), nil
+	})
+
+	// mallory starts a flow and sends the link to alice
+	req := httptest.NewRequest("POST", "/vault/connect/github", nil)
+	req.Header.Set("Authorization", "Bearer mallory")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	var started struct {
+		AuthorizationURL string `json:"authorization_url"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&started); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("Expected a connect URL, got %d (%v)", w.Code, err)
+	}
+	launch, _ := url.Parse(started.AuthorizationURL)
+	if !strings.HasPrefix(launch.Path, authorizePath) {
+		t.Fatalf("Expected the connect URL to be a proxy page, got %s", started.AuthorizationURL)
+	}
+	state := launch.Query().Get("state")
+
+	// mallory's browser opens the page and gets the cookie
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", launch.RequestURI(), nil))
+	if w.Code != http.StatusFound {
+		t.Fatalf("Expected a redirect to the authorization server, got %d", w.Code)
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || !cookies[0].HttpOnly || cookies[0].SameSite != http.SameSiteLaxMode || cookies[0].Value == state {
+		t.Fatalf("Expected an HttpOnly, SameSite=Lax cookie holding a hash of the state, got %v", cookies)
+	}
+
+	// The page can't be opened again in alice's browser
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", launch.RequestURI(), nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected the page to open once, got %d", w.Code)
+	}
+
+	// alice's browser completes the flow without the cookie
+	callback := "/vault/callback/github?state=" + url.QueryEscape(state) + "&code=good-code"
+	for _, cookie := range []*http.Cookie{nil, {Name: connectCookie, Value: ConnectBinding("other")}} {
+		req := httptest.NewRequest("GET", callback, nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected a callback from another browser to fail, got %d", w.Code)
+		}
+	}
+	if _, ok := broker.Vault().Get("mallory", "github"); ok {
+		t.Fatalf("Expected no credential to be stored for mallory")
+	}
+
+	// The browser that opened the flow can still complete it
+	req = httptest.NewRequest("GET", callback, nil)
+	req.AddCookie(cookies[0])
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected the callback to succeed, got %d: %s", w.Code, w.Body.String())
+	}
+	if _, ok := broker.Vault().Get("mallory", "github"); !ok {
+		t.Errorf("Expected the credential to be stored for the initiator")
+	}
+}